@@ -0,0 +1,418 @@
+package gogopython
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// EventLoop is an asyncio event loop owned by Go for a single interpreter.
+//
+// The loop only runs while Go is waiting on it in Run, on the calling
+// goroutine's OS thread.
+type EventLoop struct {
+	loop    PyObjectPtr
+	resolve PyObjectPtr // Helper to resolve futures from the loop's thread.
+	interp  PyInterpreterStatePtr
+	id      int64
+
+	// Cancelled when the loop is closed, stopping any pending Go futures.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	closed  bool
+	pending sync.WaitGroup // Go futures whose fn hasn't returned.
+}
+
+// Python helper used to resolve futures created by Go. It runs on the loop
+// via call_soon_threadsafe as futures aren't thread-safe.
+const resolveFutureSrc = `
+def resolve(fut, value, exc):
+    if fut.done():
+        return
+    if exc is not None:
+        fut.set_exception(exc)
+    else:
+        fut.set_result(value)
+`
+
+var eventLoops = struct {
+	sync.Mutex
+	loops map[int64]*EventLoop
+}{loops: make(map[int64]*EventLoop)}
+
+// GetEventLoop returns the Go-owned event loop for the current interpreter,
+// creating it and making it the current asyncio event loop if needed.
+//
+// Requires the caller to hold the GIL.
+func GetEventLoop() (*EventLoop, error) {
+	interp := PyInterpreterState_Get()
+	id := PyInterpreterState_GetID(interp)
+
+	eventLoops.Lock()
+	defer eventLoops.Unlock()
+	if l, ok := eventLoops.loops[id]; ok {
+		return l, nil
+	}
+
+	asyncio := PyImport_ImportModule("asyncio")
+	if asyncio == NullPyObjectPtr {
+		return nil, FetchError()
+	}
+	defer Py_DecRef(asyncio)
+
+	loop := callMethod(asyncio, "new_event_loop")
+	if loop == NullPyObjectPtr {
+		return nil, FetchError()
+	}
+	if res := callMethod(asyncio, "set_event_loop", loop); res == NullPyObjectPtr {
+		err := FetchError()
+		Py_DecRef(loop)
+		return nil, err
+	} else {
+		Py_DecRef(res)
+	}
+
+	resolve, err := compileHelper(resolveFutureSrc, "resolve")
+	if err != nil {
+		Py_DecRef(loop)
+		return nil, err
+	}
+
+	l := &EventLoop{loop: loop, resolve: resolve, interp: interp, id: id}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	eventLoops.loops[id] = l
+	return l, nil
+}
+
+// Loop returns a borrowed reference to the Python event loop object.
+func (l *EventLoop) Loop() PyObjectPtr {
+	return l.loop
+}
+
+// Close cancels pending Go futures, waits for their funcs to return, and
+// closes the event loop. Futures aren't resolved once the loop is closed. A
+// new loop will be created by the next call to GetEventLoop.
+//
+// The loop must be closed before its interpreter ends.
+//
+// Requires the caller to hold the GIL, which is released while waiting.
+func (l *EventLoop) Close() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	eventLoops.Lock()
+	delete(eventLoops.loops, l.id)
+	eventLoops.Unlock()
+
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+	l.cancel()
+
+	// Pending futures need the GIL to finish.
+	ts := PyEval_SaveThread()
+	l.pending.Wait()
+	PyEval_RestoreThread(ts)
+
+	res := callMethod(l.loop, "close")
+	if res == NullPyObjectPtr {
+		return FetchError()
+	}
+	Py_DecRef(res)
+	Py_DecRef(l.resolve)
+	Py_DecRef(l.loop)
+	return nil
+}
+
+// Run runs an awaitable, such as the coroutine returned by calling an async
+// def function, to completion on the event loop and returns its result as
+// a new reference.
+//
+// If ctx is done before the awaitable completes, its task is cancelled and
+// ctx.Err() is returned once the task finishes. A Python exception is
+// returned as an *Exception.
+//
+// Requires the caller to hold the GIL. The goroutine is locked to its OS
+// thread while the loop runs.
+func (l *EventLoop) Run(ctx context.Context, awaitable PyObjectPtr) (PyObjectPtr, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if err := ctx.Err(); err != nil {
+		return NullPyObjectPtr, err
+	}
+
+	task := l.ensureFuture(awaitable)
+	if task == NullPyObjectPtr {
+		return NullPyObjectPtr, FetchError()
+	}
+	defer Py_DecRef(task)
+
+	// Watch for cancellation while the loop runs. The watcher enters the
+	// interpreter on its own thread, which works as the loop releases the
+	// GIL while waiting for events.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	if ctx.Done() != nil {
+		Py_IncRef(task)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-ctx.Done():
+				callInInterpreter(l.interp, func() {
+					if cancel := PyObject_GetAttrString(task, "cancel"); cancel != NullPyObjectPtr {
+						l.callSoonThreadsafe(cancel)
+						Py_DecRef(cancel)
+					} else {
+						PyErr_Clear()
+					}
+					Py_DecRef(task)
+				})
+			case <-stop:
+				callInInterpreter(l.interp, func() {
+					Py_DecRef(task)
+				})
+			}
+		}()
+	}
+
+	result := callMethod(l.loop, "run_until_complete", task)
+
+	// Let the watcher finish. It may need the GIL, so release it.
+	close(stop)
+	ts := PyEval_SaveThread()
+	wg.Wait()
+	PyEval_RestoreThread(ts)
+
+	if result == NullPyObjectPtr {
+		err := FetchError()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			var exc *Exception
+			if errors.As(err, &exc) && exc.Type == "CancelledError" {
+				return NullPyObjectPtr, ctxErr
+			}
+		}
+		return NullPyObjectPtr, err
+	}
+	return result, nil
+}
+
+// ensureFuture wraps an awaitable in a task scheduled on the loop.
+func (l *EventLoop) ensureFuture(awaitable PyObjectPtr) PyObjectPtr {
+	asyncio := PyImport_ImportModule("asyncio")
+	if asyncio == NullPyObjectPtr {
+		return NullPyObjectPtr
+	}
+	defer Py_DecRef(asyncio)
+
+	ensure := PyObject_GetAttrString(asyncio, "ensure_future")
+	if ensure == NullPyObjectPtr {
+		return NullPyObjectPtr
+	}
+	defer Py_DecRef(ensure)
+
+	args := newTuple(awaitable)
+	defer Py_DecRef(args)
+	kwargs := PyDict_New()
+	defer Py_DecRef(kwargs)
+	PyDict_SetItemString(kwargs, "loop", l.loop)
+
+	return PyObject_Call(ensure, args, kwargs)
+}
+
+// Future creates an asyncio future on the loop that's resolved with the
+// result of fn, which runs in a new goroutine. Python code can await the
+// future while fn runs without holding the GIL.
+//
+// The result of fn is converted using ToPython and an error is raised in
+// Python as a RuntimeError. The context passed to fn is cancelled if the
+// future is cancelled or the loop is closed.
+//
+// Returns a new reference. Requires the caller to hold the GIL.
+func (l *EventLoop) Future(fn func(ctx context.Context) (any, error)) (PyObjectPtr, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return NullPyObjectPtr, errors.New("event loop is closed")
+	}
+
+	fut := callMethod(l.loop, "create_future")
+	if fut == NullPyObjectPtr {
+		return NullPyObjectPtr, FetchError()
+	}
+
+	ctx, cancel := context.WithCancel(l.ctx)
+	onDone := NewFunction("_go_future_done", NullPyObjectPtr, func(_, _ PyObjectPtr) PyObjectPtr {
+		cancel()
+		Py_IncRef(Py_None)
+		return Py_None
+	})
+	res := callMethod(fut, "add_done_callback", onDone)
+	Py_DecRef(onDone)
+	if res == NullPyObjectPtr {
+		cancel()
+		Py_DecRef(fut)
+		return NullPyObjectPtr, FetchError()
+	}
+	Py_DecRef(res)
+
+	// Our goroutine holds its own reference to the future. Close waits
+	// for it, so the loop and interpreter outlive it.
+	Py_IncRef(fut)
+	l.pending.Add(1)
+	go func() {
+		defer l.pending.Done()
+		v, err := fn(ctx)
+		callInInterpreter(l.interp, func() {
+			defer Py_DecRef(fut)
+			l.mu.Lock()
+			closed := l.closed
+			l.mu.Unlock()
+			if closed {
+				return
+			}
+			l.resolveFuture(fut, v, err)
+		})
+	}()
+	return fut, nil
+}
+
+// resolveFuture sets the result of a Go future from the loop's thread.
+func (l *EventLoop) resolveFuture(fut PyObjectPtr, v any, err error) {
+	// Take exactly one reference for each of value and exc.
+	value := NullPyObjectPtr
+	if err == nil {
+		value, err = ToPython(v)
+	}
+	var exc PyObjectPtr
+	if err != nil {
+		value = Py_None
+		Py_IncRef(Py_None)
+		exc = newExceptionObject(PyExc_RuntimeError, err.Error())
+	} else {
+		exc = Py_None
+		Py_IncRef(Py_None)
+	}
+
+	l.callSoonThreadsafe(l.resolve, fut, value, exc)
+	Py_DecRef(value)
+	Py_DecRef(exc)
+}
+
+// callSoonThreadsafe schedules fn(args...) on the loop. Errors are
+// discarded, e.g. if the loop is already closed.
+func (l *EventLoop) callSoonThreadsafe(fn PyObjectPtr, args ...PyObjectPtr) {
+	res := callMethod(l.loop, "call_soon_threadsafe", append([]PyObjectPtr{fn}, args...)...)
+	if res == NullPyObjectPtr {
+		PyErr_Clear()
+		return
+	}
+	Py_DecRef(res)
+}
+
+// Send resumes a generator or coroutine, sending it arg, which is None to
+// start it. If the generator yields, the yielded value is returned with done
+// set to false. If it returns, its return value is returned with done set
+// to true.
+//
+// This drives a coroutine without an event loop, so it only suits
+// coroutines that don't await asyncio futures.
+//
+// Returns a new reference. Requires the caller to hold the GIL.
+func Send(gen, arg PyObjectPtr) (value PyObjectPtr, done bool, err error) {
+	switch PyIter_Send(gen, arg, &value) {
+	case PyGen_Next:
+		return value, false, nil
+	case PyGen_Return:
+		return value, true, nil
+	}
+	return NullPyObjectPtr, true, FetchError()
+}
+
+// callInInterpreter runs fn on the current goroutine with the GIL held,
+// using a temporary thread state for the given interpreter.
+func callInInterpreter(interp PyInterpreterStatePtr, fn func()) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	ts := PyThreadState_New(interp)
+	PyEval_RestoreThread(ts)
+	fn()
+	PyThreadState_Clear(ts)
+	PyThreadState_DeleteCurrent()
+}
+
+// callMethod calls obj.name(*args), returning a new reference or
+// NullPyObjectPtr if Python raised an exception. The args are borrowed.
+func callMethod(obj PyObjectPtr, name string, args ...PyObjectPtr) PyObjectPtr {
+	method := PyObject_GetAttrString(obj, name)
+	if method == NullPyObjectPtr {
+		return NullPyObjectPtr
+	}
+	defer Py_DecRef(method)
+
+	tuple := newTuple(args...)
+	defer Py_DecRef(tuple)
+	return PyObject_CallObject(method, tuple)
+}
+
+// newTuple creates a tuple of the given borrowed references.
+func newTuple(items ...PyObjectPtr) PyObjectPtr {
+	tuple := PyTuple_New(int64(len(items)))
+	for i, item := range items {
+		// PyTuple_SetItem steals a reference.
+		Py_IncRef(item)
+		PyTuple_SetItem(tuple, int64(i), item)
+	}
+	return tuple
+}
+
+// newExceptionObject creates an instance of the exception type exc with the
+// given message.
+func newExceptionObject(exc PyObjectPtr, msg string) PyObjectPtr {
+	s, err := ToPython(msg)
+	if err != nil {
+		Py_IncRef(Py_None)
+		return Py_None
+	}
+	defer Py_DecRef(s)
+	obj := PyObject_CallOneArg(exc, s)
+	if obj == NullPyObjectPtr {
+		PyErr_Clear()
+		Py_IncRef(Py_None)
+		return Py_None
+	}
+	return obj
+}
+
+// compileHelper runs Python source in a fresh namespace and returns a new
+// reference to one of the names it defines.
+func compileHelper(src, name string) (PyObjectPtr, error) {
+	globals := PyDict_New()
+	defer Py_DecRef(globals)
+
+	builtins := PyImport_ImportModule("builtins")
+	if builtins == NullPyObjectPtr {
+		return NullPyObjectPtr, FetchError()
+	}
+	PyDict_SetItemString(globals, "__builtins__", builtins)
+	Py_DecRef(builtins)
+
+	res := PyRun_String(src, PyFileInput, globals, globals)
+	if res == NullPyObjectPtr {
+		return NullPyObjectPtr, FetchError()
+	}
+	Py_DecRef(res)
+
+	obj := PyDict_GetItemString(globals, name)
+	if obj == NullPyObjectPtr {
+		return NullPyObjectPtr, fmt.Errorf("helper did not define %s", name)
+	}
+	Py_IncRef(obj)
+	return obj, nil
+}
@@ -0,0 +1,198 @@
+package gogopython_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// newAsyncInterpreter creates an interpreter for asyncio. Python 3.12 can
+// crash finalizing after asyncio ran in an interpreter with its own GIL, so
+// it shares the main GIL.
+func newAsyncInterpreter(t *testing.T) (*gogopythontest.Interpreter, *py.EventLoop) {
+	t.Helper()
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{
+		Config: &py.PyInterpreterConfig{
+			UseMainObMalloc: 1,
+			AllowThreads:    1,
+			Gil:             py.SharedGil,
+		},
+		// Importing asyncio creates state that lives as long as the
+		// interpreter.
+		AllowLeaks: true,
+	})
+	loop, err := py.GetEventLoop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		// Tests may have replaced the loop, so close the current one.
+		current, err := py.GetEventLoop()
+		if err == nil {
+			err = current.Close()
+		}
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	gogopythontest.RequireExec(t, interp, `
+import asyncio
+
+async def add(a, b):
+    await asyncio.sleep(0)
+    return a + b
+
+async def fail():
+    raise ValueError("failed")
+
+async def wait(fut):
+    return await fut`)
+	return interp, loop
+}
+
+// run runs the coroutine returned by a Python expression on the loop.
+func run(t *testing.T, interp *gogopythontest.Interpreter, loop *py.EventLoop, ctx context.Context, expr string) (py.PyObjectPtr, error) {
+	t.Helper()
+	coro := gogopythontest.Eval(t, interp, expr)
+	return loop.Run(ctx, coro)
+}
+
+func TestEventLoopRun(t *testing.T) {
+	interp, loop := newAsyncInterpreter(t)
+
+	if again, err := py.GetEventLoop(); err != nil || again != loop {
+		t.Errorf("GetEventLoop() = %v, %v, want the existing loop", again, err)
+	}
+	gogopythontest.RequireEval(t, interp, "asyncio.get_event_loop() is not None", true)
+
+	res, err := run(t, interp, loop, context.Background(), "add(1, 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := py.PyLong_AsLong(res); n != 3 {
+		t.Errorf("add(1, 2) = %d", n)
+	}
+	py.Py_DecRef(res)
+
+	var exc *py.Exception
+	if _, err := run(t, interp, loop, context.Background(), "fail()"); !errors.As(err, &exc) || exc.Type != "ValueError" {
+		t.Errorf("fail() returned %v, want a ValueError", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := run(t, interp, loop, ctx, "asyncio.sleep(60)"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("a cancelled run returned %v, want the context's error", err)
+	}
+}
+
+func TestEventLoopFuture(t *testing.T) {
+	interp, loop := newAsyncInterpreter(t)
+
+	fut, err := loop.Future(func(ctx context.Context) (any, error) {
+		time.Sleep(10 * time.Millisecond)
+		return "done", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setGlobal(t, interp, "fut", fut)
+	res, err := run(t, interp, loop, context.Background(), "wait(fut)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := py.UnicodeToString(res); s != "done" {
+		t.Errorf("the future resolved to %q", s)
+	}
+	py.Py_DecRef(res)
+
+	fut, err = loop.Future(func(ctx context.Context) (any, error) {
+		return nil, errors.New("go failed")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setGlobal(t, interp, "fut", fut)
+	var exc *py.Exception
+	if _, err := run(t, interp, loop, context.Background(), "wait(fut)"); !errors.As(err, &exc) || exc.Type != "RuntimeError" || exc.Message != "go failed" {
+		t.Errorf("a failed future raised %v, want a RuntimeError", err)
+	}
+
+	// Cancelling the future cancels the context of its func.
+	cancelled := make(chan struct{})
+	fut, err = loop.Future(func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setGlobal(t, interp, "fut", fut)
+	gogopythontest.RequireExec(t, interp, "fut.cancel()")
+	if _, err := run(t, interp, loop, context.Background(), "asyncio.sleep(0)"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("the future's context wasn't cancelled")
+	}
+}
+
+func TestEventLoopClose(t *testing.T) {
+	_, loop := newAsyncInterpreter(t)
+
+	// Closing stops pending futures.
+	if _, err := loop.Future(func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loop.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loop.Future(func(context.Context) (any, error) { return nil, nil }); err == nil {
+		t.Error("Future succeeded on a closed loop")
+	}
+
+	// A new loop replaces the closed one.
+	if again, err := py.GetEventLoop(); err != nil || again == loop {
+		t.Errorf("GetEventLoop() = %v, %v, want a new loop", again, err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+	gogopythontest.RequireExec(t, interp, `
+def gen():
+    x = yield 1
+    return x * 2`)
+
+	g := gogopythontest.Eval(t, interp, "gen()")
+	value, done, err := py.Send(g, py.Py_None)
+	if err != nil || done || py.PyLong_AsLong(value) != 1 {
+		t.Fatalf("Send(None) = %v, %t, %v, want 1", value, done, err)
+	}
+	py.Py_DecRef(value)
+
+	arg := py.PyLong_FromLong(21)
+	defer py.Py_DecRef(arg)
+	value, done, err = py.Send(g, arg)
+	if err != nil || !done || py.PyLong_AsLong(value) != 42 {
+		t.Fatalf("Send(21) = %v, %t, %v, want a return of 42", value, done, err)
+	}
+	py.Py_DecRef(value)
+
+	// A finished generator keeps returning None.
+	value, done, err = py.Send(g, py.Py_None)
+	if err != nil || !done || value != py.Py_None {
+		t.Errorf("Send to a finished generator = %v, %t, %v, want a return of None", value, done, err)
+	}
+	py.Py_DecRef(value)
+}
@@ -0,0 +1,336 @@
+package gogopython
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ebitengine/purego"
+)
+
+// AuditAction is the decision an AuditPolicy makes for an audit event.
+type AuditAction int
+
+const (
+	AuditAllow AuditAction = iota // Allow the event.
+	AuditLog                      // Allow the event and log it.
+	AuditDeny                     // Raise a PermissionError in Python.
+)
+
+func (a AuditAction) String() string {
+	switch a {
+	case AuditAllow:
+		return "allow"
+	case AuditLog:
+		return "log"
+	case AuditDeny:
+		return "deny"
+	}
+	return fmt.Sprintf("AuditAction(%d)", int(a))
+}
+
+// AuditEvent is a Python audit event, as raised by sys.audit. See the Python
+// audit events table for the events and their arguments.
+type AuditEvent struct {
+	Interpreter int64  // Interpreter is the id of the interpreter raising the event.
+	Name        string // Name is the event name, e.g. "open" or "socket.connect".
+
+	// Args are the event arguments converted with FromPython into natural
+	// Go types. Other objects are replaced by their str().
+	Args []any
+}
+
+// AuditPolicy decides which audit events an interpreter may raise, acting as
+// a sandbox for semi-trusted scripts.
+//
+// For each event, the allowlists are checked first, then Rules, then Decide.
+// Events not matched by any of them are allowed.
+//
+// Audit hooks are a way to observe and veto operations, but are not a
+// security boundary on their own: native code can bypass them. Combine a
+// policy with an import policy that blocks modules like ctypes.
+type AuditPolicy struct {
+	// Rules sets the action for events by name, e.g. to deny
+	// "subprocess.Popen", "os.system" and "socket.connect".
+	Rules map[string]AuditAction
+
+	// Decide, if set, is called for events without a rule. It's called with
+	// the GIL held and must not call into Python.
+	Decide func(AuditEvent) AuditAction
+
+	// AllowedPaths are directories, or files, that "open" events may
+	// always access. Relative paths are resolved against the working
+	// directory, and symlinks in both these and the opened paths are
+	// resolved, so a link can't lead outside of them. Paths given as str,
+	// bytes or os.PathLike are checked; file descriptors aren't. Python
+	// also raises "open" events when reading modules, so when denying
+	// "open" include the directories on sys.path.
+	AllowedPaths []string
+
+	// AllowedModules are modules, including their submodules, that
+	// "import" events may always load.
+	AllowedModules []string
+
+	// Logger receives events with the AuditLog action. Defaults to
+	// slog.Default().
+	Logger *slog.Logger
+}
+
+var auditPolicies = struct {
+	sync.RWMutex
+	policies map[int64]*AuditPolicy
+}{policies: make(map[int64]*AuditPolicy)}
+
+// SetAuditPolicy applies a policy to the current interpreter, replacing any
+// previous one. A nil policy removes it, and it's forgotten when the
+// interpreter ends.
+//
+// The first call installs a process-wide audit hook with PySys_AddAuditHook.
+// Hooks can't be removed, but interpreters without a policy are only
+// affected by a map lookup per event.
+//
+// Requires the caller to hold the GIL.
+func SetAuditPolicy(policy *AuditPolicy) error {
+	id := PyInterpreterState_GetID(PyInterpreterState_Get())
+
+	if policy == nil {
+		auditPolicies.Lock()
+		delete(auditPolicies.policies, id)
+		auditPolicies.Unlock()
+		return nil
+	}
+	if err := installAuditHook(); err != nil {
+		return err
+	}
+
+	// Copy the policy, resolving the allowed paths up front.
+	p := *policy
+	p.AllowedPaths = make([]string, len(policy.AllowedPaths))
+	for i, path := range policy.AllowedPaths {
+		resolved, err := resolvePath(path)
+		if err != nil {
+			return err
+		}
+		p.AllowedPaths[i] = resolved
+	}
+	if p.Logger == nil {
+		p.Logger = slog.Default()
+	}
+
+	auditPolicies.RLock()
+	_, replacing := auditPolicies.policies[id]
+	auditPolicies.RUnlock()
+	if !replacing {
+		err := onInterpreterEnd(func() {
+			auditPolicies.Lock()
+			delete(auditPolicies.policies, id)
+			auditPolicies.Unlock()
+		})
+		if err != nil {
+			return err
+		}
+	}
+	auditPolicies.Lock()
+	auditPolicies.policies[id] = &p
+	auditPolicies.Unlock()
+	return nil
+}
+
+var auditHookOnce struct {
+	sync.Mutex
+	installed bool
+}
+
+// installAuditHook adds our process-wide audit hook, if not already added.
+// It enforces both audit and import policies.
+func installAuditHook() error {
+	auditHookOnce.Lock()
+	defer auditHookOnce.Unlock()
+	if auditHookOnce.installed {
+		return nil
+	}
+	if PySys_AddAuditHook(purego.NewCallback(auditHook), 0) != 0 {
+		if err := FetchError(); err != nil {
+			return err
+		}
+		return errors.New("failed to add audit hook")
+	}
+	auditHookOnce.installed = true
+	return nil
+}
+
+// auditHook is the process-wide audit hook, dispatching events to the
+// import and audit policies of the interpreter raising them.
+func auditHook(event *byte, args PyObjectPtr, _ uintptr) (result int32) {
+	defer func() {
+		if r := recover(); r != nil {
+			PyErr_SetString(PyExc_RuntimeError, fmt.Sprintf("audit hook panicked: %v", r))
+			result = -1
+		}
+	}()
+
+	id := PyInterpreterState_GetID(PyInterpreterState_Get())
+	name := cString(event)
+	switch {
+	case name == "import" && !checkImport(id, args):
+		return -1
+	case name == "exec" && !checkExec(id, args):
+		return -1
+	}
+
+	auditPolicies.RLock()
+	p, ok := auditPolicies.policies[id]
+	auditPolicies.RUnlock()
+	if !ok {
+		return 0
+	}
+
+	ev := AuditEvent{Interpreter: id, Name: name}
+	action, ok := p.allowed(ev.Name, args)
+	if !ok {
+		action, ok = p.Rules[ev.Name]
+		if !ok && p.Decide != nil {
+			ev.Args = auditArgs(args)
+			action = p.Decide(ev)
+		}
+	}
+
+	switch action {
+	case AuditLog:
+		if ev.Args == nil {
+			ev.Args = auditArgs(args)
+		}
+		p.Logger.Info("python audit event", "interpreter", id, "event", ev.Name, "args", ev.Args)
+	case AuditDeny:
+		PyErr_SetString(PyExc_PermissionError, fmt.Sprintf("%s denied by sandbox policy", ev.Name))
+		return -1
+	}
+	return 0
+}
+
+// allowed checks the allowlists, returning AuditAllow and true if the event
+// is covered by one.
+func (p *AuditPolicy) allowed(event string, args PyObjectPtr) (AuditAction, bool) {
+	switch {
+	case event == "open" && len(p.AllowedPaths) > 0:
+		// Args are (path, mode, flags), where path may be a file descriptor.
+		path, ok := pathArg(PyTuple_GetItem(args, 0))
+		if !ok {
+			return AuditAllow, false
+		}
+		resolved, err := resolvePath(path)
+		if err != nil {
+			return AuditAllow, false
+		}
+		for _, allowed := range p.AllowedPaths {
+			if resolved == allowed || strings.HasPrefix(resolved, allowed+string(filepath.Separator)) {
+				return AuditAllow, true
+			}
+		}
+
+	case event == "import" && len(p.AllowedModules) > 0:
+		// Args are (module, filename, sys.path, sys.meta_path, sys.path_hooks).
+		var module string
+		if FromPython(PyTuple_GetItem(args, 0), &module) != nil {
+			PyErr_Clear()
+			return AuditAllow, false
+		}
+		for _, allowed := range p.AllowedModules {
+			if module == allowed || strings.HasPrefix(module, allowed+".") {
+				return AuditAllow, true
+			}
+		}
+	}
+	return AuditAllow, false
+}
+
+// pathArg returns a path given as str, bytes or os.PathLike, or false for
+// other objects, e.g. file descriptors.
+func pathArg(obj PyObjectPtr) (string, bool) {
+	fspath := PyOS_FSPath(obj)
+	if fspath == NullPyObjectPtr {
+		PyErr_Clear()
+		return "", false
+	}
+	defer Py_DecRef(fspath)
+	var path string
+	if FromPython(fspath, &path) == nil {
+		return path, true
+	}
+	var b []byte
+	if FromPython(fspath, &b) == nil {
+		return string(b), true
+	}
+	PyErr_Clear()
+	return "", false
+}
+
+// resolvePath returns the absolute path with symlinks resolved. A missing
+// file, e.g. one being created, is resolved through its directory, unless
+// it's a dangling symlink.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return resolved, nil
+	}
+	if _, lerr := os.Lstat(abs); !errors.Is(lerr, fs.ErrNotExist) {
+		return "", err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(abs)), nil
+}
+
+// auditArgs converts the event arguments tuple into Go values.
+func auditArgs(args PyObjectPtr) []any {
+	n := PyTuple_Size(args)
+	values := make([]any, n)
+	for i := range n {
+		item := PyTuple_GetItem(args, i)
+		var v any
+		if err := FromPython(item, &v); err == nil && plainValue(v) {
+			values[i] = v
+			continue
+		}
+		values[i] = "<unknown>"
+		if str := PyObject_Str(item); str != NullPyObjectPtr {
+			values[i], _ = UnicodeToString(str)
+			Py_DecRef(str)
+		}
+		PyErr_Clear()
+	}
+	return values
+}
+
+// plainValue reports whether v holds no Python objects, which would only be
+// valid during the audit hook.
+func plainValue(v any) bool {
+	switch v := v.(type) {
+	case PyObjectPtr:
+		return false
+	case []any:
+		for _, item := range v {
+			if !plainValue(item) {
+				return false
+			}
+		}
+	case map[string]any:
+		for _, item := range v {
+			if !plainValue(item) {
+				return false
+			}
+		}
+	}
+	return true
+}
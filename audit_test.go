@@ -0,0 +1,147 @@
+package gogopython_test
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// sandboxDir returns a directory holding an allowed file, and a secret file
+// outside of it.
+func sandboxDir(t *testing.T) (allowed, secret string) {
+	t.Helper()
+	root := t.TempDir()
+	allowed = filepath.Join(root, "allowed")
+	secret = filepath.Join(root, "secret")
+	if err := os.Mkdir(allowed, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{filepath.Join(allowed, "file"), secret} {
+		if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return allowed, secret
+}
+
+func TestAuditPolicy(t *testing.T) {
+	// The policy keeps state in the interpreter's dict until it ends.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+	gogopythontest.RequireExec(t, interp, "import os, sys")
+	id := py.PyInterpreterState_GetID(interp.State())
+
+	var decided []py.AuditEvent
+	var logged strings.Builder
+	err := py.SetAuditPolicy(&py.AuditPolicy{
+		Rules: map[string]py.AuditAction{
+			"os.system":  py.AuditDeny,
+			"import":     py.AuditDeny,
+			"custom.log": py.AuditLog,
+		},
+		Decide: func(ev py.AuditEvent) py.AuditAction {
+			if !strings.HasPrefix(ev.Name, "custom.") {
+				return py.AuditAllow
+			}
+			decided = append(decided, ev)
+			if ev.Name == "custom.deny" {
+				return py.AuditDeny
+			}
+			return py.AuditAllow
+		},
+		AllowedModules: []string{"colorsys"},
+		Logger:         slog.New(slog.NewTextHandler(&logged, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer py.SetAuditPolicy(nil)
+
+	exc := gogopythontest.RequireRaises(t, interp, "os.system('true')", "PermissionError")
+	if exc.Message != "os.system denied by sandbox policy" {
+		t.Errorf("denied event raised %q", exc.Message)
+	}
+	gogopythontest.RequireRaises(t, interp, "import shlex", "PermissionError")
+	gogopythontest.RequireExec(t, interp, "import colorsys")
+
+	gogopythontest.RequireExec(t, interp, "sys.audit('custom.allow', 1, 'x', [2.5, None])")
+	gogopythontest.RequireRaises(t, interp, "sys.audit('custom.deny')", "PermissionError")
+	want := []py.AuditEvent{
+		{Interpreter: id, Name: "custom.allow", Args: []any{int64(1), "x", []any{2.5, nil}}},
+		{Interpreter: id, Name: "custom.deny", Args: []any{}},
+	}
+	if !reflect.DeepEqual(decided, want) {
+		t.Errorf("decided %+v, want %+v", decided, want)
+	}
+
+	// Objects without a Go equivalent are logged by their str().
+	gogopythontest.RequireExec(t, interp, "sys.audit('custom.log', 'arg', type('Obj', (), {'__str__': lambda self: 'obj'})())")
+	if out := logged.String(); !strings.Contains(out, "event=custom.log args=\"[arg obj]\"") {
+		t.Errorf("logged %q", out)
+	}
+
+	// Removing the policy allows everything again.
+	if err := py.SetAuditPolicy(nil); err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireExec(t, interp, "sys.audit('custom.deny')\nimport shlex")
+}
+
+func TestAuditActionString(t *testing.T) {
+	for action, want := range map[py.AuditAction]string{
+		py.AuditAllow:      "allow",
+		py.AuditLog:        "log",
+		py.AuditDeny:       "deny",
+		py.AuditAction(42): "AuditAction(42)",
+	} {
+		if got := action.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestAuditPolicySymlink(t *testing.T) {
+	allowed, secret := sandboxDir(t)
+	if err := os.Symlink(secret, filepath.Join(allowed, "link")); err != nil {
+		t.Fatal(err)
+	}
+	// The policy keeps state in the interpreter's dict until it ends.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+	err := py.SetAuditPolicy(&py.AuditPolicy{
+		Rules:        map[string]py.AuditAction{"open": py.AuditDeny},
+		AllowedPaths: []string{allowed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer py.SetAuditPolicy(nil)
+
+	gogopythontest.RequireEval(t, interp, fmt.Sprintf("open(%q).read()", filepath.Join(allowed, "file")), "data")
+	gogopythontest.RequireRaises(t, interp, fmt.Sprintf("open(%q)", filepath.Join(allowed, "link")), "PermissionError")
+}
+
+func TestAuditPolicyPathTypes(t *testing.T) {
+	allowed, secret := sandboxDir(t)
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+	gogopythontest.RequireExec(t, interp, "import pathlib")
+	err := py.SetAuditPolicy(&py.AuditPolicy{
+		Rules:        map[string]py.AuditAction{"open": py.AuditDeny},
+		AllowedPaths: []string{allowed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer py.SetAuditPolicy(nil)
+
+	file := filepath.Join(allowed, "file")
+	gogopythontest.RequireEval(t, interp, fmt.Sprintf("open(%q.encode()).read()", file), "data")
+	gogopythontest.RequireEval(t, interp, fmt.Sprintf("open(pathlib.Path(%q)).read()", file), "data")
+	gogopythontest.RequireRaises(t, interp, fmt.Sprintf("open(%q.encode())", secret), "PermissionError")
+	gogopythontest.RequireRaises(t, interp, fmt.Sprintf("open(pathlib.Path(%q))", secret), "PermissionError")
+}
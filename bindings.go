@@ -1,6 +1,10 @@
 package gogopython
 
-import "github.com/ebitengine/purego"
+import (
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+)
 
 var (
 	// Py_DecodeLocale converts a Go string into a Python *wchar_t, optionally
@@ -31,6 +35,10 @@ var (
 	// This can deadlock depending on the GIL state. It can also panic.
 	Py_FinalizeEx func() int32
 
+	// Py_IsInitialized reports whether the main interpreter is initialized,
+	// returning 1 if true, 0 if false.
+	Py_IsInitialized func() int32
+
 	// Py_EndInterpreter tears down a sub-interpreter using the provided
 	// thread state.
 	//
@@ -54,6 +62,20 @@ var (
 	PyEval_SaveThread    func() PyThreadStatePtr
 	PyEval_RestoreThread func(PyThreadStatePtr)
 
+	// PyEval_SetTrace sets the trace function of the current thread, as used
+	// by sys.settrace. Python holds a reference to obj, which is passed to
+	// every call of the Py_tracefunc.
+	PyEval_SetTrace func(fn PyTraceFunc, obj PyObjectPtr)
+	// PyEval_SetProfile sets the profile function of the current thread, as
+	// used by sys.setprofile.
+	PyEval_SetProfile func(fn PyTraceFunc, obj PyObjectPtr)
+	// PyEval_SetTraceAllThreads is like PyEval_SetTrace, but for all threads
+	// of the current interpreter.
+	PyEval_SetTraceAllThreads func(fn PyTraceFunc, obj PyObjectPtr)
+	// PyEval_SetProfileAllThreads is like PyEval_SetProfile, but for all
+	// threads of the current interpreter.
+	PyEval_SetProfileAllThreads func(fn PyTraceFunc, obj PyObjectPtr)
+
 	PyThreadState_Get            func() PyThreadStatePtr
 	PyThreadState_New            func(PyInterpreterStatePtr) PyThreadStatePtr
 	PyThreadState_Swap           func(PyThreadStatePtr) PyThreadStatePtr
@@ -62,8 +84,50 @@ var (
 	PyThreadState_DeleteCurrent  func()
 	PyThreadState_GetInterpreter func(PyThreadStatePtr) PyInterpreterStatePtr
 
-	PyInterpreterState_Get    func() PyInterpreterStatePtr
-	PyInterpreterState_GetID  func(PyInterpreterStatePtr) int64
+	// PyThreadState_GetUnchecked is like PyThreadState_Get, but returns
+	// NullThreadState instead of a fatal error if there's no current thread
+	// state. In Python 3.12 this is the private _PyThreadState_UncheckedGet.
+	PyThreadState_GetUnchecked func() PyThreadStatePtr
+
+	// Py_DumpTracebackThreads writes the tracebacks of the interpreter's
+	// threads to the file descriptor fd, like faulthandler. It doesn't need
+	// the GIL, reading the frames as they are, and returns an error message
+	// or nil. This is the private _Py_DumpTracebackThreads.
+	Py_DumpTracebackThreads func(fd int32, interp PyInterpreterStatePtr, current PyThreadStatePtr) *byte
+
+	// PyThreadState_GetFrame returns a new reference to the thread's current
+	// frame, or NullPyFrameObjectPtr if it isn't running Python code.
+	PyThreadState_GetFrame func(PyThreadStatePtr) PyFrameObjectPtr
+	PyThreadState_GetID    func(PyThreadStatePtr) uint64
+	// PyThreadState_Next returns the next thread state of the same
+	// interpreter, or NullThreadState after the last one.
+	PyThreadState_Next func(PyThreadStatePtr) PyThreadStatePtr
+	// PyThreadState_EnterTracing suspends the thread's trace and profile
+	// functions until PyThreadState_LeaveTracing.
+	PyThreadState_EnterTracing func(PyThreadStatePtr)
+	PyThreadState_LeaveTracing func(PyThreadStatePtr)
+
+	// PyFrame_GetCode returns a new reference to the frame's code object.
+	PyFrame_GetCode func(PyFrameObjectPtr) PyCodeObjectPtr
+	// PyFrame_GetBack returns a new reference to the calling frame, or
+	// NullPyFrameObjectPtr for the outermost frame.
+	PyFrame_GetBack       func(PyFrameObjectPtr) PyFrameObjectPtr
+	PyFrame_GetLineNumber func(PyFrameObjectPtr) int32
+
+	PyInterpreterState_Get   func() PyInterpreterStatePtr
+	PyInterpreterState_GetID func(PyInterpreterStatePtr) int64
+	// PyInterpreterState_GetDict returns a borrowed reference to the
+	// interpreter's dict for extensions' state, which Python code can't
+	// access. It's cleared when the interpreter ends.
+	PyInterpreterState_GetDict func(PyInterpreterStatePtr) PyObjectPtr
+	// PyInterpreterState_ThreadHead returns the first thread state of the
+	// interpreter, or NullThreadState if it has none.
+	PyInterpreterState_ThreadHead func(PyInterpreterStatePtr) PyThreadStatePtr
+	// PyInterpreterState_Head returns the first of all interpreters, and
+	// PyInterpreterState_Next the next one or NullInterpreterState after the
+	// last.
+	PyInterpreterState_Head   func() PyInterpreterStatePtr
+	PyInterpreterState_Next   func(PyInterpreterStatePtr) PyInterpreterStatePtr
 	PyInterpreterState_Clear  func(PyInterpreterStatePtr)
 	PyInterpreterState_Delete func(PyInterpreterStatePtr)
 
@@ -99,9 +163,22 @@ var (
 
 	PyEval_EvalCode func(co PyCodeObjectPtr, globals, locals PyObjectPtr) PyObjectPtr
 
+	// PyMarshal_WriteObjectToString serializes obj, such as a code object,
+	// into a bytes object using the given marshal format version.
+	PyMarshal_WriteObjectToString func(obj PyObjectPtr, version int32) PyObjectPtr
+	// PyMarshal_ReadObjectFromString deserializes an object written by
+	// PyMarshal_WriteObjectToString.
+	PyMarshal_ReadObjectFromString func(data *byte, size int64) PyObjectPtr
+
+	// Py_GetVersion returns the version of the Python library, including
+	// build information.
+	Py_GetVersion func() string
+
 	PyModule_New          func(string) PyObjectPtr
 	PyModule_GetDict      func(ptr PyObjectPtr) PyObjectPtr
 	PyModule_AddObjectRef func(module PyObjectPtr, name string, item PyObjectPtr) int32
+	PyModule_GetDef       func(module PyObjectPtr) *PyModuleDef
+	PyModuleDef_Init      func(def *PyModuleDef) PyObjectPtr
 
 	PyImport_AddModule                   func(name string) PyObjectPtr
 	PyImport_ExecCodeModule              func(name string, code PyCodeObjectPtr) PyObjectPtr
@@ -110,12 +187,29 @@ var (
 	PyImport_ImportModuleLevel           func(name string, globals, locals, fromList PyObjectPtr, level int32) PyObjectPtr
 	PyImport_ExecCodeModuleWithPathnames func(name string, code PyCodeObjectPtr, pathname, cPathname string) PyObjectPtr
 
+	// PyImport_AppendInittab adds a built-in module to the table used by
+	// the import system. This must be called before Python is initialized.
+	// Python keeps a pointer to name, so it must remain valid.
+	PyImport_AppendInittab func(name *byte, initfunc uintptr) int32
+
 	PyCFunction_NewEx func(def *PyMethodDef, self, module PyObjectPtr) PyObjectPtr
 
+	// PyInstanceMethod_New wraps a callable so it binds to instances like a
+	// Python function when set as a class attribute.
+	PyInstanceMethod_New func(fn PyObjectPtr) PyObjectPtr
+
+	// PyCapsule_New wraps an opaque, non-NULL pointer in a Python object. The
+	// name must remain valid for the lifetime of the capsule as Python does
+	// not copy it. The optional destructor is called when it's collected.
+	PyCapsule_New        func(pointer uintptr, name *byte, destructor PyCapsuleDestructor) PyObjectPtr
+	PyCapsule_GetPointer func(capsule PyObjectPtr, name *byte) uintptr
+	PyCapsule_IsValid    func(capsule PyObjectPtr, name *byte) int32
+	PyCapsule_GetName    func(capsule PyObjectPtr) *byte
+
 	PyBool_FromLong func(int64) PyObjectPtr
 
 	PyLong_AsLong               func(PyObjectPtr) int64
-	PyLong_AsLongAndOverflow    func(PyObjectPtr, *int64) int64
+	PyLong_AsLongAndOverflow    func(PyObjectPtr, *int32) int64
 	PyLong_AsUnsignedLong       func(PyObjectPtr) uint64
 	PyLong_FromLong             func(int64) PyObjectPtr
 	PyLong_FromUnsignedLong     func(uint64) PyObjectPtr
@@ -130,18 +224,20 @@ var (
 	PyTuple_SetItem func(tuple PyObjectPtr, pos int64, item PyObjectPtr) int32
 	PyTuple_Size    func(tuple PyObjectPtr) int64
 
-	PyList_New     func(PyObjectPtr) int32
+	PyList_New     func(size int64) PyObjectPtr
 	PyList_Size    func(PyObjectPtr) int64
 	PyList_GetItem func(PyObjectPtr, int64) PyObjectPtr
-	PyList_SetItem func(list PyObjectPtr, index int, item PyObjectPtr) int32
+	PyList_SetItem func(list PyObjectPtr, index int64, item PyObjectPtr) int32
 	PyList_Append  func(list, item PyObjectPtr) int32
-	PyList_Insert  func(list PyObjectPtr, index int, item PyObjectPtr) int32
+	PyList_Insert  func(list PyObjectPtr, index int64, item PyObjectPtr) int32
+
+	PySequence_List func(PyObjectPtr) PyObjectPtr
 
 	PyDict_New           func() PyObjectPtr
 	PyDictProxy_New      func(mapping PyObjectPtr) PyObjectPtr
 	PyDict_Clear         func(PyObjectPtr)
 	PyDict_SetItem       func(dict, key, val PyObjectPtr) int32
-	PyDict_SetItemString func(dict PyObjectPtr, key string, val PyObjectPtr) int64
+	PyDict_SetItemString func(dict PyObjectPtr, key string, val PyObjectPtr) int32
 	PyDict_GetItem       func(dict, key PyObjectPtr) PyObjectPtr
 	PyDict_GetItemString func(dict PyObjectPtr, key string) PyObjectPtr
 	PyDict_Keys          func(dict PyObjectPtr) PyObjectPtr
@@ -152,16 +248,32 @@ var (
 	PyIter_Next  func(iter PyObjectPtr) PyObjectPtr
 	PyIter_Send  func(iter, arg PyObjectPtr, result *PyObjectPtr) PySendResult
 
+	// PyObject_SelfIter is the address of the C function that implements
+	// __iter__ by returning self, for use as a tp_iter slot.
+	PyObject_SelfIter uintptr
+
 	PyFunction_GetCode func(fn PyObjectPtr) PyCodeObjectPtr
 
-	PyObject_Call             func(callable, args, kwargs PyObjectPtr) PyObjectPtr
-	PyObject_CallNoArgs       func(callable PyObjectPtr) PyObjectPtr
-	PyObject_CallOneArg       func(callable, args PyObjectPtr) PyObjectPtr
+	PyObject_Call       func(callable, args, kwargs PyObjectPtr) PyObjectPtr
+	PyObject_CallNoArgs func(callable PyObjectPtr) PyObjectPtr
+	PyObject_CallOneArg func(callable, args PyObjectPtr) PyObjectPtr
+	PyObject_CallObject func(callable, args PyObjectPtr) PyObjectPtr
+
+	// PyObject_VectorcallMethod calls the method named name of args[0] with
+	// the remaining nargsf-1 args and the keyword args named by the kwnames
+	// tuple, which follow them in args.
+	PyObject_VectorcallMethod func(name PyObjectPtr, args *PyObjectPtr, nargsf uint64, kwnames PyObjectPtr) PyObjectPtr
+	// PyObject_CallMethodNoArgs calls obj.name(), and
+	// PyObject_CallMethodOneArg obj.name(arg). These are inline functions
+	// in the headers, implemented with PyObject_VectorcallMethod.
 	PyObject_CallMethodNoArgs func(obj, name PyObjectPtr) PyObjectPtr
 	PyObject_CallMethodOneArg func(obj, name, arg PyObjectPtr) PyObjectPtr
-	PyObject_CallObject       func(callable, args PyObjectPtr) PyObjectPtr
-	PyObject_IsInstance       func(inst, cls PyObjectPtr) int32
-	PyObject_GetAttrString    func(obj PyObjectPtr, name string) PyObjectPtr
+
+	PyObject_IsInstance    func(inst, cls PyObjectPtr) int32
+	PyObject_GetAttrString func(obj PyObjectPtr, name string) PyObjectPtr
+	PyObject_SetAttrString func(obj PyObjectPtr, name string, value PyObjectPtr) int32
+	PyObject_GetIter       func(obj PyObjectPtr) PyObjectPtr
+	PyObject_Str           func(obj PyObjectPtr) PyObjectPtr
 
 	PySet_New       func(iterable PyObjectPtr) PyObjectPtr
 	PyFrozenSet_New func(iterable PyObjectPtr) PyObjectPtr
@@ -169,7 +281,7 @@ var (
 	PySet_Contains  func(set, key PyObjectPtr) int32
 	PySet_Add       func(set, key PyObjectPtr) int32
 	PySet_Discard   func(set, key PyObjectPtr) int32
-	PySet_Pop       func(set, key PyObjectPtr) PyObjectPtr
+	PySet_Pop       func(set PyObjectPtr) PyObjectPtr
 	PySet_Clear     func(set PyObjectPtr) int32
 
 	PyBytes_FromString            func(string) PyObjectPtr
@@ -178,22 +290,85 @@ var (
 	PyBytes_AsString              func(PyObjectPtr) *byte
 	PyBytes_Size                  func(PyObjectPtr) int64
 
-	PyUnicode_FromString       func(string) PyObjectPtr
-	PyUnicode_AsEncodedString  func(unicode PyObjectPtr, encoding string, errors EncodingErrors) PyObjectPtr
-	PyUnicode_AsWideCharString func(PyObjectPtr, *int) WCharPtr
-	PyUnicode_DecodeFSDefault  func(string) PyObjectPtr
-	PyUnicode_EncodeFSDefault  func(PyObjectPtr) PyObjectPtr
+	PyUnicode_FromString        func(string) PyObjectPtr
+	PyUnicode_FromStringAndSize func(*byte, int64) PyObjectPtr
+	PyUnicode_AsEncodedString   func(unicode PyObjectPtr, encoding string, errors EncodingErrors) PyObjectPtr
+	PyUnicode_AsWideCharString  func(PyObjectPtr, *int) WCharPtr
+	PyUnicode_DecodeFSDefault   func(string) PyObjectPtr
+	PyUnicode_EncodeFSDefault   func(PyObjectPtr) PyObjectPtr
+
+	// PyOS_FSPath returns the str or bytes of a path, calling __fspath__ for
+	// os.PathLike objects, like os.fspath.
+	PyOS_FSPath func(path PyObjectPtr) PyObjectPtr
 
 	Py_DecRef func(PyObjectPtr)
 	Py_IncRef func(PyObjectPtr)
 
-	PyErr_Clear func()
-	PyErr_Print func()
+	PyErr_Clear     func()
+	PyErr_Print     func()
+	PyErr_Occurred  func() PyObjectPtr
+	PyErr_SetString func(exception PyObjectPtr, msg string)
+
+	// PyErr_GetRaisedException returns the exception currently being raised,
+	// clearing the error indicator. Returns NullPyObjectPtr if there is none.
+	PyErr_GetRaisedException func() PyObjectPtr
+	// PyErr_SetRaisedException sets the exception being raised, stealing a
+	// reference to it.
+	PyErr_SetRaisedException func(exc PyObjectPtr)
 
 	PyMem_Free func(*byte)
+	// PyMem_RawFree frees memory allocated by the raw domain, such as the
+	// strings returned by Py_DecodeLocale.
+	PyMem_RawFree func(*byte)
+
+	// PyMem_GetAllocator copies the allocator currently used by a domain.
+	PyMem_GetAllocator func(domain PyMemAllocatorDomain, allocator *PyMemAllocatorEx)
+	// PyMem_SetAllocator sets the allocator used by a domain.
+	PyMem_SetAllocator func(domain PyMemAllocatorDomain, allocator *PyMemAllocatorEx)
+
+	// PySys_AddAuditHook adds a hook called for every audit event raised by
+	// any interpreter. Hooks can't be removed. The hook is a C function
+	// int hook(const char *event, PyObject *args, void *userData).
+	PySys_AddAuditHook func(hook uintptr, userData uintptr) int32
+
+	PyObject_Free func(PyObjectPtr)
 
 	PyObject_Type   func(PyObjectPtr) PyTypeObjectPtr
 	PyType_GetFlags func(PyTypeObjectPtr) uint64
+
+	// PyType_FromSpec creates a new heap type from the provided spec.
+	PyType_FromSpec func(spec *PyTypeSpec) PyObjectPtr
+	// PyType_FromModuleAndSpec is like PyType_FromSpec, but associates the
+	// new type with a module and optionally a tuple of base types.
+	PyType_FromModuleAndSpec func(module PyObjectPtr, spec *PyTypeSpec, bases PyObjectPtr) PyObjectPtr
+	PyType_GenericAlloc      func(tp PyTypeObjectPtr, nitems int64) PyObjectPtr
+)
+
+// Python singletons and built-in exception types. These are exported as
+// variables by the Python library and are resolved when it's loaded.
+//
+// All of these are borrowed references.
+var (
+	Py_None  PyObjectPtr
+	Py_True  PyObjectPtr
+	Py_False PyObjectPtr
+
+	PyExc_BaseException       PyObjectPtr
+	PyExc_Exception           PyObjectPtr
+	PyExc_AttributeError      PyObjectPtr
+	PyExc_ImportError         PyObjectPtr
+	PyExc_IndexError          PyObjectPtr
+	PyExc_KeyError            PyObjectPtr
+	PyExc_MemoryError         PyObjectPtr
+	PyExc_ModuleNotFoundError PyObjectPtr
+	PyExc_NotImplementedError PyObjectPtr
+	PyExc_OverflowError       PyObjectPtr
+	PyExc_PermissionError     PyObjectPtr
+	PyExc_RuntimeError        PyObjectPtr
+	PyExc_StopIteration       PyObjectPtr
+	PyExc_SyntaxError         PyObjectPtr
+	PyExc_TypeError           PyObjectPtr
+	PyExc_ValueError          PyObjectPtr
 )
 
 // Our problem children. These all return PyStatus, a struct. These need
@@ -217,6 +392,7 @@ func registerFuncs(lib PythonLibraryPtr) {
 	purego.RegisterLibFunc(&PyConfig_Clear, lib, "PyConfig_Clear")
 
 	purego.RegisterLibFunc(&Py_FinalizeEx, lib, "Py_FinalizeEx")
+	purego.RegisterLibFunc(&Py_IsInitialized, lib, "Py_IsInitialized")
 
 	purego.RegisterLibFunc(&Py_EndInterpreter, lib, "Py_EndInterpreter")
 
@@ -228,6 +404,10 @@ func registerFuncs(lib PythonLibraryPtr) {
 	purego.RegisterLibFunc(&PyEval_ReleaseThread, lib, "PyEval_ReleaseThread")
 	purego.RegisterLibFunc(&PyEval_SaveThread, lib, "PyEval_SaveThread")
 	purego.RegisterLibFunc(&PyEval_RestoreThread, lib, "PyEval_RestoreThread")
+	purego.RegisterLibFunc(&PyEval_SetTrace, lib, "PyEval_SetTrace")
+	purego.RegisterLibFunc(&PyEval_SetProfile, lib, "PyEval_SetProfile")
+	purego.RegisterLibFunc(&PyEval_SetTraceAllThreads, lib, "PyEval_SetTraceAllThreads")
+	purego.RegisterLibFunc(&PyEval_SetProfileAllThreads, lib, "PyEval_SetProfileAllThreads")
 
 	purego.RegisterLibFunc(&PyThreadState_Get, lib, "PyThreadState_Get")
 	purego.RegisterLibFunc(&PyThreadState_New, lib, "PyThreadState_New")
@@ -236,9 +416,24 @@ func registerFuncs(lib PythonLibraryPtr) {
 	purego.RegisterLibFunc(&PyThreadState_Delete, lib, "PyThreadState_Delete")
 	purego.RegisterLibFunc(&PyThreadState_DeleteCurrent, lib, "PyThreadState_DeleteCurrent")
 	purego.RegisterLibFunc(&PyThreadState_GetInterpreter, lib, "PyThreadState_GetInterpreter")
+	purego.RegisterLibFunc(&PyThreadState_GetUnchecked, lib, "_PyThreadState_UncheckedGet")
+	purego.RegisterLibFunc(&Py_DumpTracebackThreads, lib, "_Py_DumpTracebackThreads")
+	purego.RegisterLibFunc(&PyThreadState_GetFrame, lib, "PyThreadState_GetFrame")
+	purego.RegisterLibFunc(&PyThreadState_GetID, lib, "PyThreadState_GetID")
+	purego.RegisterLibFunc(&PyThreadState_Next, lib, "PyThreadState_Next")
+	purego.RegisterLibFunc(&PyThreadState_EnterTracing, lib, "PyThreadState_EnterTracing")
+	purego.RegisterLibFunc(&PyThreadState_LeaveTracing, lib, "PyThreadState_LeaveTracing")
+
+	purego.RegisterLibFunc(&PyFrame_GetCode, lib, "PyFrame_GetCode")
+	purego.RegisterLibFunc(&PyFrame_GetBack, lib, "PyFrame_GetBack")
+	purego.RegisterLibFunc(&PyFrame_GetLineNumber, lib, "PyFrame_GetLineNumber")
 
 	purego.RegisterLibFunc(&PyInterpreterState_Get, lib, "PyInterpreterState_Get")
 	purego.RegisterLibFunc(&PyInterpreterState_GetID, lib, "PyInterpreterState_GetID")
+	purego.RegisterLibFunc(&PyInterpreterState_GetDict, lib, "PyInterpreterState_GetDict")
+	purego.RegisterLibFunc(&PyInterpreterState_ThreadHead, lib, "PyInterpreterState_ThreadHead")
+	purego.RegisterLibFunc(&PyInterpreterState_Head, lib, "PyInterpreterState_Head")
+	purego.RegisterLibFunc(&PyInterpreterState_Next, lib, "PyInterpreterState_Next")
 	purego.RegisterLibFunc(&PyInterpreterState_Clear, lib, "PyInterpreterState_Clear")
 	purego.RegisterLibFunc(&PyInterpreterState_Delete, lib, "PyInterpreterState_Delete")
 
@@ -251,9 +446,16 @@ func registerFuncs(lib PythonLibraryPtr) {
 
 	purego.RegisterLibFunc(&PyEval_EvalCode, lib, "PyEval_EvalCode")
 
+	purego.RegisterLibFunc(&PyMarshal_WriteObjectToString, lib, "PyMarshal_WriteObjectToString")
+	purego.RegisterLibFunc(&PyMarshal_ReadObjectFromString, lib, "PyMarshal_ReadObjectFromString")
+
+	purego.RegisterLibFunc(&Py_GetVersion, lib, "Py_GetVersion")
+
 	purego.RegisterLibFunc(&PyModule_New, lib, "PyModule_New")
 	purego.RegisterLibFunc(&PyModule_GetDict, lib, "PyModule_GetDict")
 	purego.RegisterLibFunc(&PyModule_AddObjectRef, lib, "PyModule_AddObjectRef")
+	purego.RegisterLibFunc(&PyModule_GetDef, lib, "PyModule_GetDef")
+	purego.RegisterLibFunc(&PyModuleDef_Init, lib, "PyModuleDef_Init")
 
 	purego.RegisterLibFunc(&PyImport_AddModule, lib, "PyImport_AddModule")
 	purego.RegisterLibFunc(&PyImport_GetModuleDict, lib, "PyImport_GetModuleDict")
@@ -261,8 +463,15 @@ func registerFuncs(lib PythonLibraryPtr) {
 	purego.RegisterLibFunc(&PyImport_ImportModuleLevel, lib, "PyImport_ImportModuleLevel")
 	purego.RegisterLibFunc(&PyImport_ExecCodeModule, lib, "PyImport_ExecCodeModule")
 	purego.RegisterLibFunc(&PyImport_ExecCodeModuleWithPathnames, lib, "PyImport_ExecCodeModuleWithPathnames")
+	purego.RegisterLibFunc(&PyImport_AppendInittab, lib, "PyImport_AppendInittab")
 
 	purego.RegisterLibFunc(&PyCFunction_NewEx, lib, "PyCFunction_NewEx")
+	purego.RegisterLibFunc(&PyInstanceMethod_New, lib, "PyInstanceMethod_New")
+
+	purego.RegisterLibFunc(&PyCapsule_New, lib, "PyCapsule_New")
+	purego.RegisterLibFunc(&PyCapsule_GetPointer, lib, "PyCapsule_GetPointer")
+	purego.RegisterLibFunc(&PyCapsule_IsValid, lib, "PyCapsule_IsValid")
+	purego.RegisterLibFunc(&PyCapsule_GetName, lib, "PyCapsule_GetName")
 
 	// ==== Data types
 	purego.RegisterLibFunc(&PyBool_FromLong, lib, "PyBool_FromLong")
@@ -290,6 +499,8 @@ func registerFuncs(lib PythonLibraryPtr) {
 	purego.RegisterLibFunc(&PyList_Append, lib, "PyList_Append")
 	purego.RegisterLibFunc(&PyList_Insert, lib, "PyList_Insert")
 
+	purego.RegisterLibFunc(&PySequence_List, lib, "PySequence_List")
+
 	purego.RegisterLibFunc(&PyDict_New, lib, "PyDict_New")
 	purego.RegisterLibFunc(&PyDictProxy_New, lib, "PyDictProxy_New")
 	purego.RegisterLibFunc(&PyDict_Clear, lib, "PyDict_Clear")
@@ -304,6 +515,7 @@ func registerFuncs(lib PythonLibraryPtr) {
 	purego.RegisterLibFunc(&PyIter_Check, lib, "PyIter_Check")
 	purego.RegisterLibFunc(&PyIter_Next, lib, "PyIter_Next")
 	purego.RegisterLibFunc(&PyIter_Send, lib, "PyIter_Send")
+	PyObject_SelfIter = registerLibSymbol(lib, "PyObject_SelfIter")
 
 	purego.RegisterLibFunc(&PyFunction_GetCode, lib, "PyFunction_GetCode")
 
@@ -311,10 +523,19 @@ func registerFuncs(lib PythonLibraryPtr) {
 	purego.RegisterLibFunc(&PyObject_CallOneArg, lib, "PyObject_CallOneArg")
 	purego.RegisterLibFunc(&PyObject_CallNoArgs, lib, "PyObject_CallNoArgs")
 	purego.RegisterLibFunc(&PyObject_CallObject, lib, "PyObject_CallObject")
-	purego.RegisterLibFunc(&PyObject_CallMethodOneArg, lib, "PyObject_CallOneArg")
-	purego.RegisterLibFunc(&PyObject_CallMethodNoArgs, lib, "PyObject_CallNoArgs")
+	purego.RegisterLibFunc(&PyObject_VectorcallMethod, lib, "PyObject_VectorcallMethod")
+	PyObject_CallMethodNoArgs = func(obj, name PyObjectPtr) PyObjectPtr {
+		return PyObject_VectorcallMethod(name, &obj, 1, NullPyObjectPtr)
+	}
+	PyObject_CallMethodOneArg = func(obj, name, arg PyObjectPtr) PyObjectPtr {
+		args := [2]PyObjectPtr{obj, arg}
+		return PyObject_VectorcallMethod(name, &args[0], 2, NullPyObjectPtr)
+	}
 	purego.RegisterLibFunc(&PyObject_IsInstance, lib, "PyObject_IsInstance")
 	purego.RegisterLibFunc(&PyObject_GetAttrString, lib, "PyObject_GetAttrString")
+	purego.RegisterLibFunc(&PyObject_SetAttrString, lib, "PyObject_SetAttrString")
+	purego.RegisterLibFunc(&PyObject_GetIter, lib, "PyObject_GetIter")
+	purego.RegisterLibFunc(&PyObject_Str, lib, "PyObject_Str")
 
 	purego.RegisterLibFunc(&PySet_New, lib, "PySet_New")
 	purego.RegisterLibFunc(&PyFrozenSet_New, lib, "PyFrozenSet_New")
@@ -332,23 +553,88 @@ func registerFuncs(lib PythonLibraryPtr) {
 	purego.RegisterLibFunc(&PyBytes_Size, lib, "PyBytes_Size")
 
 	purego.RegisterLibFunc(&PyUnicode_FromString, lib, "PyUnicode_FromString")
+	purego.RegisterLibFunc(&PyUnicode_FromStringAndSize, lib, "PyUnicode_FromStringAndSize")
 	purego.RegisterLibFunc(&PyUnicode_AsEncodedString, lib, "PyUnicode_AsEncodedString")
 	purego.RegisterLibFunc(&PyUnicode_AsWideCharString, lib, "PyUnicode_AsWideCharString")
 	purego.RegisterLibFunc(&PyUnicode_DecodeFSDefault, lib, "PyUnicode_DecodeFSDefault")
 	purego.RegisterLibFunc(&PyUnicode_EncodeFSDefault, lib, "PyUnicode_EncodeFSDefault")
+	purego.RegisterLibFunc(&PyOS_FSPath, lib, "PyOS_FSPath")
 
 	purego.RegisterLibFunc(&Py_DecRef, lib, "Py_DecRef")
 	purego.RegisterLibFunc(&Py_IncRef, lib, "Py_IncRef")
 
 	purego.RegisterLibFunc(&PyErr_Clear, lib, "PyErr_Clear")
 	purego.RegisterLibFunc(&PyErr_Print, lib, "PyErr_Print")
+	purego.RegisterLibFunc(&PyErr_Occurred, lib, "PyErr_Occurred")
+	purego.RegisterLibFunc(&PyErr_SetString, lib, "PyErr_SetString")
+	purego.RegisterLibFunc(&PyErr_GetRaisedException, lib, "PyErr_GetRaisedException")
+	purego.RegisterLibFunc(&PyErr_SetRaisedException, lib, "PyErr_SetRaisedException")
 
 	purego.RegisterLibFunc(&PyMem_Free, lib, "PyMem_Free")
+	purego.RegisterLibFunc(&PyMem_RawFree, lib, "PyMem_RawFree")
+	purego.RegisterLibFunc(&PyMem_GetAllocator, lib, "PyMem_GetAllocator")
+	purego.RegisterLibFunc(&PyMem_SetAllocator, lib, "PyMem_SetAllocator")
+
+	purego.RegisterLibFunc(&PySys_AddAuditHook, lib, "PySys_AddAuditHook")
+
+	purego.RegisterLibFunc(&PyObject_Free, lib, "PyObject_Free")
 
 	purego.RegisterLibFunc(&PyObject_Type, lib, "PyObject_Type")
 	purego.RegisterLibFunc(&PyType_GetFlags, lib, "PyType_GetFlags")
+	purego.RegisterLibFunc(&PyType_FromSpec, lib, "PyType_FromSpec")
+	purego.RegisterLibFunc(&PyType_FromModuleAndSpec, lib, "PyType_FromModuleAndSpec")
+	purego.RegisterLibFunc(&PyType_GenericAlloc, lib, "PyType_GenericAlloc")
+
+	// ==== Singletons and exceptions
+	// The singletons are statically allocated objects, so the symbol is the
+	// object itself. The exceptions are pointers to the type objects.
+	Py_None = registerLibObject(lib, "_Py_NoneStruct")
+	Py_True = registerLibObject(lib, "_Py_TrueStruct")
+	Py_False = registerLibObject(lib, "_Py_FalseStruct")
+
+	registerLibVar(&PyExc_BaseException, lib, "PyExc_BaseException")
+	registerLibVar(&PyExc_Exception, lib, "PyExc_Exception")
+	registerLibVar(&PyExc_AttributeError, lib, "PyExc_AttributeError")
+	registerLibVar(&PyExc_ImportError, lib, "PyExc_ImportError")
+	registerLibVar(&PyExc_IndexError, lib, "PyExc_IndexError")
+	registerLibVar(&PyExc_KeyError, lib, "PyExc_KeyError")
+	registerLibVar(&PyExc_MemoryError, lib, "PyExc_MemoryError")
+	registerLibVar(&PyExc_ModuleNotFoundError, lib, "PyExc_ModuleNotFoundError")
+	registerLibVar(&PyExc_NotImplementedError, lib, "PyExc_NotImplementedError")
+	registerLibVar(&PyExc_OverflowError, lib, "PyExc_OverflowError")
+	registerLibVar(&PyExc_PermissionError, lib, "PyExc_PermissionError")
+	registerLibVar(&PyExc_RuntimeError, lib, "PyExc_RuntimeError")
+	registerLibVar(&PyExc_StopIteration, lib, "PyExc_StopIteration")
+	registerLibVar(&PyExc_SyntaxError, lib, "PyExc_SyntaxError")
+	registerLibVar(&PyExc_TypeError, lib, "PyExc_TypeError")
+	registerLibVar(&PyExc_ValueError, lib, "PyExc_ValueError")
 
 	// For the functions that return structs, we need to use some platform
 	// dependent approaches.
 	registerFuncsPlatDependent(lib)
 }
+
+// registerLibObject resolves the address of a statically allocated Python
+// object exported by the library.
+func registerLibObject(lib PythonLibraryPtr, name string) PyObjectPtr {
+	return PyObjectPtr(registerLibSymbol(lib, name))
+}
+
+// registerLibSymbol resolves the address of a symbol exported by the library.
+func registerLibSymbol(lib PythonLibraryPtr, name string) uintptr {
+	sym, err := purego.Dlsym(lib, name)
+	if err != nil {
+		panic(err)
+	}
+	return sym
+}
+
+// registerLibVar resolves a PyObject* variable exported by the library and
+// stores its current value in ptr.
+func registerLibVar(ptr *PyObjectPtr, lib PythonLibraryPtr, name string) {
+	sym, err := purego.Dlsym(lib, name)
+	if err != nil {
+		panic(err)
+	}
+	*ptr = **(**PyObjectPtr)(unsafe.Pointer(&sym))
+}
@@ -0,0 +1,1132 @@
+package gogopython_test
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"unsafe"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// Built-in module registered before Python is initialized.
+var inittabModule *py.NativeModule
+
+func TestMain(m *testing.M) {
+	os.Exit(gogopythontest.MainWithSetup(m, func() error {
+		var err error
+		inittabModule, err = py.NewModuleBuilder("_bindingtest").Const("answer", 42).Build()
+		if err != nil {
+			return err
+		}
+		if os.Getenv(trackMemoryEnv) != "" {
+			py.TrackMemory()
+		}
+		return inittabModule.AppendInittab()
+	}))
+}
+
+// TestBindingSymbols checks that every binding in bindings.go is registered
+// against the symbol of the same name.
+func TestBindingSymbols(t *testing.T) {
+	fset := token.NewFileSet()
+	bindings, err := parser.ParseFile(fset, "bindings.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Symbols registered with purego, which must match the binding's name
+	// except for private functions bound under their public style name.
+	aliases := map[string]string{
+		"PyConfig_InitIsolatedPythonConfig": "PyConfig_InitIsolatedConfig",
+		"PyThreadState_GetUnchecked":        "_PyThreadState_UncheckedGet",
+		"Py_DumpTracebackThreads":           "_Py_DumpTracebackThreads",
+	}
+	ast.Inspect(bindings, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 3 {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); !ok || sel.Sel.Name != "RegisterLibFunc" {
+			return true
+		}
+		name := call.Args[0].(*ast.UnaryExpr).X.(*ast.Ident).Name
+		symbol := strings.Trim(call.Args[2].(*ast.BasicLit).Value, `"`)
+		want := name
+		if alias, ok := aliases[name]; ok {
+			want = alias
+		}
+		if symbol != want {
+			t.Errorf("%s is registered against %s", name, symbol)
+		}
+		return true
+	})
+}
+
+func TestVersion(t *testing.T) {
+	gogopythontest.SkipWithoutPython(t)
+	if v := py.Py_GetVersion(); !strings.HasPrefix(v, "3.12") {
+		t.Errorf("Py_GetVersion() = %q, want 3.12", v)
+	}
+	if py.Py_IsInitialized() != 1 {
+		t.Error("Py_IsInitialized() = 0")
+	}
+}
+
+func TestConfig(t *testing.T) {
+	// PyMem_Free needs an interpreter.
+	gogopythontest.NewInterpreter(t)
+
+	var pre py.PyPreConfig
+	py.PyPreConfig_InitIsolatedConfig(&pre)
+	if pre.Isolated != 1 {
+		t.Error("isolated pre-config isn't isolated")
+	}
+	// Python is already pre-initialized, so this is a no-op.
+	if status := py.Py_PreInitialize(&pre); status.Type != 0 {
+		t.Errorf("Py_PreInitialize failed with status %d", status.Type)
+	}
+
+	var config py.PyConfig_3_12
+	py.PyConfig_InitPythonConfig(&config)
+	if config.Isolated != 0 {
+		t.Error("python config is isolated")
+	}
+	py.PyConfig_Clear(&config)
+
+	py.PyConfig_InitIsolatedPythonConfig(&config)
+	defer py.PyConfig_Clear(&config)
+	if config.Isolated != 1 {
+		t.Error("isolated config isn't isolated")
+	}
+	if status := py.PyConfig_SetBytesString(&config, &config.Home, "/opt/python"); status.Type != 0 {
+		t.Fatalf("PyConfig_SetBytesString failed with status %d", status.Type)
+	}
+	if home, err := py.WCharToString(config.Home); err != nil || home != "/opt/python" {
+		t.Errorf("home = %q, %v", home, err)
+	}
+}
+
+func TestLocale(t *testing.T) {
+	gogopythontest.NewInterpreter(t)
+
+	w := py.Py_DecodeLocale("hello", nil)
+	if w == nil {
+		t.Fatal("Py_DecodeLocale failed")
+	}
+	defer py.PyMem_RawFree(w)
+
+	s := py.Py_EncodeLocale(w, nil)
+	if s == nil {
+		t.Fatal("Py_EncodeLocale failed")
+	}
+	defer py.PyMem_Free(s)
+	if got := unsafe.String(s, 5); got != "hello" {
+		t.Errorf("round trip = %q", got)
+	}
+}
+
+func TestGIL(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+	ts := interp.ThreadState()
+
+	if py.PyGILState_Check() != 1 {
+		t.Error("PyGILState_Check() = 0 with the GIL held")
+	}
+	state := py.PyGILState_Ensure()
+	py.PyGILState_Release(state)
+
+	if saved := py.PyEval_SaveThread(); saved != ts {
+		t.Errorf("PyEval_SaveThread() = %x, want %x", saved, ts)
+	}
+	if py.PyThreadState_GetUnchecked() != py.NullThreadState {
+		t.Error("thread state still current after PyEval_SaveThread")
+	}
+	py.PyEval_RestoreThread(ts)
+
+	py.PyEval_ReleaseThread(ts)
+	py.PyEval_AcquireThread(ts)
+	if py.PyThreadState_Get() != ts {
+		t.Error("thread state not restored")
+	}
+}
+
+func TestThreadState(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+	ts, state := interp.ThreadState(), interp.State()
+
+	if got := py.PyThreadState_Get(); got != ts {
+		t.Errorf("PyThreadState_Get() = %x, want %x", got, ts)
+	}
+	if got := py.PyThreadState_GetUnchecked(); got != ts {
+		t.Errorf("PyThreadState_GetUnchecked() = %x, want %x", got, ts)
+	}
+	if got := py.PyThreadState_GetInterpreter(ts); got != state {
+		t.Errorf("PyThreadState_GetInterpreter() = %x, want %x", got, state)
+	}
+	if got := py.PyInterpreterState_Get(); got != state {
+		t.Errorf("PyInterpreterState_Get() = %x, want %x", got, state)
+	}
+	if py.PyInterpreterState_GetID(state) <= 0 {
+		t.Error("sub-interpreter has the main interpreter's id")
+	}
+	if dict := py.PyInterpreterState_GetDict(state); py.TypeName(dict) != "dict" {
+		t.Errorf("PyInterpreterState_GetDict() returned a %s", py.TypeName(dict))
+	}
+	if frame := py.PyThreadState_GetFrame(ts); frame != py.NullPyFrameObjectPtr {
+		t.Error("PyThreadState_GetFrame() returned a frame outside Python code")
+	}
+
+	var interps []py.PyInterpreterStatePtr
+	for i := py.PyInterpreterState_Head(); i != py.NullInterpreterState; i = py.PyInterpreterState_Next(i) {
+		interps = append(interps, i)
+	}
+	if !slices.Contains(interps, state) {
+		t.Error("interpreter not found with PyInterpreterState_Head and PyInterpreterState_Next")
+	}
+
+	other := py.PyThreadState_New(state)
+	if py.PyThreadState_GetID(other) == py.PyThreadState_GetID(ts) {
+		t.Error("thread states have the same id")
+	}
+	var threads []py.PyThreadStatePtr
+	for th := py.PyInterpreterState_ThreadHead(state); th != py.NullThreadState; th = py.PyThreadState_Next(th) {
+		threads = append(threads, th)
+	}
+	if !slices.Contains(threads, ts) || !slices.Contains(threads, other) {
+		t.Error("thread states not found with PyInterpreterState_ThreadHead and PyThreadState_Next")
+	}
+
+	if prev := py.PyThreadState_Swap(other); prev != ts {
+		t.Errorf("PyThreadState_Swap() = %x, want %x", prev, ts)
+	}
+	if prev := py.PyThreadState_Swap(ts); prev != other {
+		t.Errorf("PyThreadState_Swap() = %x, want %x", prev, other)
+	}
+	py.PyThreadState_Clear(other)
+	py.PyThreadState_Delete(other)
+
+	// Deleting the current thread state releases the GIL.
+	other = py.PyThreadState_New(state)
+	py.PyThreadState_Swap(other)
+	py.PyThreadState_Clear(other)
+	py.PyThreadState_DeleteCurrent()
+	py.PyEval_RestoreThread(ts)
+}
+
+func TestInterpreterState(t *testing.T) {
+	// Tearing down a sub-interpreter by hand rather than with
+	// Py_EndInterpreter skips finalizing it, leaving its memory behind.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+
+	var ts py.PyThreadStatePtr
+	config := py.PyInterpreterConfig{Gil: py.OwnGil, CheckMultiInterpExtensions: 1}
+	if status := py.Py_NewInterpreterFromConfig(&ts, &config); status.Type != 0 {
+		t.Fatalf("Py_NewInterpreterFromConfig failed with status %d", status.Type)
+	}
+	state := py.PyInterpreterState_Get()
+	if state == interp.State() {
+		t.Fatal("new interpreter isn't current")
+	}
+	py.PyInterpreterState_Clear(state)
+	py.PyThreadState_Clear(ts)
+	py.PyThreadState_DeleteCurrent()
+	py.PyInterpreterState_Delete(state)
+
+	py.PyEval_RestoreThread(interp.ThreadState())
+}
+
+func TestFrames(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	var name, dump string
+	var line int32
+	var back bool
+	probe := py.NewFunction("probe", py.NullPyObjectPtr, func(_, _ py.PyObjectPtr) py.PyObjectPtr {
+		frame := py.PyThreadState_GetFrame(py.PyThreadState_Get())
+		defer py.Py_DecRef(py.PyObjectPtr(frame))
+
+		code := py.PyFrame_GetCode(frame)
+		coName := py.PyObject_GetAttrString(py.PyObjectPtr(code), "co_name")
+		name, _ = py.UnicodeToString(coName)
+		py.Py_DecRef(coName)
+		py.Py_DecRef(py.PyObjectPtr(code))
+		line = py.PyFrame_GetLineNumber(frame)
+		if caller := py.PyFrame_GetBack(frame); caller != py.NullPyFrameObjectPtr {
+			back = true
+			py.Py_DecRef(py.PyObjectPtr(caller))
+		}
+		dump = dumpTracebacks(t)
+
+		py.Py_IncRef(py.Py_None)
+		return py.Py_None
+	})
+	py.PyDict_SetItemString(interp.Globals(), "probe", probe)
+	py.Py_DecRef(probe)
+
+	gogopythontest.RequireExec(t, interp, "def outer():\n    probe()\nouter()")
+	if name != "outer" || line != 2 || !back {
+		t.Errorf("frame = %s line %d, back %v, want outer line 2 with a caller", name, line, back)
+	}
+	if !strings.Contains(dump, "Current thread") || !strings.Contains(dump, "in outer") {
+		t.Errorf("unexpected traceback dump:\n%s", dump)
+	}
+}
+
+// dumpTracebacks returns the tracebacks of the current interpreter's threads.
+func dumpTracebacks(t *testing.T) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	msg := py.Py_DumpTracebackThreads(int32(w.Fd()), py.PyInterpreterState_Get(), py.PyThreadState_Get())
+	w.Close()
+	if msg != nil {
+		t.Errorf("Py_DumpTracebackThreads failed")
+	}
+	b, _ := io.ReadAll(r)
+	return string(b)
+}
+
+func TestTrace(t *testing.T) {
+	// Tracing instruments code with per interpreter monitoring data.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+	gogopythontest.RequireExec(t, interp, "def f():\n    return len([])")
+
+	for _, set := range []struct {
+		name string
+		fn   func(py.TraceFunc) error
+		want py.PyTraceWhat
+	}{
+		{"SetTrace", py.SetTrace, py.PyTrace_Line},
+		{"SetTraceAllThreads", py.SetTraceAllThreads, py.PyTrace_Line},
+		{"SetProfile", py.SetProfile, py.PyTrace_CCall},
+		{"SetProfileAllThreads", py.SetProfileAllThreads, py.PyTrace_CCall},
+	} {
+		var events []py.PyTraceWhat
+		err := set.fn(func(ev py.TraceEvent) error {
+			events = append(events, ev.What)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", set.name, err)
+		}
+		gogopythontest.RequireExec(t, interp, "f()")
+		if err := set.fn(nil); err != nil {
+			t.Fatalf("%s: %v", set.name, err)
+		}
+		if !slices.Contains(events, py.PyTrace_Call) || !slices.Contains(events, set.want) {
+			t.Errorf("%s: events = %v", set.name, events)
+		}
+	}
+
+	calls := 0
+	err := py.SetTrace(func(ev py.TraceEvent) error {
+		if ev.What == py.PyTrace_Call {
+			calls++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer py.SetTrace(nil)
+	ts := py.PyThreadState_Get()
+	py.PyThreadState_EnterTracing(ts)
+	gogopythontest.RequireExec(t, interp, "f()")
+	py.PyThreadState_LeaveTracing(ts)
+	if calls != 0 {
+		t.Errorf("%d calls traced between PyThreadState_EnterTracing and PyThreadState_LeaveTracing", calls)
+	}
+	gogopythontest.RequireExec(t, interp, "f()")
+	if calls == 0 {
+		t.Error("no calls traced after PyThreadState_LeaveTracing")
+	}
+}
+
+func TestRun(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{Preload: []string{"io"}})
+	globals := interp.Globals()
+
+	if py.PyRun_SimpleString("_run_test = 1\ndel _run_test") != 0 {
+		t.Error("PyRun_SimpleString failed")
+	}
+	gogopythontest.RequireExec(t, interp, "import io, sys\nsys.stderr = io.StringIO()")
+	if py.PyRun_SimpleString("raise ValueError") != -1 {
+		t.Error("PyRun_SimpleString succeeded despite an exception")
+	}
+	gogopythontest.RequireEval(t, interp, "'ValueError' in sys.stderr.getvalue()", true)
+	gogopythontest.RequireExec(t, interp, "sys.stderr = sys.__stderr__\ndel sys.last_exc, sys.last_type, sys.last_value, sys.last_traceback")
+
+	res := py.PyRun_String("x = 6 * 7", py.PyFileInput, globals, globals)
+	if res != py.Py_None {
+		t.Error("PyRun_String with PyFileInput didn't return None")
+	}
+	py.Py_DecRef(res)
+	res = py.PyRun_String("x", py.PyEvalInput, globals, globals)
+	if py.PyLong_AsLong(res) != 42 {
+		t.Error("PyRun_String with PyEvalInput didn't return the value")
+	}
+	py.Py_DecRef(res)
+}
+
+func TestCompile(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+	globals := interp.Globals()
+
+	eval := func(code py.PyCodeObjectPtr) int64 {
+		t.Helper()
+		if code == py.NullPyCodeObjectPtr {
+			t.Fatalf("compile failed: %v", py.FetchError())
+		}
+		defer py.Py_DecRef(py.PyObjectPtr(code))
+		res := py.PyEval_EvalCode(code, globals, globals)
+		if res == py.NullPyObjectPtr {
+			t.Fatalf("eval failed: %v", py.FetchError())
+		}
+		defer py.Py_DecRef(res)
+		return py.PyLong_AsLong(res)
+	}
+	if n := eval(py.Py_CompileString("1 + 2", "<test>", py.PyEvalInput)); n != 3 {
+		t.Errorf("Py_CompileString: got %d", n)
+	}
+	if n := eval(py.Py_CompileStringFlags("3 + 4", "<test>", py.PyEvalInput, nil)); n != 7 {
+		t.Errorf("Py_CompileStringFlags: got %d", n)
+	}
+
+	// Asserts are removed when optimizing.
+	code := py.Py_CompileStringExFlags("assert False", "<test>", py.PyFileInput, nil, 1)
+	if code == py.NullPyCodeObjectPtr {
+		t.Fatalf("Py_CompileStringExFlags failed: %v", py.FetchError())
+	}
+	res := py.PyEval_EvalCode(code, globals, globals)
+	py.Py_DecRef(py.PyObjectPtr(code))
+	if res == py.NullPyObjectPtr {
+		t.Fatalf("optimized assert raised %v", py.FetchError())
+	}
+	py.Py_DecRef(res)
+
+	if code := py.Py_CompileString("1 +", "<test>", py.PyEvalInput); code != py.NullPyCodeObjectPtr {
+		t.Error("compiling invalid code succeeded")
+	}
+	var exc *py.Exception
+	if err := py.FetchError(); !errors.As(err, &exc) || exc.Type != "SyntaxError" {
+		t.Errorf("compiling invalid code raised %v", err)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	code := py.Py_CompileString("6 * 7", "<test>", py.PyEvalInput)
+	data := py.PyMarshal_WriteObjectToString(py.PyObjectPtr(code), 4)
+	py.Py_DecRef(py.PyObjectPtr(code))
+	if data == py.NullPyObjectPtr {
+		t.Fatalf("PyMarshal_WriteObjectToString failed: %v", py.FetchError())
+	}
+	defer py.Py_DecRef(data)
+
+	obj := py.PyMarshal_ReadObjectFromString(py.PyBytes_AsString(data), py.PyBytes_Size(data))
+	if obj == py.NullPyObjectPtr {
+		t.Fatalf("PyMarshal_ReadObjectFromString failed: %v", py.FetchError())
+	}
+	defer py.Py_DecRef(obj)
+	res := py.PyEval_EvalCode(py.PyCodeObjectPtr(obj), interp.Globals(), interp.Globals())
+	defer py.Py_DecRef(res)
+	if py.PyLong_AsLong(res) != 42 {
+		t.Error("unmarshalled code returned the wrong value")
+	}
+}
+
+func TestModules(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	mod := py.PyModule_New("_bindingtest_new")
+	defer py.Py_DecRef(mod)
+	seven := py.PyLong_FromLong(7)
+	if py.PyModule_AddObjectRef(mod, "seven", seven) != 0 {
+		t.Fatalf("PyModule_AddObjectRef failed: %v", py.FetchError())
+	}
+	py.Py_DecRef(seven)
+	if got := py.PyDict_GetItemString(py.PyModule_GetDict(mod), "seven"); got != seven {
+		t.Error("PyModule_GetDict doesn't hold the added object")
+	}
+
+	if py.PyModule_GetDef(mod) != nil {
+		t.Error("PyModule_GetDef found a definition for a module created with PyModule_New")
+	}
+
+	// Built-in modules are defined by a PyModuleDef.
+	gogopythontest.RequireExec(t, interp, "import _bindingtest")
+	gogopythontest.RequireEval(t, interp, "_bindingtest.answer", 42)
+	if py.PyModule_GetDef(gogopythontest.Eval(t, interp, "_bindingtest")) == nil {
+		t.Error("PyModule_GetDef found no definition for a built-in module")
+	}
+	gogopythontest.RequireExec(t, interp, "import sys\ndel sys.modules['_bindingtest']")
+}
+
+func TestImport(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	added := py.PyImport_AddModule("_bindingtest_added")
+	if added == py.NullPyObjectPtr {
+		t.Fatalf("PyImport_AddModule failed: %v", py.FetchError())
+	}
+	if py.PyDict_GetItemString(py.PyImport_GetModuleDict(), "_bindingtest_added") != added {
+		t.Error("added module isn't in sys.modules")
+	}
+
+	code := py.Py_CompileString("value = 5", "<test>", py.PyFileInput)
+	defer py.Py_DecRef(py.PyObjectPtr(code))
+	mod := py.PyImport_ExecCodeModule("_bindingtest_exec", code)
+	if mod == py.NullPyObjectPtr {
+		t.Fatalf("PyImport_ExecCodeModule failed: %v", py.FetchError())
+	}
+	py.Py_DecRef(mod)
+	mod = py.PyImport_ExecCodeModuleWithPathnames("_bindingtest_path", code, "/src/path.py", "")
+	if mod == py.NullPyObjectPtr {
+		t.Fatalf("PyImport_ExecCodeModuleWithPathnames failed: %v", py.FetchError())
+	}
+	py.Py_DecRef(mod)
+	gogopythontest.RequireExec(t, interp, "import sys, _bindingtest_exec, _bindingtest_path")
+	gogopythontest.RequireEval(t, interp, "_bindingtest_exec.value", 5)
+	gogopythontest.RequireEval(t, interp, "_bindingtest_path.__file__", "/src/path.py")
+
+	for _, mod := range []py.PyObjectPtr{
+		py.PyImport_ImportModule("sys"),
+		py.PyImport_ImportModuleLevel("sys", interp.Globals(), py.NullPyObjectPtr, py.NullPyObjectPtr, 0),
+	} {
+		if mod == py.NullPyObjectPtr {
+			t.Fatalf("import failed: %v", py.FetchError())
+		}
+		py.Py_DecRef(mod)
+	}
+	if py.PyImport_ImportModule("_bindingtest_missing") != py.NullPyObjectPtr {
+		t.Error("importing a missing module succeeded")
+	}
+	var exc *py.Exception
+	if err := py.FetchError(); !errors.As(err, &exc) || exc.Type != "ModuleNotFoundError" {
+		t.Errorf("importing a missing module raised %v", err)
+	}
+
+	gogopythontest.RequireExec(t, interp, `
+for name in ['_bindingtest_added', '_bindingtest_exec', '_bindingtest_path']:
+    del sys.modules[name]`)
+}
+
+func TestImportPolicy(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{
+		Imports: py.ImportPolicy{Deny: []string{"json"}},
+	})
+	gogopythontest.RequireRaises(t, interp, "import json", "ModuleNotFoundError")
+}
+
+var capsuleName = []byte("gogopython.test\x00")
+
+func TestCapsule(t *testing.T) {
+	gogopythontest.NewInterpreter(t)
+
+	var value int64 = 42
+	ptr := uintptr(unsafe.Pointer(&value))
+	capsule := py.PyCapsule_New(ptr, &capsuleName[0], 0)
+	if capsule == py.NullPyObjectPtr {
+		t.Fatalf("PyCapsule_New failed: %v", py.FetchError())
+	}
+	defer py.Py_DecRef(capsule)
+
+	if py.PyCapsule_IsValid(capsule, &capsuleName[0]) != 1 {
+		t.Error("capsule isn't valid")
+	}
+	other := []byte("other\x00")
+	if py.PyCapsule_IsValid(capsule, &other[0]) != 0 {
+		t.Error("capsule is valid with another name")
+	}
+	if py.PyCapsule_GetPointer(capsule, &capsuleName[0]) != ptr {
+		t.Error("PyCapsule_GetPointer returned another pointer")
+	}
+	if py.PyCapsule_GetName(capsule) != &capsuleName[0] {
+		t.Error("PyCapsule_GetName returned another name")
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	for _, n := range []int64{0, 1, -1, math.MaxInt64, math.MinInt64} {
+		for _, obj := range []py.PyObjectPtr{py.PyLong_FromLong(n), py.PyLong_FromLongLong(n)} {
+			if got := py.PyLong_AsLong(obj); got != n {
+				t.Errorf("PyLong_AsLong() = %d, want %d", got, n)
+			}
+			py.Py_DecRef(obj)
+		}
+	}
+	for _, n := range []uint64{0, math.MaxUint64} {
+		for _, obj := range []py.PyObjectPtr{py.PyLong_FromUnsignedLong(n), py.PyLong_FromUnsignedLongLong(n)} {
+			if got := py.PyLong_AsUnsignedLong(obj); got != n {
+				t.Errorf("PyLong_AsUnsignedLong() = %d, want %d", got, n)
+			}
+			py.Py_DecRef(obj)
+		}
+	}
+
+	for expr, want := range map[string]int32{"2**64": 1, "-2**64": -1, "7": 0} {
+		var overflow int32
+		py.PyLong_AsLongAndOverflow(gogopythontest.Eval(t, interp, expr), &overflow)
+		if overflow != want {
+			t.Errorf("PyLong_AsLongAndOverflow(%s) overflow = %d, want %d", expr, overflow, want)
+		}
+	}
+	py.PyLong_AsUnsignedLong(gogopythontest.Eval(t, interp, "-1"))
+	var exc *py.Exception
+	if err := py.FetchError(); !errors.As(err, &exc) || exc.Type != "OverflowError" {
+		t.Errorf("PyLong_AsUnsignedLong(-1) raised %v", err)
+	}
+
+	if py.PyBool_FromLong(5) != py.Py_True || py.PyBool_FromLong(0) != py.Py_False {
+		t.Error("PyBool_FromLong returned the wrong singleton")
+	}
+
+	f := py.PyFloat_FromDouble(1.5)
+	if got := py.PyFloat_AsDouble(f); got != 1.5 {
+		t.Errorf("PyFloat_AsDouble() = %v", got)
+	}
+	py.Py_DecRef(f)
+}
+
+func TestTuple(t *testing.T) {
+	gogopythontest.NewInterpreter(t)
+
+	tuple := py.PyTuple_New(2)
+	defer py.Py_DecRef(tuple)
+	for i := int64(0); i < 2; i++ {
+		if py.PyTuple_SetItem(tuple, i, py.PyLong_FromLong(i*10)) != 0 {
+			t.Fatalf("PyTuple_SetItem failed: %v", py.FetchError())
+		}
+	}
+	if py.PyTuple_Size(tuple) != 2 || py.PyLong_AsLong(py.PyTuple_GetItem(tuple, 1)) != 10 {
+		t.Error("tuple doesn't hold the items set")
+	}
+	if py.PyTuple_SetItem(tuple, 2, py.PyLong_FromLong(0)) != -1 {
+		t.Error("PyTuple_SetItem out of range succeeded")
+	}
+	py.PyErr_Clear()
+}
+
+func TestList(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	list := py.PyList_New(0)
+	py.PyDict_SetItemString(interp.Globals(), "l", list)
+	py.Py_DecRef(list)
+
+	one, two := py.PyLong_FromLong(1), py.PyLong_FromLong(2)
+	defer py.Py_DecRef(one)
+	defer py.Py_DecRef(two)
+	if py.PyList_Append(list, one) != 0 || py.PyList_Insert(list, 0, two) != 0 {
+		t.Fatalf("adding to list failed: %v", py.FetchError())
+	}
+	py.Py_IncRef(one) // Stolen by PyList_SetItem.
+	if py.PyList_SetItem(list, 1, one) != 0 {
+		t.Fatalf("PyList_SetItem failed: %v", py.FetchError())
+	}
+	gogopythontest.RequireEval(t, interp, "l", []int{2, 1})
+	if py.PyList_Size(list) != 2 || py.PyList_GetItem(list, 0) != two {
+		t.Error("list doesn't hold the items added")
+	}
+
+	py.Py_IncRef(one)
+	if py.PyList_SetItem(list, 5, one) != -1 || py.PyList_Insert(py.Py_None, 0, one) != -1 {
+		t.Error("invalid list operations succeeded")
+	}
+	py.PyErr_Clear()
+
+	tuple := gogopythontest.Eval(t, interp, "(1, 2)")
+	copied := py.PySequence_List(tuple)
+	if copied == py.NullPyObjectPtr {
+		t.Fatalf("PySequence_List failed: %v", py.FetchError())
+	}
+	defer py.Py_DecRef(copied)
+	if py.PyList_Size(copied) != 2 {
+		t.Errorf("PySequence_List((1, 2)) has %d items, want 2", py.PyList_Size(copied))
+	}
+}
+
+func TestDict(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	dict := py.PyDict_New()
+	py.PyDict_SetItemString(interp.Globals(), "d", dict)
+	py.Py_DecRef(dict)
+
+	key, value := py.PyUnicode_FromString("a"), py.PyLong_FromLong(1)
+	defer py.Py_DecRef(key)
+	defer py.Py_DecRef(value)
+	if py.PyDict_SetItem(dict, key, value) != 0 || py.PyDict_SetItemString(dict, "b", value) != 0 {
+		t.Fatalf("setting items failed: %v", py.FetchError())
+	}
+	gogopythontest.RequireEval(t, interp, "d", map[string]int{"a": 1, "b": 1})
+	if py.PyDict_GetItem(dict, key) != value || py.PyDict_GetItemString(dict, "b") != value {
+		t.Error("dict doesn't hold the items set")
+	}
+	if py.PyDict_GetItemString(dict, "c") != py.NullPyObjectPtr {
+		t.Error("missing key found")
+	}
+	if py.PyDict_Size(dict) != 2 {
+		t.Error("wrong dict size")
+	}
+
+	keys, values := py.PyDict_Keys(dict), py.PyDict_Values(dict)
+	if py.PyList_Size(keys) != 2 || py.PyList_GetItem(values, 0) != value {
+		t.Error("wrong keys or values")
+	}
+	py.Py_DecRef(keys)
+	py.Py_DecRef(values)
+
+	proxy := py.PyDictProxy_New(dict)
+	py.PyDict_SetItemString(interp.Globals(), "p", proxy)
+	py.Py_DecRef(proxy)
+	gogopythontest.RequireRaises(t, interp, "p['c'] = 1", "TypeError")
+
+	// Errors are reported as a C int.
+	if py.PyDict_SetItemString(py.Py_None, "a", value) != -1 {
+		t.Error("PyDict_SetItemString on None didn't return -1")
+	}
+	py.PyErr_Clear()
+
+	py.PyDict_Clear(dict)
+	gogopythontest.RequireEval(t, interp, "d", map[string]int{})
+}
+
+func TestSet(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	set := py.PySet_New(py.NullPyObjectPtr)
+	defer py.Py_DecRef(set)
+	one := gogopythontest.Eval(t, interp, "1")
+	two := gogopythontest.Eval(t, interp, "2")
+	if py.PySet_Add(set, one) != 0 || py.PySet_Add(set, two) != 0 {
+		t.Fatalf("PySet_Add failed: %v", py.FetchError())
+	}
+	if py.PySet_Size(set) != 2 || py.PySet_Contains(set, one) != 1 {
+		t.Error("set doesn't hold the items added")
+	}
+	if py.PySet_Discard(set, one) != 1 || py.PySet_Contains(set, one) != 0 {
+		t.Error("PySet_Discard failed")
+	}
+	popped := py.PySet_Pop(set)
+	if popped != two {
+		t.Error("PySet_Pop returned another item")
+	}
+	py.Py_DecRef(popped)
+	if py.PySet_Pop(set) != py.NullPyObjectPtr {
+		t.Error("PySet_Pop from an empty set succeeded")
+	}
+	py.PyErr_Clear()
+
+	py.PySet_Add(set, one)
+	if py.PySet_Clear(set) != 0 || py.PySet_Size(set) != 0 {
+		t.Error("PySet_Clear failed")
+	}
+
+	frozen := py.PyFrozenSet_New(gogopythontest.Eval(t, interp, "[1, 2, 2]"))
+	defer py.Py_DecRef(frozen)
+	if py.PySet_Size(frozen) != 2 || py.TypeName(frozen) != "frozenset" {
+		t.Error("PyFrozenSet_New failed")
+	}
+}
+
+func TestBytes(t *testing.T) {
+	gogopythontest.NewInterpreter(t)
+
+	b := py.PyBytes_FromString("abc")
+	if py.PyBytes_Size(b) != 3 || unsafe.String(py.PyBytes_AsString(b), 3) != "abc" {
+		t.Error("PyBytes_FromString failed")
+	}
+	py.Py_DecRef(b)
+
+	data := []byte("a\x00b")
+	b = py.PyBytes_FromStringAndSize(&data[0], int64(len(data)))
+	if py.PyBytes_Size(b) != 3 {
+		t.Error("PyBytes_FromStringAndSize stopped at NUL")
+	}
+	py.Py_DecRef(b)
+
+	b = py.PyByteArray_FromStringAndSize(&data[0], int64(len(data)))
+	if py.TypeName(b) != "bytearray" {
+		t.Error("PyByteArray_FromStringAndSize didn't create a bytearray")
+	}
+	py.Py_DecRef(b)
+}
+
+func TestFSPath(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{Preload: []string{"pathlib"}})
+	gogopythontest.RequireExec(t, interp, "import pathlib")
+
+	for _, tt := range []struct {
+		path string
+		want any
+	}{
+		{"'a/b'", "a/b"},
+		{"b'a/b'", []byte("a/b")},
+		{"pathlib.PurePosixPath('/a/b')", "/a/b"},
+	} {
+		res := py.PyOS_FSPath(gogopythontest.Eval(t, interp, tt.path))
+		if res == py.NullPyObjectPtr {
+			t.Errorf("PyOS_FSPath(%s) raised %v", tt.path, py.FetchError())
+			continue
+		}
+		setGlobal(t, interp, "res", res)
+		gogopythontest.RequireEval(t, interp, "res", tt.want)
+	}
+
+	if py.PyOS_FSPath(gogopythontest.Eval(t, interp, "1")) != py.NullPyObjectPtr || py.PyErr_Occurred() != py.PyExc_TypeError {
+		t.Error("PyOS_FSPath didn't raise TypeError for an int")
+	}
+	py.PyErr_Clear()
+}
+
+func TestUnicode(t *testing.T) {
+	gogopythontest.NewInterpreter(t)
+
+	s := py.PyUnicode_FromString("héllo")
+	defer py.Py_DecRef(s)
+	if got, _ := py.UnicodeToString(s); got != "héllo" {
+		t.Errorf("PyUnicode_FromString round trip = %q", got)
+	}
+
+	data := []byte("héllo world")
+	s2 := py.PyUnicode_FromStringAndSize(&data[0], 6)
+	if got, _ := py.UnicodeToString(s2); got != "héllo" {
+		t.Errorf("PyUnicode_FromStringAndSize = %q", got)
+	}
+	py.Py_DecRef(s2)
+
+	encoded := py.PyUnicode_AsEncodedString(s, "utf-8", py.Strict)
+	if py.PyBytes_Size(encoded) != 6 {
+		t.Error("PyUnicode_AsEncodedString returned the wrong size")
+	}
+	py.Py_DecRef(encoded)
+
+	var size int
+	w := py.PyUnicode_AsWideCharString(s, &size)
+	if w == nil || size != 5 {
+		t.Errorf("PyUnicode_AsWideCharString size = %d", size)
+	}
+	py.PyMem_Free(w)
+
+	path := py.PyUnicode_DecodeFSDefault("/tmp/file")
+	if got, _ := py.UnicodeToString(path); got != "/tmp/file" {
+		t.Errorf("PyUnicode_DecodeFSDefault = %q", got)
+	}
+	b := py.PyUnicode_EncodeFSDefault(path)
+	if py.PyBytes_Size(b) != 9 {
+		t.Error("PyUnicode_EncodeFSDefault returned the wrong size")
+	}
+	py.Py_DecRef(b)
+	py.Py_DecRef(path)
+}
+
+func TestRefCount(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+	gogopythontest.RequireExec(t, interp, "import sys\no = object()")
+	obj := py.PyDict_GetItemString(interp.Globals(), "o")
+
+	refs := func() (n int64) {
+		t.Helper()
+		res := gogopythontest.Eval(t, interp, "sys.getrefcount(o)")
+		return py.PyLong_AsLong(res)
+	}
+	before := refs()
+	py.Py_IncRef(obj)
+	if refs() != before+1 {
+		t.Error("Py_IncRef didn't increment")
+	}
+	py.Py_DecRef(obj)
+	if refs() != before {
+		t.Error("Py_DecRef didn't decrement")
+	}
+}
+
+func TestIter(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	list := gogopythontest.Eval(t, interp, "[1, 2, 3]")
+	it := py.PyObject_GetIter(list)
+	defer py.Py_DecRef(it)
+	if py.PyIter_Check(it) != 1 || py.PyIter_Check(list) != 0 {
+		t.Error("PyIter_Check failed")
+	}
+	var sum int64
+	for item := py.PyIter_Next(it); item != py.NullPyObjectPtr; item = py.PyIter_Next(it) {
+		sum += py.PyLong_AsLong(item)
+		py.Py_DecRef(item)
+	}
+	gogopythontest.RequireNoException(t)
+	if sum != 6 {
+		t.Errorf("sum = %d", sum)
+	}
+
+	gogopythontest.RequireExec(t, interp, "def gen():\n    x = yield 1\n    return x * 2")
+	g := gogopythontest.Eval(t, interp, "gen()")
+	var res py.PyObjectPtr
+	if py.PyIter_Send(g, py.Py_None, &res) != py.PyGen_Next || py.PyLong_AsLong(res) != 1 {
+		t.Error("PyIter_Send didn't yield")
+	}
+	py.Py_DecRef(res)
+	arg := py.PyLong_FromLong(21)
+	defer py.Py_DecRef(arg)
+	if py.PyIter_Send(g, arg, &res) != py.PyGen_Return || py.PyLong_AsLong(res) != 42 {
+		t.Error("PyIter_Send didn't return")
+	}
+	py.Py_DecRef(res)
+}
+
+func TestGoIterator(t *testing.T) {
+	// The iterator type is created once per interpreter.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+
+	if py.PyObject_SelfIter == 0 {
+		t.Fatal("PyObject_SelfIter isn't resolved")
+	}
+	goIter, err := py.NewIterator(slices.Values([]int{1, 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	py.PyDict_SetItemString(interp.Globals(), "go_iter", goIter)
+	py.Py_DecRef(goIter)
+	gogopythontest.RequireEval(t, interp, "iter(go_iter) is go_iter and list(go_iter)", []int{1, 2})
+}
+
+func TestCall(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+	gogopythontest.RequireExec(t, interp, `
+def f(*args, **kwargs):
+    return repr((args, kwargs))
+
+class C:
+    def m(self, x=None):
+        return x`)
+	f := py.PyDict_GetItemString(interp.Globals(), "f")
+	one := gogopythontest.Eval(t, interp, "1")
+	args := gogopythontest.Eval(t, interp, "(1, 2)")
+	kwargs := gogopythontest.Eval(t, interp, "{'k': 3}")
+
+	for _, call := range []struct {
+		name string
+		res  py.PyObjectPtr
+		want string
+	}{
+		{"PyObject_CallNoArgs", py.PyObject_CallNoArgs(f), "((), {})"},
+		{"PyObject_CallOneArg", py.PyObject_CallOneArg(f, one), "((1,), {})"},
+		{"PyObject_CallObject", py.PyObject_CallObject(f, args), "((1, 2), {})"},
+		{"PyObject_CallObject without args", py.PyObject_CallObject(f, py.NullPyObjectPtr), "((), {})"},
+		{"PyObject_Call", py.PyObject_Call(f, args, kwargs), "((1, 2), {'k': 3})"},
+	} {
+		if call.res == py.NullPyObjectPtr {
+			t.Errorf("%s raised %v", call.name, py.FetchError())
+			continue
+		}
+		if got, _ := py.UnicodeToString(call.res); got != call.want {
+			t.Errorf("%s = %s, want %s", call.name, got, call.want)
+		}
+		py.Py_DecRef(call.res)
+	}
+
+	c := gogopythontest.Eval(t, interp, "C()")
+	m := gogopythontest.Eval(t, interp, "'m'")
+	if res := py.PyObject_CallMethodNoArgs(c, m); res != py.Py_None {
+		t.Errorf("PyObject_CallMethodNoArgs() = %s, %v", py.TypeName(res), py.FetchError())
+	}
+	res := py.PyObject_CallMethodOneArg(c, m, one)
+	if res != one {
+		t.Errorf("PyObject_CallMethodOneArg() = %s, %v", py.TypeName(res), py.FetchError())
+	}
+	py.Py_DecRef(res)
+
+	vectorArgs := []py.PyObjectPtr{c, one}
+	kwnames := gogopythontest.Eval(t, interp, "('x',)")
+	res = py.PyObject_VectorcallMethod(m, &vectorArgs[0], 1, kwnames)
+	if res != one {
+		t.Errorf("PyObject_VectorcallMethod() = %s, %v", py.TypeName(res), py.FetchError())
+	}
+	py.Py_DecRef(res)
+}
+
+func TestObject(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+	gogopythontest.RequireExec(t, interp, "class C:\n    pass\n\ndef g(self):\n    return 'bound'")
+	class := gogopythontest.Eval(t, interp, "C")
+	c := gogopythontest.Eval(t, interp, "C()")
+
+	if py.PyObject_IsInstance(c, class) != 1 || py.PyObject_IsInstance(class, c) != -1 {
+		t.Error("PyObject_IsInstance failed")
+	}
+	py.PyErr_Clear()
+
+	value := gogopythontest.Eval(t, interp, "'value'")
+	if py.PyObject_SetAttrString(c, "attr", value) != 0 {
+		t.Fatalf("PyObject_SetAttrString failed: %v", py.FetchError())
+	}
+	attr := py.PyObject_GetAttrString(c, "attr")
+	if attr != value {
+		t.Error("PyObject_GetAttrString returned another object")
+	}
+	py.Py_DecRef(attr)
+
+	str := py.PyObject_Str(gogopythontest.Eval(t, interp, "1"))
+	if got, _ := py.UnicodeToString(str); got != "1" {
+		t.Errorf("PyObject_Str() = %q", got)
+	}
+	py.Py_DecRef(str)
+
+	tp := py.PyObject_Type(c)
+	if py.PyObjectPtr(tp) != class {
+		t.Error("PyObject_Type returned another type")
+	}
+	const heapType = 1 << 9
+	if py.PyType_GetFlags(tp)&heapType == 0 {
+		t.Error("class isn't a heap type")
+	}
+	alloc := py.PyType_GenericAlloc(tp, 0)
+	if alloc == py.NullPyObjectPtr || py.PyObject_IsInstance(alloc, class) != 1 {
+		t.Error("PyType_GenericAlloc didn't create an instance")
+	}
+	py.Py_DecRef(alloc)
+	py.Py_DecRef(py.PyObjectPtr(tp))
+
+	g := py.PyDict_GetItemString(interp.Globals(), "g")
+	code := py.PyFunction_GetCode(g)
+	name := py.PyObject_GetAttrString(py.PyObjectPtr(code), "co_name")
+	if got, _ := py.UnicodeToString(name); got != "g" {
+		t.Errorf("PyFunction_GetCode returned the code of %s", got)
+	}
+	py.Py_DecRef(name)
+
+	method := py.PyInstanceMethod_New(g)
+	py.PyObject_SetAttrString(class, "g", method)
+	py.Py_DecRef(method)
+	gogopythontest.RequireEval(t, interp, "C().g()", "bound")
+}
+
+func TestErrors(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{Preload: []string{"io"}})
+
+	py.PyErr_SetString(py.PyExc_ValueError, "bad value")
+	if py.PyErr_Occurred() != py.PyExc_ValueError {
+		t.Error("PyErr_Occurred didn't return the exception type")
+	}
+	exc := py.PyErr_GetRaisedException()
+	if exc == py.NullPyObjectPtr || py.PyErr_Occurred() != py.NullPyObjectPtr {
+		t.Fatal("PyErr_GetRaisedException didn't take the exception")
+	}
+	py.PyErr_SetRaisedException(exc)
+	if py.PyErr_Occurred() != py.PyExc_ValueError {
+		t.Error("PyErr_SetRaisedException didn't set the exception")
+	}
+	py.PyErr_Clear()
+	gogopythontest.RequireNoException(t)
+
+	gogopythontest.RequireExec(t, interp, "import io, sys\nsys.stderr = io.StringIO()")
+	py.PyErr_SetString(py.PyExc_RuntimeError, "printed")
+	py.PyErr_Print()
+	gogopythontest.RequireEval(t, interp, "'RuntimeError: printed' in sys.stderr.getvalue()", true)
+	gogopythontest.RequireExec(t, interp, "sys.stderr = sys.__stderr__\ndel sys.last_exc, sys.last_type, sys.last_value, sys.last_traceback")
+}
+
+func TestSingletons(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	for name, obj := range map[string]py.PyObjectPtr{
+		"None":                py.Py_None,
+		"True":                py.Py_True,
+		"False":               py.Py_False,
+		"BaseException":       py.PyExc_BaseException,
+		"Exception":           py.PyExc_Exception,
+		"AttributeError":      py.PyExc_AttributeError,
+		"ImportError":         py.PyExc_ImportError,
+		"IndexError":          py.PyExc_IndexError,
+		"KeyError":            py.PyExc_KeyError,
+		"MemoryError":         py.PyExc_MemoryError,
+		"ModuleNotFoundError": py.PyExc_ModuleNotFoundError,
+		"NotImplementedError": py.PyExc_NotImplementedError,
+		"OverflowError":       py.PyExc_OverflowError,
+		"PermissionError":     py.PyExc_PermissionError,
+		"RuntimeError":        py.PyExc_RuntimeError,
+		"StopIteration":       py.PyExc_StopIteration,
+		"SyntaxError":         py.PyExc_SyntaxError,
+		"TypeError":           py.PyExc_TypeError,
+		"ValueError":          py.PyExc_ValueError,
+	} {
+		if got := gogopythontest.Eval(t, interp, name); got != obj {
+			t.Errorf("%s is %x, want %x", name, obj, got)
+		}
+	}
+}
+
+func TestAllocator(t *testing.T) {
+	gogopythontest.NewInterpreter(t)
+
+	for _, domain := range []py.PyMemAllocatorDomain{py.PyMem_DomainRaw, py.PyMem_DomainMem, py.PyMem_DomainObj} {
+		var allocator py.PyMemAllocatorEx
+		py.PyMem_GetAllocator(domain, &allocator)
+		if allocator.Malloc == 0 || allocator.Free == 0 {
+			t.Fatalf("domain %d has no allocator", domain)
+		}
+		// Setting the current allocator again changes nothing.
+		py.PyMem_SetAllocator(domain, &allocator)
+	}
+}
+
+type counter struct{ n int }
+
+func (c *counter) Inc() int {
+	c.n++
+	return c.n
+}
+
+func TestClass(t *testing.T) {
+	// Types are cached per interpreter, so they outlive the test.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+
+	class, err := py.NewClass[counter]("Counter").
+		Constructor(func() *counter { return &counter{} }).
+		Methods().
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := class.Wrap(&counter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	py.PyDict_SetItemString(interp.Globals(), "c", obj)
+	py.Py_DecRef(obj)
+	gogopythontest.RequireEval(t, interp, "c.inc() + c.inc()", 3)
+
+	mod, err := py.NewModuleBuilder("_bindingtest_class").
+		Func("double", func(n int) int { return 2 * n }).
+		Class(class).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mod.Install(); err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireExec(t, interp, "import _bindingtest_class as m")
+	gogopythontest.RequireEval(t, interp, "m.double(c.inc())", 6)
+	gogopythontest.RequireEval(t, interp, "type(c) is m.Counter", true)
+
+	// Type attributes can't make the constructor find another class.
+	gogopythontest.RequireExec(t, interp, "m.Counter.__goclass__ = 1")
+	gogopythontest.RequireEval(t, interp, "m.Counter().inc()", 1)
+}
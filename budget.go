@@ -0,0 +1,177 @@
+package gogopython
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ebitengine/purego"
+)
+
+// Budget caps the work Python code run by RunWithBudget may do. A zero field
+// is neither limited nor counted, as counting instructions in particular
+// slows Python down a lot. Use math.MaxInt64 to count without a limit.
+type Budget struct {
+	Instructions int64 // Instructions caps the bytecode instructions run.
+	Calls        int64 // Calls caps the calls of Python and C functions.
+}
+
+// BudgetUsage is the work done by Python code run by RunWithBudget.
+type BudgetUsage struct {
+	Instructions int64
+	Calls        int64
+}
+
+// BudgetExceededError is returned by RunWithBudget when the Python code ran
+// out of budget.
+type BudgetExceededError struct {
+	Exception
+
+	Budget Budget
+	Usage  BudgetUsage
+}
+
+func (e *BudgetExceededError) Unwrap() error {
+	return &e.Exception
+}
+
+// Python exception raised when the budget is exceeded. It derives from
+// BaseException, like KeyboardInterrupt, so "except Exception" won't
+// swallow it.
+//
+// Python 3.12 only reports opcode events to trace functions set after some
+// frame of the interpreter asked for them, so the helper asks first.
+const budgetExceededSrc = `
+import sys
+sys._getframe().f_trace_opcodes = True
+
+class BudgetExceeded(BaseException):
+    pass
+`
+
+// Name of the capsules carrying budget hooks.
+const budgetCapsuleName = "gogopython.budget"
+
+// budgetState is the budget of one RunWithBudget call.
+type budgetState struct {
+	budget   Budget
+	usage    BudgetUsage
+	class    PyObjectPtr // The BudgetExceeded class.
+	exceeded bool
+}
+
+// budgetHook is the state passed to the trace or profile function.
+type budgetHook struct {
+	*budgetState
+	profile bool
+}
+
+var budgetTrampoline struct {
+	once sync.Once
+	fn   PyTraceFunc
+}
+
+// RunWithBudget calls fn, which runs Python code on the current thread, and
+// stops the code if it exceeds the budget by raising a BudgetExceeded
+// exception in it. Returns the work done, and fn's error, replaced by a
+// *BudgetExceededError if the budget was exceeded.
+//
+// Instructions are counted with a trace function and calls with a profile
+// function, replacing any set on the current thread and removing them
+// afterwards. Code run by other threads isn't counted.
+//
+// Requires the caller to hold the GIL.
+func RunWithBudget(budget Budget, fn func() error) (BudgetUsage, error) {
+	if budget.Instructions < 0 || budget.Calls < 0 {
+		return BudgetUsage{}, errors.New("budget must not be negative")
+	}
+	budgetTrampoline.once.Do(func() {
+		budgetTrampoline.fn = purego.NewCallback(budgetDispatch)
+	})
+
+	class, err := compileHelper(budgetExceededSrc, "BudgetExceeded")
+	if err != nil {
+		return BudgetUsage{}, err
+	}
+	defer Py_DecRef(class)
+	s := &budgetState{budget: budget, class: class}
+
+	if budget.Instructions > 0 {
+		capsule, err := NewCapsule(budgetCapsuleName, budgetHook{budgetState: s})
+		if err != nil {
+			return BudgetUsage{}, err
+		}
+		PyEval_SetTrace(budgetTrampoline.fn, capsule)
+		Py_DecRef(capsule)
+		defer PyEval_SetTrace(0, NullPyObjectPtr)
+	}
+	if budget.Calls > 0 {
+		capsule, err := NewCapsule(budgetCapsuleName, budgetHook{budgetState: s, profile: true})
+		if err != nil {
+			return BudgetUsage{}, err
+		}
+		PyEval_SetProfile(budgetTrampoline.fn, capsule)
+		Py_DecRef(capsule)
+		defer PyEval_SetProfile(0, NullPyObjectPtr)
+	}
+
+	err = fn()
+	if !s.exceeded {
+		return s.usage, err
+	}
+	e := &BudgetExceededError{
+		Exception: Exception{Type: "BudgetExceeded", Message: "budget exceeded"},
+		Budget:    budget,
+		Usage:     s.usage,
+	}
+	var exc *Exception
+	if errors.As(err, &exc) && exc.Type == "BudgetExceeded" {
+		e.Exception = *exc
+	}
+	return s.usage, e
+}
+
+// budgetDispatch is the Py_tracefunc counting work against the budget in the
+// capsule obj.
+func budgetDispatch(obj PyObjectPtr, frame PyFrameObjectPtr, what PyTraceWhat, _ PyObjectPtr) (result int32) {
+	defer func() {
+		if r := recover(); r != nil {
+			PyErr_SetString(PyExc_RuntimeError, fmt.Sprintf("budget hook panicked: %v", r))
+			result = -1
+		}
+	}()
+
+	h, err := CapsuleValue[budgetHook](obj, budgetCapsuleName)
+	if err != nil {
+		PyErr_SetString(PyExc_RuntimeError, err.Error())
+		return -1
+	}
+
+	switch {
+	case h.profile && (what == PyTrace_Call || what == PyTrace_CCall):
+		h.usage.Calls++
+		if h.usage.Calls > h.budget.Calls {
+			return h.raise("call", h.budget.Calls)
+		}
+
+	case !h.profile && what == PyTrace_Call:
+		// Opcode events are only reported for frames asking for them.
+		if PyObject_SetAttrString(PyObjectPtr(frame), "f_trace_opcodes", Py_True) != 0 {
+			return -1
+		}
+
+	case !h.profile && what == PyTrace_Opcode:
+		h.usage.Instructions++
+		if h.usage.Instructions > h.budget.Instructions {
+			return h.raise("instruction", h.budget.Instructions)
+		}
+	}
+	return 0
+}
+
+// raise raises BudgetExceeded in Python, returning -1.
+func (s *budgetState) raise(kind string, limit int64) int32 {
+	s.exceeded = true
+	PyErr_SetString(s.class, fmt.Sprintf("%s budget of %d exceeded", kind, limit))
+	return -1
+}
@@ -0,0 +1,105 @@
+package gogopython_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+func TestRunWithBudget(t *testing.T) {
+	// Tracing instruments code with per interpreter monitoring data.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+	gogopythontest.RequireExec(t, interp, "def f(x):\n    return abs(x)")
+	loop := func() error { return interp.Exec("for i in range(100):\n    f(i)") }
+
+	unlimited := py.Budget{Instructions: math.MaxInt64, Calls: math.MaxInt64}
+	usage, err := py.RunWithBudget(unlimited, loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// range is called once, f and abs 100 times each.
+	if usage.Calls != 201 || usage.Instructions < 1000 {
+		t.Errorf("usage = %+v, want 201 calls and at least 1000 instructions", usage)
+	}
+	// Counting is deterministic.
+	again, err := py.RunWithBudget(unlimited, loop)
+	if err != nil || again != usage {
+		t.Errorf("running again used %+v, %v, want %+v", again, err, usage)
+	}
+
+	// Only the limited work is counted.
+	usage, err = py.RunWithBudget(py.Budget{Calls: 1000}, loop)
+	if err != nil || usage != (py.BudgetUsage{Calls: 201}) {
+		t.Errorf("counting calls only used %+v, %v", usage, err)
+	}
+
+	// fn's errors are returned as they are.
+	want := errors.New("failed")
+	if _, err := py.RunWithBudget(unlimited, func() error { return want }); err != want {
+		t.Errorf("RunWithBudget returned %v, want %v", err, want)
+	}
+	if _, err := py.RunWithBudget(py.Budget{Calls: -1}, loop); err == nil {
+		t.Error("ran with a negative budget")
+	}
+	gogopythontest.RequireNoException(t)
+}
+
+func TestBudgetExceeded(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+	gogopythontest.RequireExec(t, interp, "def f():\n    pass")
+
+	for _, tt := range []struct {
+		name   string
+		src    string
+		budget py.Budget
+		msg    string
+		usage  py.BudgetUsage
+	}{
+		{
+			name:   "instructions",
+			src:    "while True:\n    pass",
+			budget: py.Budget{Instructions: 1000},
+			msg:    "instruction budget of 1000 exceeded",
+			usage:  py.BudgetUsage{Instructions: 1001},
+		},
+		{
+			name:   "calls",
+			src:    "while True:\n    f()",
+			budget: py.Budget{Calls: 10},
+			msg:    "call budget of 10 exceeded",
+			usage:  py.BudgetUsage{Calls: 11},
+		},
+		{
+			// BudgetExceeded isn't an Exception, so it can't be swallowed
+			// by accident.
+			name:   "caught",
+			src:    "while True:\n    try:\n        f()\n    except Exception:\n        pass",
+			budget: py.Budget{Calls: 10},
+			msg:    "call budget of 10 exceeded",
+			usage:  py.BudgetUsage{Calls: 11},
+		},
+	} {
+		usage, err := py.RunWithBudget(tt.budget, func() error { return interp.Exec(tt.src) })
+		var exceeded *py.BudgetExceededError
+		if !errors.As(err, &exceeded) {
+			t.Errorf("%s: RunWithBudget returned %v, want a *BudgetExceededError", tt.name, err)
+			continue
+		}
+		if exceeded.Type != "BudgetExceeded" || exceeded.Message != tt.msg || exceeded.Traceback == "" {
+			t.Errorf("%s: raised %s: %s", tt.name, exceeded.Type, exceeded.Message)
+		}
+		if exceeded.Budget != tt.budget || exceeded.Usage != tt.usage || usage != tt.usage {
+			t.Errorf("%s: error has budget %+v and usage %+v, returned usage %+v, want %+v", tt.name, exceeded.Budget, exceeded.Usage, usage, tt.usage)
+		}
+		var exc *py.Exception
+		if !errors.As(err, &exc) || exc.Type != "BudgetExceeded" {
+			t.Errorf("%s: %v doesn't unwrap to an *Exception", tt.name, err)
+		}
+	}
+
+	// The hooks are removed afterwards.
+	gogopythontest.RequireExec(t, interp, "for i in range(100):\n    f()")
+}
@@ -0,0 +1,184 @@
+package gogopython
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+)
+
+// purego has a hard, process-wide limit on the number of callbacks that can
+// ever be created. Instead of creating one per Go func, all Go funcs exposed
+// to Python share a small fixed set of trampolines, one per calling
+// convention. Each Python function object gets a capsule as its "self" that
+// carries the id of the Go func to dispatch to.
+//
+// The capsule's destructor removes the registry entry once Python collects
+// the function object.
+
+// Name for capsules carrying callback ids. Python keeps a pointer to this,
+// so it must be NUL-terminated and live forever.
+var callbackCapsuleName = []byte("gogopython.callback\x00")
+
+// callback is a registered Go func callable from Python.
+type callback struct {
+	id   uintptr
+	name []byte      // NUL-terminated copy of the function name.
+	self PyObjectPtr // The self object requested by the caller, if any.
+
+	// The method definition handed to Python. It must not move or be
+	// collected while the function object is alive.
+	def    PyMethodDef
+	pinner runtime.Pinner
+
+	varargs  func(self, args PyObjectPtr) PyObjectPtr
+	keywords func(self, args, kwargs PyObjectPtr) PyObjectPtr
+	fast     func(self PyObjectPtr, args *PyObjectPtr, nargs int64) PyObjectPtr
+}
+
+var callbacks = struct {
+	sync.Mutex
+	next    uintptr
+	entries map[uintptr]*callback
+}{entries: make(map[uintptr]*callback)}
+
+var trampolines struct {
+	once       sync.Once
+	varargs    PyCFunction
+	keywords   PyCFunction
+	fast       PyCFunction
+	destructor PyCapsuleDestructor
+}
+
+func initTrampolines() {
+	trampolines.once.Do(func() {
+		trampolines.varargs = purego.NewCallback(varargsTrampoline)
+		trampolines.keywords = purego.NewCallback(keywordsTrampoline)
+		trampolines.fast = purego.NewCallback(fastTrampoline)
+		trampolines.destructor = purego.NewCallback(destroyCallback)
+	})
+}
+
+// newCallback creates a Python function object dispatching to cb using the
+// given calling convention.
+func newCallback(name string, self PyObjectPtr, flags MethodFlags, cb *callback) PyObjectPtr {
+	initTrampolines()
+
+	cb.name = append([]byte(name), 0)
+	cb.self = self
+	cb.def = PyMethodDef{
+		Name:  &cb.name[0],
+		Flags: flags,
+	}
+	switch flags {
+	case PyCFunctionDefault:
+		cb.def.Method = trampolines.varargs
+	case PyCFunctionWithKeywords:
+		cb.def.Method = trampolines.keywords
+	case PyCFunctionFast:
+		cb.def.Method = trampolines.fast
+	default:
+		panic(fmt.Sprintf("unsupported method flags: 0x%x", flags))
+	}
+	cb.pinner.Pin(&cb.def)
+	cb.pinner.Pin(&cb.name[0])
+
+	callbacks.Lock()
+	callbacks.next++
+	cb.id = callbacks.next
+	callbacks.entries[cb.id] = cb
+	callbacks.Unlock()
+
+	if self != NullPyObjectPtr {
+		Py_IncRef(self)
+	}
+
+	capsule := PyCapsule_New(cb.id, &callbackCapsuleName[0], trampolines.destructor)
+	if capsule == NullPyObjectPtr {
+		releaseCallback(cb.id)
+		return NullPyObjectPtr
+	}
+
+	// On success the function object holds the only reference to the
+	// capsule. On failure, this destroys the capsule and our entry.
+	fn := PyCFunction_NewEx(&cb.def, capsule, NullPyObjectPtr)
+	Py_DecRef(capsule)
+	return fn
+}
+
+// lookupCallback finds the callback associated with a trampoline's self.
+func lookupCallback(capsule PyObjectPtr) *callback {
+	id := PyCapsule_GetPointer(capsule, &callbackCapsuleName[0])
+	if id == 0 {
+		return nil
+	}
+	callbacks.Lock()
+	defer callbacks.Unlock()
+	return callbacks.entries[id]
+}
+
+// releaseCallback removes a callback from the registry, releasing the
+// resources it holds.
+func releaseCallback(id uintptr) {
+	callbacks.Lock()
+	cb, ok := callbacks.entries[id]
+	delete(callbacks.entries, id)
+	callbacks.Unlock()
+
+	if !ok {
+		return
+	}
+	if cb.self != NullPyObjectPtr {
+		Py_DecRef(cb.self)
+	}
+	cb.pinner.Unpin()
+}
+
+// recoverCallback converts a panic in a Go callback into a Python exception
+// so it never unwinds through the interpreter.
+func recoverCallback(cb *callback, result *PyObjectPtr) {
+	if r := recover(); r != nil {
+		name := "callback"
+		if cb != nil {
+			name = unsafe.String(&cb.name[0], len(cb.name)-1)
+		}
+		PyErr_SetString(PyExc_RuntimeError, fmt.Sprintf("%s() panicked: %v", name, r))
+		*result = NullPyObjectPtr
+	}
+}
+
+func varargsTrampoline(capsule, args PyObjectPtr) (result PyObjectPtr) {
+	defer trackCallback()()
+	cb := lookupCallback(capsule)
+	defer recoverCallback(cb, &result)
+	if cb == nil {
+		panic("unknown Go callback")
+	}
+	return cb.varargs(cb.self, args)
+}
+
+func keywordsTrampoline(capsule, args, kwargs PyObjectPtr) (result PyObjectPtr) {
+	defer trackCallback()()
+	cb := lookupCallback(capsule)
+	defer recoverCallback(cb, &result)
+	if cb == nil {
+		panic("unknown Go callback")
+	}
+	return cb.keywords(cb.self, args, kwargs)
+}
+
+func fastTrampoline(capsule PyObjectPtr, args *PyObjectPtr, nargs int64) (result PyObjectPtr) {
+	defer trackCallback()()
+	cb := lookupCallback(capsule)
+	defer recoverCallback(cb, &result)
+	if cb == nil {
+		panic("unknown Go callback")
+	}
+	return cb.fast(cb.self, args, nargs)
+}
+
+func destroyCallback(capsule PyObjectPtr) {
+	releaseCallback(PyCapsule_GetPointer(capsule, &callbackCapsuleName[0]))
+}
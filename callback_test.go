@@ -0,0 +1,51 @@
+package gogopython_test
+
+import (
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+func TestCallbackLimit(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	// purego can only ever create 2000 callbacks.
+	for i := range int64(3000) {
+		fn := py.NewFunction("f", py.NullPyObjectPtr, func(_, _ py.PyObjectPtr) py.PyObjectPtr {
+			return py.PyLong_FromLong(i)
+		})
+		setGlobal(t, interp, "f", fn)
+		gogopythontest.RequireEval(t, interp, "f()", i)
+	}
+}
+
+func TestCallbackSelf(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+	gogopythontest.RequireExec(t, interp, "import sys\no = object()")
+	self := py.PyDict_GetItemString(interp.Globals(), "o")
+	before := py.PyLong_AsLong(gogopythontest.Eval(t, interp, "sys.getrefcount(o)"))
+
+	setGlobal(t, interp, "f", py.NewFunction("f", self, func(s, _ py.PyObjectPtr) py.PyObjectPtr {
+		py.Py_IncRef(s)
+		return s
+	}))
+	gogopythontest.RequireEval(t, interp, "f() is o", true)
+	gogopythontest.RequireEval(t, interp, "sys.getrefcount(o)", before+1)
+
+	// Collecting the function releases its self.
+	gogopythontest.RequireExec(t, interp, "del f")
+	gogopythontest.RequireEval(t, interp, "sys.getrefcount(o)", before)
+}
+
+func TestCallbackPanic(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	setGlobal(t, interp, "f", py.NewFunction("f", py.NullPyObjectPtr, func(_, _ py.PyObjectPtr) py.PyObjectPtr {
+		panic("boom")
+	}))
+	exc := gogopythontest.RequireRaises(t, interp, "f()", "RuntimeError")
+	if exc.Message != "f() panicked: boom" {
+		t.Errorf("panic raised %q", exc.Message)
+	}
+}
@@ -0,0 +1,101 @@
+package gogopython
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/ebitengine/purego"
+)
+
+// Python keeps a pointer to a capsule's name for the life of the capsule, so
+// names are interned as NUL-terminated strings that are never freed.
+var capsuleNames = struct {
+	sync.Mutex
+	names map[string][]byte
+}{names: make(map[string][]byte)}
+
+func capsuleName(name string) *byte {
+	capsuleNames.Lock()
+	defer capsuleNames.Unlock()
+	b, ok := capsuleNames.names[name]
+	if !ok {
+		b = append([]byte(name), 0)
+		capsuleNames.names[name] = b
+	}
+	return &b[0]
+}
+
+var capsuleDestructor struct {
+	once sync.Once
+	fn   PyCapsuleDestructor
+}
+
+// NewCapsule stores the Go value v in a new Python capsule with the given
+// name, allowing Python code to carry it around and hand it back to Go.
+//
+// By convention the name is the dotted path of where the capsule can be
+// found, e.g. "mymodule.connection". The Go value is released when Python
+// collects the capsule.
+//
+// Returns a new reference. Requires the caller to hold the GIL.
+func NewCapsule(name string, v any) (PyObjectPtr, error) {
+	capsuleDestructor.once.Do(func() {
+		capsuleDestructor.fn = purego.NewCallback(destroyCapsule)
+	})
+
+	h := newHandle(v)
+	capsule := PyCapsule_New(h, capsuleName(name), capsuleDestructor.fn)
+	if capsule == NullPyObjectPtr {
+		PyErr_Clear()
+		releaseHandle(h)
+		return NullPyObjectPtr, fmt.Errorf("failed to create capsule %s", name)
+	}
+	return capsule, nil
+}
+
+// CapsuleValue returns the Go value stored by NewCapsule in a capsule with
+// the given name.
+//
+// An error is returned if obj is not a capsule with a matching name or if
+// the value is not a T.
+//
+// Requires the caller to hold the GIL.
+func CapsuleValue[T any](obj PyObjectPtr, name string) (T, error) {
+	var zero T
+	cname := capsuleName(name)
+	if PyCapsule_IsValid(obj, cname) != 1 {
+		return zero, fmt.Errorf("expected capsule %s, got %s", name, TypeName(obj))
+	}
+	h := PyCapsule_GetPointer(obj, cname)
+	if h == 0 {
+		PyErr_Clear()
+		return zero, fmt.Errorf("invalid capsule %s", name)
+	}
+	v, ok := handleValue(h)
+	if !ok {
+		return zero, fmt.Errorf("capsule %s has no Go value", name)
+	}
+	t, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("capsule %s holds %T, not %s", name, v, reflect.TypeFor[T]())
+	}
+	return t, nil
+}
+
+func destroyCapsule(capsule PyObjectPtr) {
+	// The capsule may be freed while an exception is being raised, which
+	// must survive any error raised here.
+	pending := PyErr_GetRaisedException()
+	h := PyCapsule_GetPointer(capsule, PyCapsule_GetName(capsule))
+	PyErr_SetRaisedException(pending)
+	if h == 0 {
+		return
+	}
+	if v, ok := handleValue(h); ok {
+		if f, ok := v.(finalizer); ok {
+			f.finalize()
+		}
+	}
+	releaseHandle(h)
+}
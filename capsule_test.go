@@ -0,0 +1,59 @@
+package gogopython_test
+
+import (
+	"errors"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+type connection struct{ addr string }
+
+func TestCapsuleValue(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	conn := &connection{addr: "localhost"}
+	capsule, err := py.NewCapsule("test.connection", conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setGlobal(t, interp, "conn", capsule)
+
+	// The capsule survives a trip through Python.
+	obj := gogopythontest.Eval(t, interp, "[conn][0]")
+	got, err := py.CapsuleValue[*connection](obj, "test.connection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != conn {
+		t.Errorf("CapsuleValue returned %v, want %v", got, conn)
+	}
+
+	if _, err := py.CapsuleValue[*connection](obj, "test.other"); err == nil {
+		t.Error("CapsuleValue accepted another name")
+	}
+	if _, err := py.CapsuleValue[string](obj, "test.connection"); err == nil {
+		t.Error("CapsuleValue accepted another type")
+	}
+	if _, err := py.CapsuleValue[*connection](py.Py_None, "test.connection"); err == nil {
+		t.Error("CapsuleValue accepted None")
+	}
+	gogopythontest.RequireNoException(t)
+}
+
+func TestCapsuleKeepsPendingException(t *testing.T) {
+	gogopythontest.NewInterpreter(t)
+
+	capsule, err := py.NewCapsule("test.pending", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	py.PyErr_SetString(py.PyExc_ValueError, "pending")
+	py.Py_DecRef(capsule)
+
+	var exc *py.Exception
+	if err := py.FetchError(); !errors.As(err, &exc) || exc.Type != "ValueError" {
+		t.Errorf("exception after freeing a capsule = %v, want the pending ValueError", err)
+	}
+}
@@ -0,0 +1,549 @@
+package gogopython
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+)
+
+// Instances of Go-backed classes are a bare PyObject followed by a handle to
+// the Go value, i.e. the equivalent of:
+//
+//	typedef struct {
+//		PyObject_HEAD
+//		uintptr_t handle;
+//	} GoObject;
+const (
+	objectTypeOffset   = 8 // Offset of ob_type in PyObject_HEAD.
+	objectHeadSize     = 16
+	objectHandleOffset = objectHeadSize
+	goObjectSize       = objectHeadSize + 8
+)
+
+// ClassBuilder defines a Python class whose instances wrap a Go value.
+//
+// Errors from the builder methods are collected and reported by Build.
+type ClassBuilder struct {
+	name    string
+	doc     string
+	ctx     context.Context
+	ptrType reflect.Type // *T for the Go type T backing instances.
+	ctor    *export
+	methods []classMethod
+	props   []*classProperty
+	slots   []PyTypeSlot // Additional type slots for internal classes.
+	errs    []error
+}
+
+type classMethod struct {
+	name string
+	e    *export
+}
+
+// classProperty is a Python property implemented by Go getter and setter
+// funcs.
+type classProperty struct {
+	name  string
+	class *Class
+	get   reflect.Value
+	set   reflect.Value // Invalid if read-only.
+}
+
+// NewClass starts the definition of a Python class with the given name
+// whose instances wrap a *T.
+func NewClass[T any](name string) *ClassBuilder {
+	b := &ClassBuilder{name: name, ptrType: reflect.TypeFor[*T]()}
+	if name == "" || strings.Contains(name, ".") {
+		b.errs = append(b.errs, fmt.Errorf("invalid class name '%s'", name))
+	}
+	return b
+}
+
+// Doc sets the class docstring.
+func (b *ClassBuilder) Doc(doc string) *ClassBuilder {
+	b.doc = doc
+	return b
+}
+
+// Context sets the context passed to the constructor and methods taking a
+// context.Context, instead of context.Background().
+func (b *ClassBuilder) Context(ctx context.Context) *ClassBuilder {
+	b.ctx = ctx
+	return b
+}
+
+// Constructor allows creating instances from Python by calling the class.
+// The fn must return a *T and optionally an error, and its arguments are
+// converted as described by ExportFunc.
+//
+// Without a constructor, instances can only be created from Go via Wrap.
+func (b *ClassBuilder) Constructor(fn any) *ClassBuilder {
+	e, err := newExport(b.name, fn)
+	if err != nil {
+		b.errs = append(b.errs, fmt.Errorf("class %s: %w", b.name, err))
+		return b
+	}
+	t := reflect.TypeOf(fn)
+	if t.NumOut() == 0 || t.Out(0) != b.ptrType || t.NumOut() > 2 || (t.NumOut() == 2 && !e.hasErr) {
+		b.errs = append(b.errs, fmt.Errorf("class %s: constructor must return (%s, error)", b.name, b.ptrType))
+		return b
+	}
+	b.ctor = e
+	return b
+}
+
+// Method adds a method to the class. The fn must take the *T receiver as its
+// first parameter, e.g. a method expression like (*T).Method. Remaining
+// arguments and results are converted as described by ExportFunc.
+func (b *ClassBuilder) Method(name string, fn any) *ClassBuilder {
+	e, err := newMethodExport(name, fn, b.ptrType, nil)
+	if err != nil {
+		b.errs = append(b.errs, fmt.Errorf("class %s: %w", b.name, err))
+		return b
+	}
+	b.methods = append(b.methods, classMethod{name: name, e: e})
+	return b
+}
+
+// Methods adds all exported methods of *T to the class, using snake_case
+// versions of the Go method names.
+func (b *ClassBuilder) Methods() *ClassBuilder {
+	for i := 0; i < b.ptrType.NumMethod(); i++ {
+		m := b.ptrType.Method(i)
+		b.Method(snakeCase(m.Name), m.Func.Interface())
+	}
+	return b
+}
+
+// Property adds a property to the class. The get func must have the form
+// func(*T) V. The set func must have the form func(*T, V) with an optional
+// error result, or be nil for a read-only property.
+func (b *ClassBuilder) Property(name string, get, set any) *ClassBuilder {
+	p := &classProperty{name: name, get: reflect.ValueOf(get)}
+	gt := p.get.Type()
+	if gt.Kind() != reflect.Func || gt.NumIn() != 1 || gt.In(0) != b.ptrType ||
+		gt.NumOut() != 1 || !convertible(gt.Out(0)) {
+		b.errs = append(b.errs, fmt.Errorf("class %s: getter for %s must be func(%s) V", b.name, name, b.ptrType))
+		return b
+	}
+	if set != nil {
+		p.set = reflect.ValueOf(set)
+		st := p.set.Type()
+		if st.Kind() != reflect.Func || st.NumIn() != 2 || st.In(0) != b.ptrType || st.In(1) != gt.Out(0) ||
+			st.NumOut() > 1 || (st.NumOut() == 1 && st.Out(0) != errorType) {
+			b.errs = append(b.errs, fmt.Errorf("class %s: setter for %s must be func(%s, %s) error", b.name, name, b.ptrType, gt.Out(0)))
+			return b
+		}
+	}
+	b.props = append(b.props, p)
+	return b
+}
+
+// Build validates the class definition and creates the PyTypeSpec used to
+// create the class in each interpreter.
+func (b *ClassBuilder) Build() (*Class, error) {
+	if err := errors.Join(b.errs...); err != nil {
+		return nil, err
+	}
+	initClassTrampolines()
+
+	c := &Class{builder: b, types: make(map[int64]PyObjectPtr)}
+	c.name = append([]byte(b.name), 0)
+	c.pinner.Pin(&c.name[0])
+
+	if b.ctor != nil {
+		b.ctor.ctx = b.ctx
+	}
+	for _, m := range b.methods {
+		m.e.receiver = c.receiver
+		m.e.ctx = b.ctx
+	}
+
+	c.getset = make([]PyGetSetDef, len(b.props)+1)
+	for i, p := range b.props {
+		p.class = c
+		name := append([]byte(p.name), 0)
+		c.pinner.Pin(&name[0])
+		c.getset[i] = PyGetSetDef{
+			Name:    &name[0],
+			Get:     classTrampolines.getter,
+			Closure: newHandle(p),
+		}
+		if p.set.IsValid() {
+			c.getset[i].Set = classTrampolines.setter
+		}
+	}
+	c.pinner.Pin(&c.getset[0])
+
+	c.slots = []PyTypeSlot{
+		{Slot: TypeSlotDealloc, Value: classTrampolines.dealloc},
+		{Slot: TypeSlotGetSet, Value: uintptr(unsafe.Pointer(&c.getset[0]))},
+	}
+	flags := TypeFlagsDefault
+	if b.ctor != nil {
+		c.slots = append(c.slots, PyTypeSlot{Slot: TypeSlotNew, Value: classTrampolines.new})
+	} else {
+		flags |= TypeFlagsDisallowInstantiation
+	}
+	c.slots = append(c.slots, b.slots...)
+	if b.doc != "" {
+		c.doc = append([]byte(b.doc), 0)
+		c.pinner.Pin(&c.doc[0])
+		c.slots = append(c.slots, PyTypeSlot{Slot: TypeSlotDoc, Value: uintptr(unsafe.Pointer(&c.doc[0]))})
+	}
+	c.slots = append(c.slots, PyTypeSlot{})
+	c.pinner.Pin(&c.slots[0])
+
+	c.spec = PyTypeSpec{
+		Name:      &c.name[0],
+		BasicSize: goObjectSize,
+		Flags:     flags,
+		Slots:     &c.slots[0],
+	}
+	c.pinner.Pin(&c.spec)
+	return c, nil
+}
+
+// Class is a built Python class backed by a Go type.
+//
+// Each interpreter gets its own type object, created on first use and
+// released when the interpreter ends. Like modules, classes are never freed.
+type Class struct {
+	builder *ClassBuilder
+
+	spec   PyTypeSpec
+	slots  []PyTypeSlot
+	getset []PyGetSetDef
+	name   []byte
+	doc    []byte
+	pinner runtime.Pinner
+
+	mu    sync.Mutex
+	types map[int64]PyObjectPtr // Type objects keyed by interpreter id.
+}
+
+// Name returns the name of the class.
+func (c *Class) Name() string {
+	return c.builder.name
+}
+
+// TypeObject returns a borrowed reference to the class's type object in the
+// current interpreter, creating it if needed.
+//
+// Requires the caller to hold the GIL.
+func (c *Class) TypeObject() (PyObjectPtr, error) {
+	return c.typeObject(NullPyObjectPtr)
+}
+
+// typeObject finds or creates the type object for the current interpreter,
+// associating it with module if one is provided.
+func (c *Class) typeObject(module PyObjectPtr) (PyObjectPtr, error) {
+	id := PyInterpreterState_GetID(PyInterpreterState_Get())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tp, ok := c.types[id]; ok {
+		return tp, nil
+	}
+
+	var tp PyObjectPtr
+	if module != NullPyObjectPtr {
+		tp = PyType_FromModuleAndSpec(module, &c.spec, NullPyObjectPtr)
+	} else {
+		tp = PyType_FromSpec(&c.spec)
+	}
+	if tp == NullPyObjectPtr {
+		PyErr_Clear()
+		return NullPyObjectPtr, fmt.Errorf("failed to create type for class %s", c.builder.name)
+	}
+	if module != NullPyObjectPtr {
+		name := PyObject_GetAttrString(module, "__name__")
+		if name != NullPyObjectPtr {
+			PyObject_SetAttrString(tp, "__module__", name)
+			Py_DecRef(name)
+		}
+		PyErr_Clear()
+	}
+
+	for _, m := range c.builder.methods {
+		fn := NewFunctionWithKeywords(m.name, NullPyObjectPtr, m.e.call)
+		if fn == NullPyObjectPtr {
+			PyErr_Clear()
+			Py_DecRef(tp)
+			return NullPyObjectPtr, fmt.Errorf("failed to create method %s.%s", c.builder.name, m.name)
+		}
+		method := PyInstanceMethod_New(fn)
+		Py_DecRef(fn)
+		if method == NullPyObjectPtr || PyObject_SetAttrString(tp, m.name, method) != 0 {
+			PyErr_Clear()
+			Py_DecRef(method)
+			Py_DecRef(tp)
+			return NullPyObjectPtr, fmt.Errorf("failed to add method %s.%s", c.builder.name, m.name)
+		}
+		Py_DecRef(method)
+	}
+
+	// Hold our reference for the life of the interpreter.
+	if err := onInterpreterEnd(func() { c.release(id, tp) }); err != nil {
+		Py_DecRef(tp)
+		return NullPyObjectPtr, err
+	}
+	c.types[id] = tp
+	classTypes.Lock()
+	classTypes.classes[tp] = c
+	classTypes.Unlock()
+	return tp, nil
+}
+
+// release forgets the type object of an interpreter that ended.
+func (c *Class) release(id int64, tp PyObjectPtr) {
+	c.mu.Lock()
+	delete(c.types, id)
+	c.mu.Unlock()
+	classTypes.Lock()
+	delete(classTypes.classes, tp)
+	classTypes.Unlock()
+	Py_DecRef(tp)
+}
+
+// Wrap creates a new instance of the class in the current interpreter that
+// wraps v, which must be a *T for the class's Go type T.
+//
+// Returns a new reference. Requires the caller to hold the GIL.
+func (c *Class) Wrap(v any) (PyObjectPtr, error) {
+	if reflect.TypeOf(v) != c.builder.ptrType {
+		return NullPyObjectPtr, fmt.Errorf("class %s wraps %s, not %T", c.builder.name, c.builder.ptrType, v)
+	}
+	tp, err := c.TypeObject()
+	if err != nil {
+		return NullPyObjectPtr, err
+	}
+	return c.alloc(PyTypeObjectPtr(tp), v)
+}
+
+// alloc creates an instance of tp holding v.
+func (c *Class) alloc(tp PyTypeObjectPtr, v any) (PyObjectPtr, error) {
+	obj := PyType_GenericAlloc(tp, 0)
+	if obj == NullPyObjectPtr {
+		PyErr_Clear()
+		return NullPyObjectPtr, fmt.Errorf("failed to allocate instance of %s", c.builder.name)
+	}
+	*objectField(obj, objectHandleOffset) = newHandle(v)
+	return obj, nil
+}
+
+// value returns the Go value wrapped by obj, if obj is an instance of the
+// class in the current interpreter.
+func (c *Class) value(obj PyObjectPtr) (any, error) {
+	tp, err := c.TypeObject()
+	if err != nil {
+		return nil, err
+	}
+	if PyObject_IsInstance(obj, tp) != 1 {
+		PyErr_Clear()
+		return nil, fmt.Errorf("expected %s, got %s", c.builder.name, TypeName(obj))
+	}
+	v, ok := handleValue(*objectField(obj, objectHandleOffset))
+	if !ok {
+		return nil, fmt.Errorf("%s instance has no Go value", c.builder.name)
+	}
+	return v, nil
+}
+
+// receiver converts a Python self into the Go receiver for methods.
+func (c *Class) receiver(self PyObjectPtr) (reflect.Value, error) {
+	v, err := c.value(self)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(v), nil
+}
+
+// ClassValue returns the Go value wrapped by obj, an instance of class c.
+//
+// Requires the caller to hold the GIL.
+func ClassValue[T any](c *Class, obj PyObjectPtr) (*T, error) {
+	v, err := c.value(obj)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := v.(*T)
+	if !ok {
+		return nil, fmt.Errorf("class %s wraps %T, not *%s", c.builder.name, v, reflect.TypeFor[T]())
+	}
+	return t, nil
+}
+
+// objectField returns a pointer to the pointer-sized field at offset in the
+// memory of a Python object.
+func objectField(obj PyObjectPtr, offset uintptr) *uintptr {
+	p := uintptr(obj) + offset
+	return *(**uintptr)(unsafe.Pointer(&p))
+}
+
+// snakeCase converts a Go identifier like "AllowN" into "allow_n".
+func snakeCase(name string) string {
+	var sb strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word unless we're in the middle of an acronym.
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// Classes by their type objects in all interpreters. Go classes can't be
+// subclassed, so instances' types are always found.
+var classTypes = struct {
+	sync.Mutex
+	classes map[PyObjectPtr]*Class
+}{classes: make(map[PyObjectPtr]*Class)}
+
+var classTrampolines struct {
+	once    sync.Once
+	dealloc uintptr
+	new     uintptr
+	getter  uintptr
+	setter  uintptr
+}
+
+func initClassTrampolines() {
+	classTrampolines.once.Do(func() {
+		classTrampolines.dealloc = purego.NewCallback(deallocObject)
+		classTrampolines.new = purego.NewCallback(newObject)
+		classTrampolines.getter = purego.NewCallback(getProperty)
+		classTrampolines.setter = purego.NewCallback(setProperty)
+	})
+}
+
+// lookupClass finds the Class of a type object.
+func lookupClass(tp PyObjectPtr) (*Class, error) {
+	classTypes.Lock()
+	defer classTypes.Unlock()
+	c, ok := classTypes.classes[tp]
+	if !ok {
+		return nil, errors.New("type is not a Go class")
+	}
+	return c, nil
+}
+
+// finalizer is implemented by internal Go values that need to release
+// resources when their Python object is freed.
+type finalizer interface {
+	finalize()
+}
+
+func deallocObject(self PyObjectPtr) {
+	h := *objectField(self, objectHandleOffset)
+	if v, ok := handleValue(h); ok {
+		if f, ok := v.(finalizer); ok {
+			f.finalize()
+		}
+	}
+	releaseHandle(h)
+
+	// Instances of heap types hold a reference to their type.
+	tp := PyObjectPtr(*objectField(self, objectTypeOffset))
+	PyObject_Free(self)
+	Py_DecRef(tp)
+}
+
+func newObject(tp, args, kwargs PyObjectPtr) (result PyObjectPtr) {
+	defer recoverCallback(nil, &result)
+
+	c, err := lookupClass(tp)
+	if err != nil {
+		PyErr_SetString(PyExc_TypeError, err.Error())
+		return NullPyObjectPtr
+	}
+	in, err := c.builder.ctor.arguments(args, kwargs)
+	if err != nil {
+		PyErr_SetString(PyExc_TypeError, err.Error())
+		return NullPyObjectPtr
+	}
+	out := c.builder.ctor.fn.Call(in)
+	if c.builder.ctor.hasErr {
+		if err, _ := out[1].Interface().(error); err != nil {
+			PyErr_SetString(PyExc_RuntimeError, err.Error())
+			return NullPyObjectPtr
+		}
+	}
+	if out[0].IsNil() {
+		PyErr_SetString(PyExc_RuntimeError, fmt.Sprintf("%s constructor returned nil", c.builder.name))
+		return NullPyObjectPtr
+	}
+
+	obj, err := c.alloc(PyTypeObjectPtr(tp), out[0].Interface())
+	if err != nil {
+		PyErr_SetString(PyExc_MemoryError, err.Error())
+		return NullPyObjectPtr
+	}
+	return obj
+}
+
+func getProperty(self PyObjectPtr, closure uintptr) (result PyObjectPtr) {
+	defer recoverCallback(nil, &result)
+
+	v, _ := handleValue(closure)
+	p := v.(*classProperty)
+	recv, err := p.class.receiver(self)
+	if err != nil {
+		PyErr_SetString(PyExc_TypeError, err.Error())
+		return NullPyObjectPtr
+	}
+	obj, err := toPython(p.get.Call([]reflect.Value{recv})[0])
+	if err != nil {
+		PyErr_SetString(PyExc_TypeError, fmt.Sprintf("%s: %v", p.name, err))
+		return NullPyObjectPtr
+	}
+	return obj
+}
+
+func setProperty(self, value PyObjectPtr, closure uintptr) (result int32) {
+	defer func() {
+		if r := recover(); r != nil {
+			PyErr_SetString(PyExc_RuntimeError, fmt.Sprintf("setter panicked: %v", r))
+			result = -1
+		}
+	}()
+
+	v, _ := handleValue(closure)
+	p := v.(*classProperty)
+	if value == NullPyObjectPtr {
+		PyErr_SetString(PyExc_AttributeError, fmt.Sprintf("cannot delete attribute '%s'", p.name))
+		return -1
+	}
+	recv, err := p.class.receiver(self)
+	if err != nil {
+		PyErr_SetString(PyExc_TypeError, err.Error())
+		return -1
+	}
+	val := reflect.New(p.set.Type().In(1)).Elem()
+	if err := fromPython(value, val); err != nil {
+		PyErr_SetString(PyExc_TypeError, fmt.Sprintf("%s: %v", p.name, err))
+		return -1
+	}
+	out := p.set.Call([]reflect.Value{recv, val})
+	if len(out) == 1 {
+		if err, _ := out[0].Interface().(error); err != nil {
+			PyErr_SetString(PyExc_ValueError, err.Error())
+			return -1
+		}
+	}
+	return 0
+}
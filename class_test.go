@@ -0,0 +1,135 @@
+package gogopython_test
+
+import (
+	"errors"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+type limiter struct {
+	rate int
+	used int
+}
+
+func (l *limiter) AllowN(n int) bool {
+	if l.used+n > l.rate {
+		return false
+	}
+	l.used += n
+	return true
+}
+
+func (l *limiter) Reset() { l.used = 0 }
+
+func newLimiterClass(t *testing.T) *py.Class {
+	t.Helper()
+	class, err := py.NewClass[limiter]("Limiter").
+		Doc("Limits things.").
+		Constructor(func(rate int) (*limiter, error) {
+			if rate < 0 {
+				return nil, errors.New("negative rate")
+			}
+			return &limiter{rate: rate}, nil
+		}).
+		Methods().
+		Method("remaining", func(l *limiter) int { return l.rate - l.used }).
+		Property("rate", func(l *limiter) int { return l.rate }, func(l *limiter, rate int) error {
+			if rate < 0 {
+				return errors.New("negative rate")
+			}
+			l.rate = rate
+			return nil
+		}).
+		Property("used", func(l *limiter) int { return l.used }, nil).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return class
+}
+
+func TestClassMethodsAndProperties(t *testing.T) {
+	// Types are cached per interpreter, so they outlive the test.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+	class := newLimiterClass(t)
+	tp, err := class.TypeObject()
+	if err != nil {
+		t.Fatal(err)
+	}
+	py.Py_IncRef(tp)
+	setGlobal(t, interp, class.Name(), tp)
+
+	gogopythontest.RequireExec(t, interp, "l = Limiter(3)")
+	gogopythontest.RequireEval(t, interp, "Limiter.__doc__", "Limits things.")
+	gogopythontest.RequireEval(t, interp, "[l.allow_n(2), l.allow_n(2), l.remaining()]", []any{true, false, int64(1)})
+	gogopythontest.RequireEval(t, interp, "l.used", 2)
+	gogopythontest.RequireExec(t, interp, "l.reset()\nl.rate = 5")
+	gogopythontest.RequireEval(t, interp, "(l.used, l.rate)", []int{0, 5})
+
+	exc := gogopythontest.RequireRaises(t, interp, "l.rate = -1", "ValueError")
+	if exc.Message != "negative rate" {
+		t.Errorf("setter error raised %q", exc.Message)
+	}
+	gogopythontest.RequireRaises(t, interp, "l.used = 1", "AttributeError")
+	gogopythontest.RequireRaises(t, interp, "l.rate = 'fast'", "TypeError")
+	gogopythontest.RequireRaises(t, interp, "Limiter(-1)", "RuntimeError")
+	gogopythontest.RequireRaises(t, interp, "Limiter.remaining(1)", "TypeError")
+
+	l, err := py.ClassValue[limiter](class, py.PyDict_GetItemString(interp.Globals(), "l"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.rate != 5 {
+		t.Errorf("ClassValue returned rate %d, want 5", l.rate)
+	}
+	if _, err := py.ClassValue[counter](class, py.PyDict_GetItemString(interp.Globals(), "l")); err == nil {
+		t.Error("ClassValue returned the wrong Go type")
+	}
+	if _, err := py.ClassValue[limiter](class, py.Py_None); err == nil {
+		t.Error("ClassValue accepted None")
+	}
+}
+
+func TestClassWrap(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+	class, err := py.NewClass[counter]("Counter").Methods().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &counter{n: 41}
+	obj, err := class.Wrap(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setGlobal(t, interp, "c", obj)
+	gogopythontest.RequireEval(t, interp, "c.inc()", 42)
+	if c.n != 42 {
+		t.Errorf("the wrapped value wasn't shared, n = %d", c.n)
+	}
+	if _, err := class.Wrap(counter{}); err == nil {
+		t.Error("Wrap accepted a value instead of a pointer")
+	}
+	// Without a constructor, instances can only come from Go.
+	gogopythontest.RequireRaises(t, interp, "type(c)()", "TypeError")
+}
+
+func TestClassBuilderErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		builder *py.ClassBuilder
+	}{
+		{"empty name", py.NewClass[counter]("")},
+		{"dotted name", py.NewClass[counter]("a.B")},
+		{"constructor of another type", py.NewClass[counter]("C").Constructor(func() *limiter { return nil })},
+		{"method without receiver", py.NewClass[counter]("C").Method("m", func() {})},
+		{"getter with arguments", py.NewClass[counter]("C").Property("p", func(*counter, int) int { return 0 }, nil)},
+		{"setter of another type", py.NewClass[counter]("C").Property("p", func(*counter) int { return 0 }, func(*counter, string) {})},
+	} {
+		if _, err := tt.builder.Build(); err == nil {
+			t.Errorf("%s: Build succeeded", tt.name)
+		}
+	}
+}
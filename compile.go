@@ -0,0 +1,140 @@
+package gogopython
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CompileOptions are named options for Compile.
+type CompileOptions struct {
+	// Future lists "from __future__ import" features to enable, e.g.
+	// "annotations".
+	Future []string
+
+	OnlyAST            bool // Return an ast.Module instead of a code object.
+	TypeComments       bool // Include type comments in the AST.
+	AllowTopLevelAwait bool // Allow await outside of async functions.
+
+	// FeatureVersion is the minor version of Python 3 whose grammar is
+	// accepted, like ast.parse's feature_version. Zero means the running
+	// version. Python only checks it when producing an AST, so it requires
+	// OnlyAST.
+	FeatureVersion int
+
+	// Optimize is the optimization level. Zero means NoOptimization, use
+	// UseInterpreterLevel for the interpreter's setting.
+	Optimize OptimizeLevel
+}
+
+// futureFeatures maps the names in the __future__ module to their flags.
+// Features that are always enabled in Python 3 have no flag.
+var futureFeatures = map[string]CompilerFlag{
+	"nested_scopes":    0,
+	"generators":       0,
+	"division":         FutureDivision,
+	"absolute_import":  FutureAbsoluteImport,
+	"with_statement":   FutureWithStatement,
+	"print_function":   FuturePrintFunction,
+	"unicode_literals": FutureUnicodeLiterals,
+	"barry_as_FLUFL":   FutureBarryAsBDFL,
+	"generator_stop":   FutureGeneratorStop,
+	"annotations":      FutureAnnotations,
+}
+
+// Oldest feature version supported by the parser, documented as (3, 4) for
+// ast.parse.
+const minFeatureVersion = 4
+
+// Compile compiles Python source with the given options. The mode is one of
+// PyFileInput, PyEvalInput or PySingleInput, or PyFuncTypeInput when only
+// producing an AST.
+//
+// Returns a new reference to a code object, which can be converted to a
+// PyCodeObjectPtr, or an ast.Module if opts.OnlyAST is set. Code compiled
+// with AllowTopLevelAwait may return a coroutine when evaluated, which can
+// be run with an EventLoop.
+//
+// Invalid options are reported without calling Python, while a syntax error
+// in src is returned as a *SyntaxError. Requires the caller to hold the GIL.
+func Compile(src, filename string, mode StartToken, opts CompileOptions) (PyObjectPtr, error) {
+	flags, err := opts.flags(mode)
+	if err != nil {
+		return NullPyObjectPtr, err
+	}
+	code := Py_CompileStringExFlags(src, filename, mode, &flags, opts.Optimize)
+	if code == NullPyCodeObjectPtr {
+		return NullPyObjectPtr, FetchError()
+	}
+	return PyObjectPtr(code), nil
+}
+
+// flags validates the options and converts them to PyCompilerFlags.
+func (opts CompileOptions) flags(mode StartToken) (PyCompilerFlags, error) {
+	var flags PyCompilerFlags
+
+	switch mode {
+	case PyFileInput, PyEvalInput, PySingleInput:
+	case PyFuncTypeInput:
+		if !opts.OnlyAST {
+			return flags, fmt.Errorf("mode PyFuncTypeInput requires OnlyAST")
+		}
+	default:
+		return flags, fmt.Errorf("invalid compile mode %d", mode)
+	}
+
+	for _, name := range opts.Future {
+		flag, ok := futureFeatures[name]
+		if !ok {
+			return flags, fmt.Errorf("unknown future feature '%s'", name)
+		}
+		flags.Flags |= flag
+	}
+	if opts.OnlyAST {
+		flags.Flags |= PyCF_OnlyAST
+	}
+	if opts.TypeComments {
+		if !opts.OnlyAST {
+			return flags, fmt.Errorf("TypeComments requires OnlyAST")
+		}
+		flags.Flags |= PyCF_TypeComments
+	}
+	if opts.AllowTopLevelAwait {
+		flags.Flags |= PyCF_AllowTopLevelAwait
+	}
+
+	current, err := pythonMinorVersion()
+	if err != nil {
+		return flags, err
+	}
+	flags.FeatureVersion = int32(current)
+	if v := opts.FeatureVersion; v != 0 {
+		if !opts.OnlyAST {
+			return flags, fmt.Errorf("FeatureVersion requires OnlyAST")
+		}
+		if v < minFeatureVersion || v > current {
+			return flags, fmt.Errorf("feature version 3.%d not in supported range 3.%d to 3.%d",
+				v, minFeatureVersion, current)
+		}
+		flags.FeatureVersion = int32(v)
+	}
+
+	if opts.Optimize < UseInterpreterLevel || opts.Optimize > RemoveDebugsAssertsAndDocstrings {
+		return flags, fmt.Errorf("invalid optimization level %d", opts.Optimize)
+	}
+	return flags, nil
+}
+
+// pythonMinorVersion parses the minor version from Py_GetVersion, e.g. 12
+// for "3.12.1 (main, ...)".
+func pythonMinorVersion() (int, error) {
+	version := Py_GetVersion()
+	_, rest, ok := strings.Cut(version, ".")
+	if ok {
+		minor, _, _ := strings.Cut(rest, ".")
+		if v, err := strconv.Atoi(minor); err == nil {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("unexpected Python version '%s'", version)
+}
@@ -0,0 +1,115 @@
+package gogopython_test
+
+import (
+	"errors"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// execCompiled compiles Python statements and runs them in the interpreter's
+// globals.
+func execCompiled(t *testing.T, interp *gogopythontest.Interpreter, src string, opts py.CompileOptions) {
+	t.Helper()
+	code, err := py.Compile(src, "<test>", py.PyFileInput, opts)
+	if err != nil {
+		t.Fatalf("Compile(%q): %v", src, err)
+	}
+	defer py.Py_DecRef(code)
+	res := py.PyEval_EvalCode(py.PyCodeObjectPtr(code), interp.Globals(), interp.Globals())
+	if res == py.NullPyObjectPtr {
+		t.Fatalf("running %q raised %v", src, py.FetchError())
+	}
+	py.Py_DecRef(res)
+}
+
+func TestCompileOptions(t *testing.T) {
+	// The AST types are created on first use.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{Preload: []string{"ast"}})
+
+	execCompiled(t, interp, "def f(x: Undefined): pass", py.CompileOptions{Future: []string{"annotations"}})
+	gogopythontest.RequireEval(t, interp, "f.__annotations__['x']", "Undefined")
+
+	// Asserts are removed when optimizing.
+	execCompiled(t, interp, "assert False", py.CompileOptions{Optimize: py.RemoveDebugsAndAsserts})
+	gogopythontest.RequireRaises(t, interp, "exec(compile('assert False', '<test>', 'exec'))", "AssertionError")
+
+	for _, tt := range []struct {
+		name string
+		src  string
+		mode py.StartToken
+		opts py.CompileOptions
+		want string
+	}{
+		{"ast", "x = 1", py.PyFileInput, py.CompileOptions{OnlyAST: true}, "Module"},
+		{"expression ast", "x + 1", py.PyEvalInput, py.CompileOptions{OnlyAST: true}, "Expression"},
+		{"function type", "(int) -> str", py.PyFuncTypeInput, py.CompileOptions{OnlyAST: true}, "FunctionType"},
+		{"top level await", "await x", py.PyFileInput, py.CompileOptions{AllowTopLevelAwait: true}, "code"},
+		{"feature version", "match x:\n    case 1: pass", py.PyFileInput, py.CompileOptions{OnlyAST: true, FeatureVersion: 10}, "Module"},
+	} {
+		obj, err := py.Compile(tt.src, "<test>", tt.mode, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := py.TypeName(obj); got != tt.want {
+			t.Errorf("%s: compiled to %s, want %s", tt.name, got, tt.want)
+		}
+		py.Py_DecRef(obj)
+	}
+
+	// Type comments are only kept when asked for.
+	typeComment := "x = [] # type: list[int]"
+	for _, keep := range []bool{false, true} {
+		mod, err := py.Compile(typeComment, "<test>", py.PyFileInput, py.CompileOptions{OnlyAST: true, TypeComments: keep})
+		if err != nil {
+			t.Fatal(err)
+		}
+		setGlobal(t, interp, "mod", mod)
+		gogopythontest.RequireEval(t, interp, "mod.body[0].type_comment is not None", keep)
+	}
+}
+
+func TestCompileRejects(t *testing.T) {
+	gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{Preload: []string{"ast"}})
+
+	for _, tt := range []struct {
+		name string
+		src  string
+		mode py.StartToken
+		opts py.CompileOptions
+	}{
+		{"await", "await x", py.PyFileInput, py.CompileOptions{}},
+		{"old feature version", "match x:\n    case 1: pass", py.PyFileInput, py.CompileOptions{OnlyAST: true, FeatureVersion: 9}},
+		{"future import too late", "x = 1\nfrom __future__ import annotations", py.PyFileInput, py.CompileOptions{}},
+	} {
+		var syntaxErr *py.SyntaxError
+		if _, err := py.Compile(tt.src, "<test>", tt.mode, tt.opts); !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: Compile returned %v, want a *SyntaxError", tt.name, err)
+		}
+	}
+
+	// Invalid options are caught before compiling.
+	for _, tt := range []struct {
+		name string
+		mode py.StartToken
+		opts py.CompileOptions
+	}{
+		{"mode", 0, py.CompileOptions{}},
+		{"function type without ast", py.PyFuncTypeInput, py.CompileOptions{}},
+		{"future feature", py.PyFileInput, py.CompileOptions{Future: []string{"braces"}}},
+		{"type comments without ast", py.PyFileInput, py.CompileOptions{TypeComments: true}},
+		{"feature version without ast", py.PyFileInput, py.CompileOptions{FeatureVersion: 10}},
+		{"feature version too old", py.PyFileInput, py.CompileOptions{OnlyAST: true, FeatureVersion: 3}},
+		{"feature version too new", py.PyFileInput, py.CompileOptions{OnlyAST: true, FeatureVersion: 99}},
+		{"optimization level", py.PyFileInput, py.CompileOptions{Optimize: 3}},
+	} {
+		_, err := py.Compile("x = 1", "<test>", tt.mode, tt.opts)
+		var syntaxErr *py.SyntaxError
+		if err == nil || errors.As(err, &syntaxErr) {
+			t.Errorf("invalid %s: Compile returned %v", tt.name, err)
+		}
+	}
+	gogopythontest.RequireNoException(t)
+}
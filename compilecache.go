@@ -0,0 +1,239 @@
+package gogopython
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"unsafe"
+)
+
+// CompileCache caches compiled code objects as marshal data so the same
+// source is only compiled once, no matter how many interpreters run it.
+//
+// Entries are keyed by a hash of the source, filename, start token,
+// optimization level and Python version, so a cache directory may be shared
+// by different Python builds. Entries are kept in memory and, if a directory
+// is given, written to disk to survive restarts.
+//
+// The memory used by entries isn't bounded unless SetMaxSize is called, so
+// caching many distinct sources, e.g. scripts submitted to a job runner,
+// needs a limit.
+//
+// A CompileCache is safe for concurrent use.
+type CompileCache struct {
+	dir string
+
+	mu      sync.Mutex
+	entries map[string][]byte
+	order   []string // Keys of the entries in memory, oldest first.
+	size    int64    // Bytes of the entries in memory.
+	maxSize int64    // Limit of size, or 0 for none.
+	hits    int64
+	misses  int64
+}
+
+// NewCompileCache creates a compile cache, storing entries in dir if it's
+// not empty. The directory is created if needed.
+func NewCompileCache(dir string) (*CompileCache, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &CompileCache{dir: dir, entries: make(map[string][]byte)}, nil
+}
+
+// SetMaxSize limits the memory used by entries to n bytes, evicting the
+// oldest entries when it's exceeded. Evicted entries are reloaded from disk,
+// if there's a directory, or compiled again. A limit of 0 or less removes it.
+func (c *CompileCache) SetMaxSize(n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxSize = max(n, 0)
+	c.evict()
+}
+
+// Compile is like Py_CompileStringExFlags, returning a new reference to a
+// code object loaded from the cache or compiled and added to it.
+//
+// Writing to the cache directory is best effort: if it fails, e.g. because
+// the directory is read-only or full, the code is still returned and kept
+// in memory.
+//
+// A syntax error is returned as a *SyntaxError. Requires the caller to hold
+// the GIL.
+func (c *CompileCache) Compile(src, filename string, start StartToken, optimize OptimizeLevel) (PyCodeObjectPtr, error) {
+	if optimize == UseInterpreterLevel {
+		level, err := interpreterOptimizeLevel()
+		if err != nil {
+			return NullPyCodeObjectPtr, err
+		}
+		optimize = level
+	}
+	key := compileKey(src, filename, start, optimize)
+
+	if data, ok := c.load(key); ok {
+		code := PyMarshal_ReadObjectFromString(unsafe.SliceData(data), int64(len(data)))
+		if code != NullPyObjectPtr {
+			c.mu.Lock()
+			c.hits++
+			c.mu.Unlock()
+			return PyCodeObjectPtr(code), nil
+		}
+		// Treat a corrupt entry as a miss, replacing it below.
+		PyErr_Clear()
+		c.mu.Lock()
+		c.remove(key)
+		c.mu.Unlock()
+	}
+
+	code := Py_CompileStringExFlags(src, filename, start, nil, optimize)
+	if code == NullPyCodeObjectPtr {
+		return NullPyCodeObjectPtr, FetchError()
+	}
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+
+	marshalled := PyMarshal_WriteObjectToString(PyObjectPtr(code), PyMarshalVersion)
+	if marshalled == NullPyObjectPtr {
+		// The code is still usable without caching it.
+		PyErr_Clear()
+		return code, nil
+	}
+	data := append([]byte(nil), unsafe.Slice(PyBytes_AsString(marshalled), PyBytes_Size(marshalled))...)
+	Py_DecRef(marshalled)
+
+	_ = c.store(key, data)
+	return code, nil
+}
+
+// Stats returns the number of cache hits and misses.
+func (c *CompileCache) Stats() (hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// load finds an entry in memory or on disk.
+func (c *CompileCache) load(key string) ([]byte, bool) {
+	c.mu.Lock()
+	data, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok || c.dir == "" {
+		return data, ok
+	}
+
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	c.add(key, data)
+	c.mu.Unlock()
+	return data, true
+}
+
+// store adds an entry to memory and, if enabled, to disk.
+func (c *CompileCache) store(key string, data []byte) error {
+	c.mu.Lock()
+	c.add(key, data)
+	c.mu.Unlock()
+	if c.dir == "" {
+		return nil
+	}
+
+	// Write to a temporary file and rename it so concurrent readers, even
+	// in other processes, never see a partial entry.
+	f, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.path(key))
+	}
+	if err != nil {
+		return errors.Join(err, os.Remove(f.Name()))
+	}
+	return nil
+}
+
+// add keeps an entry in memory. Requires c.mu to be held.
+func (c *CompileCache) add(key string, data []byte) {
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	c.entries[key] = data
+	c.order = append(c.order, key)
+	c.size += int64(len(data))
+	c.evict()
+}
+
+// remove drops an entry from memory. Requires c.mu to be held.
+func (c *CompileCache) remove(key string) {
+	data, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	delete(c.entries, key)
+	c.order = slices.DeleteFunc(c.order, func(k string) bool { return k == key })
+	c.size -= int64(len(data))
+}
+
+// evict drops the oldest entries from memory until the size limit is met.
+// Requires c.mu to be held.
+func (c *CompileCache) evict() {
+	for c.maxSize > 0 && c.size > c.maxSize {
+		key := c.order[0]
+		c.order = c.order[1:]
+		c.size -= int64(len(c.entries[key]))
+		delete(c.entries, key)
+	}
+}
+
+func (c *CompileCache) path(key string) string {
+	return filepath.Join(c.dir, key+".marshal")
+}
+
+// compileKey hashes everything that affects the compiled code. Each string
+// is length-prefixed so different inputs can't produce the same bytes.
+func compileKey(src, filename string, start StartToken, optimize OptimizeLevel) string {
+	h := sha256.New()
+	for _, s := range []string{Py_GetVersion(), filename, src} {
+		binary.Write(h, binary.LittleEndian, int64(len(s)))
+		h.Write([]byte(s))
+	}
+	binary.Write(h, binary.LittleEndian, int32(start))
+	binary.Write(h, binary.LittleEndian, optimize)
+	binary.Write(h, binary.LittleEndian, PyMarshalVersion)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// interpreterOptimizeLevel returns the optimization level of the current
+// interpreter, from sys.flags.optimize.
+func interpreterOptimizeLevel() (OptimizeLevel, error) {
+	flags, err := sysAttr("flags")
+	if err != nil {
+		return 0, err
+	}
+	defer Py_DecRef(flags)
+	obj := PyObject_GetAttrString(flags, "optimize")
+	if obj == NullPyObjectPtr {
+		return 0, FetchError()
+	}
+	defer Py_DecRef(obj)
+	var level OptimizeLevel
+	if err := FromPython(obj, &level); err != nil {
+		return 0, err
+	}
+	return level, nil
+}
@@ -0,0 +1,152 @@
+package gogopython_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// compileCached compiles an expression with the cache, failing the test on
+// errors.
+func compileCached(t *testing.T, cache *py.CompileCache, src string) {
+	t.Helper()
+	code, err := cache.Compile(src, "<test>", py.PyEvalInput, py.UseInterpreterLevel)
+	if err != nil {
+		t.Fatalf("Compile(%q): %v", src, err)
+	}
+	py.Py_DecRef(py.PyObjectPtr(code))
+}
+
+// evalCached compiles and evaluates an expression with the cache.
+func evalCached(t *testing.T, interp *gogopythontest.Interpreter, cache *py.CompileCache, src string, optimize py.OptimizeLevel) int64 {
+	t.Helper()
+	code, err := cache.Compile(src, "<test>", py.PyEvalInput, optimize)
+	if err != nil {
+		t.Fatalf("Compile(%q): %v", src, err)
+	}
+	defer py.Py_DecRef(py.PyObjectPtr(code))
+	res := py.PyEval_EvalCode(code, interp.Globals(), interp.Globals())
+	if res == py.NullPyObjectPtr {
+		t.Fatalf("eval of %q raised %v", src, py.FetchError())
+	}
+	defer py.Py_DecRef(res)
+	return py.PyLong_AsLong(res)
+}
+
+func TestCompileCache(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	dir := t.TempDir()
+	cache, err := py.NewCompileCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := func(wantHits, wantMisses int64) {
+		t.Helper()
+		if hits, misses := cache.Stats(); hits != wantHits || misses != wantMisses {
+			t.Errorf("Stats() = %d hits, %d misses, want %d and %d", hits, misses, wantHits, wantMisses)
+		}
+	}
+
+	if n := evalCached(t, interp, cache, "1 + 2", py.NoOptimization); n != 3 {
+		t.Errorf("1 + 2 = %d", n)
+	}
+	stats(0, 1)
+	if n := evalCached(t, interp, cache, "1 + 2", py.NoOptimization); n != 3 {
+		t.Errorf("1 + 2 = %d from the cache", n)
+	}
+	stats(1, 1)
+
+	// The optimization level is part of the key.
+	if n := evalCached(t, interp, cache, "__debug__ + 0", py.NoOptimization); n != 1 {
+		t.Errorf("__debug__ = %d without optimization", n)
+	}
+	if n := evalCached(t, interp, cache, "__debug__ + 0", py.RemoveDebugsAndAsserts); n != 0 {
+		t.Errorf("__debug__ = %d when optimized", n)
+	}
+	stats(1, 3)
+
+	// Entries survive on disk for a new cache.
+	entries, err := filepath.Glob(filepath.Join(dir, "*.marshal"))
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("cache directory has entries %v, %v, want 3", entries, err)
+	}
+	cache, err = py.NewCompileCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := evalCached(t, interp, cache, "1 + 2", py.NoOptimization); n != 3 {
+		t.Errorf("1 + 2 = %d from disk", n)
+	}
+	stats(1, 0)
+
+	// Corrupt entries are compiled again.
+	for _, entry := range entries {
+		if err := os.WriteFile(entry, []byte("corrupt"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache, err = py.NewCompileCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := evalCached(t, interp, cache, "1 + 2", py.NoOptimization); n != 3 {
+		t.Errorf("1 + 2 = %d after corrupting the cache", n)
+	}
+	evalCached(t, interp, cache, "1 + 2", py.NoOptimization)
+	stats(1, 1)
+
+	var syntaxErr *py.SyntaxError
+	if _, err := cache.Compile("1 +", "<test>", py.PyEvalInput, py.NoOptimization); !errors.As(err, &syntaxErr) {
+		t.Errorf("compiling invalid code returned %v, want a *SyntaxError", err)
+	}
+}
+
+func TestCompileCacheUnwritableDir(t *testing.T) {
+	gogopythontest.NewInterpreter(t)
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache, err := py.NewCompileCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Entries can't be written below a file.
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	compileCached(t, cache, "1 + 1")
+	compileCached(t, cache, "1 + 1")
+	if hits, misses := cache.Stats(); hits != 1 || misses != 1 {
+		t.Errorf("Stats() = %d hits, %d misses, want 1 and 1", hits, misses)
+	}
+}
+
+func TestCompileCacheMaxSize(t *testing.T) {
+	gogopythontest.NewInterpreter(t)
+
+	cache, err := py.NewCompileCache("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.SetMaxSize(1)
+	compileCached(t, cache, "1 + 1")
+	compileCached(t, cache, "1 + 1")
+	if hits, misses := cache.Stats(); hits != 0 || misses != 2 {
+		t.Errorf("Stats() = %d hits, %d misses, want the entry evicted", hits, misses)
+	}
+
+	cache.SetMaxSize(0)
+	compileCached(t, cache, "1 + 1")
+	compileCached(t, cache, "1 + 1")
+	if hits, _ := cache.Stats(); hits != 1 {
+		t.Errorf("Stats() = %d hits without a limit, want 1", hits)
+	}
+}
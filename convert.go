@@ -0,0 +1,480 @@
+package gogopython
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+// The reflect.Type of a PyObjectPtr, used to pass Python objects through
+// conversions untouched.
+var pyObjectPtrType = reflect.TypeFor[PyObjectPtr]()
+
+// ToPython converts a Go value into a new reference to an equivalent Python
+// object.
+//
+// Supported are bools, integers, floats, strings, byte slices (as bytes),
+// slices and arrays (as lists), maps (as dicts), structs (as dicts keyed by
+// field name or "py" struct tag) and pointers to any of those. A nil value
+// or nil pointer is converted to None.
+//
+// A PyObjectPtr is passed through as-is and the reference is stolen, making
+// it possible to return Python objects from Go.
+//
+// Requires the caller to hold the GIL.
+func ToPython(v any) (obj PyObjectPtr, err error) {
+	defer func() { countConversion(MetricToPython, err) }()
+	if v == nil {
+		Py_IncRef(Py_None)
+		return Py_None, nil
+	}
+	return toPython(reflect.ValueOf(v))
+}
+
+func toPython(v reflect.Value) (PyObjectPtr, error) {
+	if v.Type() == pyObjectPtrType {
+		obj := PyObjectPtr(v.Uint())
+		if obj == NullPyObjectPtr {
+			return NullPyObjectPtr, errors.New("cannot convert a NULL PyObjectPtr")
+		}
+		return obj, nil
+	}
+
+	var obj PyObjectPtr
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			obj = PyBool_FromLong(1)
+		} else {
+			obj = PyBool_FromLong(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		obj = PyLong_FromLongLong(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		obj = PyLong_FromUnsignedLongLong(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		obj = PyFloat_FromDouble(v.Float())
+	case reflect.String:
+		s := v.String()
+		obj = PyUnicode_FromStringAndSize(unsafe.StringData(s), int64(len(s)))
+	case reflect.Slice:
+		if v.IsNil() {
+			Py_IncRef(Py_None)
+			return Py_None, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := v.Bytes()
+			return PyBytes_FromStringAndSize(unsafe.SliceData(b), int64(len(b))), nil
+		}
+		return sequenceToPython(v)
+	case reflect.Array:
+		return sequenceToPython(v)
+	case reflect.Map:
+		if v.IsNil() {
+			Py_IncRef(Py_None)
+			return Py_None, nil
+		}
+		return mapToPython(v)
+	case reflect.Struct:
+		return structToPython(v)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			Py_IncRef(Py_None)
+			return Py_None, nil
+		}
+		return toPython(v.Elem())
+	default:
+		return NullPyObjectPtr, fmt.Errorf("cannot convert Go type %s to Python", v.Type())
+	}
+
+	if obj == NullPyObjectPtr {
+		PyErr_Clear()
+		return NullPyObjectPtr, fmt.Errorf("failed to convert Go type %s to Python", v.Type())
+	}
+	return obj, nil
+}
+
+func sequenceToPython(v reflect.Value) (PyObjectPtr, error) {
+	list := PyList_New(int64(v.Len()))
+	if list == NullPyObjectPtr {
+		PyErr_Clear()
+		return NullPyObjectPtr, errors.New("failed to create list")
+	}
+	for i := 0; i < v.Len(); i++ {
+		item, err := toPython(v.Index(i))
+		if err != nil {
+			Py_DecRef(list)
+			return NullPyObjectPtr, err
+		}
+		// Steals our reference to item.
+		PyList_SetItem(list, int64(i), item)
+	}
+	return list, nil
+}
+
+func mapToPython(v reflect.Value) (PyObjectPtr, error) {
+	dict := PyDict_New()
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := toPython(iter.Key())
+		if err != nil {
+			Py_DecRef(dict)
+			return NullPyObjectPtr, err
+		}
+		val, err := toPython(iter.Value())
+		if err != nil {
+			Py_DecRef(key)
+			Py_DecRef(dict)
+			return NullPyObjectPtr, err
+		}
+		rc := PyDict_SetItem(dict, key, val)
+		Py_DecRef(key)
+		Py_DecRef(val)
+		if rc != 0 {
+			PyErr_Clear()
+			Py_DecRef(dict)
+			return NullPyObjectPtr, fmt.Errorf("unhashable map key type %s", v.Type().Key())
+		}
+	}
+	return dict, nil
+}
+
+func structToPython(v reflect.Value) (PyObjectPtr, error) {
+	dict := PyDict_New()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := fieldName(t.Field(i))
+		if !ok {
+			continue
+		}
+		val, err := toPython(v.Field(i))
+		if err != nil {
+			Py_DecRef(dict)
+			return NullPyObjectPtr, err
+		}
+		PyDict_SetItemString(dict, name, val)
+		Py_DecRef(val)
+	}
+	return dict, nil
+}
+
+// fieldName returns the Python name of a struct field, using the "py" tag if
+// present. Unexported fields and fields tagged "-" are skipped.
+func fieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("py")
+	if tag == "-" {
+		return "", false
+	}
+	if tag != "" {
+		return tag, true
+	}
+	return f.Name, true
+}
+
+// FromPython converts a Python object into the Go value pointed to by dst.
+//
+// The conversion rules mirror ToPython. Struct fields are matched against
+// dict keys by "py" tag or, case-insensitively, by field name. If dst points
+// to a PyObjectPtr, obj is stored as a borrowed reference. If dst points to
+// an empty interface, the natural Go type for obj is used.
+//
+// Requires the caller to hold the GIL.
+func FromPython(obj PyObjectPtr, dst any) (err error) {
+	defer func() { countConversion(MetricFromPython, err) }()
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return errors.New("destination must be a non-nil pointer")
+	}
+	return fromPython(obj, v.Elem())
+}
+
+// ConversionError describes a Python object that could not be converted into
+// the requested Go type.
+type ConversionError struct {
+	PythonType string       // Python type name of the source object.
+	GoType     reflect.Type // Go type we tried to convert into.
+	Reason     string       // Optional detail about the failure.
+}
+
+func (e *ConversionError) Error() string {
+	msg := fmt.Sprintf("cannot convert Python %s to Go %s", e.PythonType, e.GoType)
+	if e.Reason != "" {
+		msg = msg + ": " + e.Reason
+	}
+	return msg
+}
+
+func conversionError(obj PyObjectPtr, t reflect.Type, reason string) error {
+	return &ConversionError{PythonType: TypeName(obj), GoType: t, Reason: reason}
+}
+
+func fromPython(obj PyObjectPtr, v reflect.Value) error {
+	if obj == NullPyObjectPtr {
+		return errors.New("cannot convert a NULL PyObjectPtr")
+	}
+	t := v.Type()
+	if t == pyObjectPtrType {
+		v.SetUint(uint64(obj))
+		return nil
+	}
+
+	pyType := BaseType(obj)
+	switch t.Kind() {
+	case reflect.Bool:
+		if pyType != Bool {
+			return conversionError(obj, t, "")
+		}
+		v.SetBool(obj == Py_True)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if pyType != Long && pyType != Bool {
+			return conversionError(obj, t, "")
+		}
+		var overflow int32
+		n := PyLong_AsLongAndOverflow(obj, &overflow)
+		if overflow != 0 || v.OverflowInt(n) {
+			return conversionError(obj, t, "value out of range")
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if pyType != Long && pyType != Bool {
+			return conversionError(obj, t, "")
+		}
+		n := PyLong_AsUnsignedLong(obj)
+		if PyErr_Occurred() != NullPyObjectPtr {
+			PyErr_Clear()
+			return conversionError(obj, t, "value out of range")
+		}
+		if v.OverflowUint(n) {
+			return conversionError(obj, t, "value out of range")
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if pyType != Float && pyType != Long {
+			return conversionError(obj, t, "")
+		}
+		f := PyFloat_AsDouble(obj)
+		if PyErr_Occurred() != NullPyObjectPtr {
+			PyErr_Clear()
+			return conversionError(obj, t, "value out of range")
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		if pyType != String {
+			return conversionError(obj, t, "")
+		}
+		s, err := UnicodeToString(obj)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Slice:
+		if pyType == None {
+			v.SetZero()
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && pyType == Bytes {
+			sz := PyBytes_Size(obj)
+			b := unsafe.Slice(PyBytes_AsString(obj), sz)
+			v.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+		items, err := sequenceItems(obj, pyType)
+		if err != nil {
+			return conversionError(obj, t, "")
+		}
+		s := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if err := fromPython(item, s.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		items, err := sequenceItems(obj, pyType)
+		if err != nil {
+			return conversionError(obj, t, "")
+		}
+		if len(items) != v.Len() {
+			return conversionError(obj, t, fmt.Sprintf("expected %d items, got %d", v.Len(), len(items)))
+		}
+		for i, item := range items {
+			if err := fromPython(item, v.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+	case reflect.Map:
+		if pyType == None {
+			v.SetZero()
+			return nil
+		}
+		if pyType != Dict {
+			return conversionError(obj, t, "")
+		}
+		m := reflect.MakeMapWithSize(t, int(PyDict_Size(obj)))
+		keys := PyDict_Keys(obj)
+		defer Py_DecRef(keys)
+		for i := int64(0); i < PyList_Size(keys); i++ {
+			key := PyList_GetItem(keys, i)
+			k := reflect.New(t.Key()).Elem()
+			if err := fromPython(key, k); err != nil {
+				return fmt.Errorf("key: %w", err)
+			}
+			e := reflect.New(t.Elem()).Elem()
+			if err := fromPython(PyDict_GetItem(obj, key), e); err != nil {
+				return fmt.Errorf("key %v: %w", k, err)
+			}
+			m.SetMapIndex(k, e)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		if pyType != Dict {
+			return conversionError(obj, t, "")
+		}
+		return dictToStruct(obj, v)
+	case reflect.Pointer:
+		if pyType == None {
+			v.SetZero()
+			return nil
+		}
+		p := reflect.New(t.Elem())
+		if err := fromPython(obj, p.Elem()); err != nil {
+			return err
+		}
+		v.Set(p)
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return conversionError(obj, t, "only empty interfaces are supported")
+		}
+		natural, err := naturalType(obj, pyType)
+		if err != nil {
+			return err
+		}
+		if natural == nil {
+			v.SetZero()
+			return nil
+		}
+		n := reflect.New(natural).Elem()
+		if err := fromPython(obj, n); err != nil {
+			return err
+		}
+		v.Set(n)
+	default:
+		return conversionError(obj, t, "unsupported Go type")
+	}
+	return nil
+}
+
+// naturalType picks the Go type used when converting into an empty interface.
+func naturalType(obj PyObjectPtr, pyType Type) (reflect.Type, error) {
+	switch pyType {
+	case None:
+		return nil, nil
+	case Bool:
+		return reflect.TypeFor[bool](), nil
+	case Long:
+		return reflect.TypeFor[int64](), nil
+	case Float:
+		return reflect.TypeFor[float64](), nil
+	case String:
+		return reflect.TypeFor[string](), nil
+	case Bytes:
+		return reflect.TypeFor[[]byte](), nil
+	case List, Tuple:
+		return reflect.TypeFor[[]any](), nil
+	case Dict:
+		return reflect.TypeFor[map[string]any](), nil
+	}
+	// Anything else is handed back as the Python object itself.
+	return pyObjectPtrType, nil
+}
+
+// sequenceItems returns borrowed references to the items of a list or tuple.
+func sequenceItems(obj PyObjectPtr, pyType Type) ([]PyObjectPtr, error) {
+	var items []PyObjectPtr
+	switch pyType {
+	case List:
+		for i := int64(0); i < PyList_Size(obj); i++ {
+			items = append(items, PyList_GetItem(obj, i))
+		}
+	case Tuple:
+		for i := int64(0); i < PyTuple_Size(obj); i++ {
+			items = append(items, PyTuple_GetItem(obj, i))
+		}
+	default:
+		return nil, errors.New("not a list or tuple")
+	}
+	return items, nil
+}
+
+// dictToStruct populates the fields of a struct from a Python dict with
+// string keys. Unknown keys are an error.
+func dictToStruct(dict PyObjectPtr, v reflect.Value) error {
+	keys := PyDict_Keys(dict)
+	defer Py_DecRef(keys)
+	for i := int64(0); i < PyList_Size(keys); i++ {
+		key := PyList_GetItem(keys, i)
+		name, err := UnicodeToString(key)
+		if err != nil {
+			return err
+		}
+		field, ok := structField(v, name)
+		if !ok {
+			return fmt.Errorf("unexpected key '%s' for %s", name, v.Type())
+		}
+		if err := fromPython(PyDict_GetItem(dict, key), field); err != nil {
+			return fmt.Errorf("key '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// structField finds the field of struct v corresponding to a Python name.
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fname, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		if f.Tag.Get("py") != "" {
+			if fname == name {
+				return v.Field(i), true
+			}
+		} else if strings.EqualFold(fname, name) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// TypeName returns the name of the Python type of obj, e.g. "str".
+func TypeName(obj PyObjectPtr) string {
+	if obj == NullPyObjectPtr {
+		return "NULL"
+	}
+	tp := PyObject_Type(obj)
+	if tp == NullPyTypeObjectPtr {
+		PyErr_Clear()
+		return "unknown"
+	}
+	defer Py_DecRef(PyObjectPtr(tp))
+
+	name := PyObject_GetAttrString(PyObjectPtr(tp), "__name__")
+	if name == NullPyObjectPtr {
+		PyErr_Clear()
+		return "unknown"
+	}
+	defer Py_DecRef(name)
+
+	s, err := UnicodeToString(name)
+	if err != nil {
+		return "unknown"
+	}
+	return s
+}
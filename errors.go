@@ -0,0 +1,168 @@
+package gogopython
+
+import "strings"
+
+// Exception is a Python exception converted into a Go error.
+//
+// It only holds copies of the exception details, so it's safe to use after
+// releasing the GIL or from other goroutines.
+type Exception struct {
+	Type      string // Type is the exception's type name, e.g. "ValueError".
+	Message   string // Message is str() of the exception.
+	Traceback string // Traceback is the formatted traceback, if available.
+}
+
+func (e *Exception) Error() string {
+	if e.Message == "" {
+		return e.Type
+	}
+	return e.Type + ": " + e.Message
+}
+
+// SyntaxError describes a Python SyntaxError, or a subclass such as
+// IndentationError, with the location of the problem in the source.
+//
+// Positions are 1-based, with zero meaning unknown. The embedded Exception
+// is returned by Unwrap, so errors.As can find either type.
+type SyntaxError struct {
+	Exception
+
+	Msg       string // Msg is the error without location, e.g. "invalid syntax".
+	Filename  string
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+	Text      string // Text is the offending source line, if available.
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return &e.Exception
+}
+
+// Context returns the offending source line followed by a line of carets
+// marking the columns at fault, like Python's traceback output. Returns an
+// empty string if the source line isn't known.
+func (e *SyntaxError) Context() string {
+	if e.Text == "" {
+		return ""
+	}
+	text := strings.TrimLeft(e.Text, " \t\f")
+	indent := len([]rune(e.Text)) - len([]rune(text))
+	start := max(e.Column-1-indent, 0)
+	end := start + 1
+	if e.EndLine == e.Line && e.EndColumn > e.Column {
+		end = e.EndColumn - 1 - indent
+	}
+	end = max(min(end, len([]rune(text))), start+1)
+	return text + "\n" + strings.Repeat(" ", start) + strings.Repeat("^", end-start)
+}
+
+// FetchError takes the Python exception currently being raised, if any, and
+// returns it as an *Exception, a *SyntaxError for syntax errors or an
+// *ImportDeniedError for imports denied by an ImportPolicy. The Python error
+// indicator is cleared.
+//
+// Returns nil if no exception is set. Requires the caller to hold the GIL.
+func FetchError() error {
+	exc := PyErr_GetRaisedException()
+	if exc == NullPyObjectPtr {
+		return nil
+	}
+	defer Py_DecRef(exc)
+	if PyObject_IsInstance(exc, PyExc_SyntaxError) == 1 {
+		return newSyntaxError(exc)
+	}
+	if PyObject_IsInstance(exc, PyExc_ImportError) == 1 {
+		if err := newImportDenied(exc); err != nil {
+			return err
+		}
+	}
+	return newException(exc)
+}
+
+// newSyntaxError converts a Python SyntaxError into a *SyntaxError.
+func newSyntaxError(exc PyObjectPtr) *SyntaxError {
+	e := &SyntaxError{Exception: *newException(exc)}
+	attrs := []struct {
+		name string
+		dst  any
+	}{
+		{"msg", &e.Msg},
+		{"filename", &e.Filename},
+		{"lineno", &e.Line},
+		{"offset", &e.Column},
+		{"end_lineno", &e.EndLine},
+		{"end_offset", &e.EndColumn},
+		{"text", &e.Text},
+	}
+	for _, attr := range attrs {
+		obj := PyObject_GetAttrString(exc, attr.name)
+		if obj == NullPyObjectPtr {
+			PyErr_Clear()
+			continue
+		}
+		// Attributes may be None if unknown, leaving the zero value.
+		if obj != Py_None {
+			_ = FromPython(obj, attr.dst)
+		}
+		Py_DecRef(obj)
+	}
+	// Python uses -1 for some unknown positions.
+	e.Line, e.Column = max(e.Line, 0), max(e.Column, 0)
+	e.EndLine, e.EndColumn = max(e.EndLine, 0), max(e.EndColumn, 0)
+	e.Text = strings.TrimRight(e.Text, "\r\n")
+	return e
+}
+
+// newException converts a Python exception object into an *Exception.
+func newException(exc PyObjectPtr) *Exception {
+	e := &Exception{Type: TypeName(exc)}
+	if str := PyObject_Str(exc); str != NullPyObjectPtr {
+		e.Message, _ = UnicodeToString(str)
+		Py_DecRef(str)
+	}
+	e.Traceback = formatException(exc)
+	PyErr_Clear()
+	return e
+}
+
+// formatException formats an exception and its traceback using Python's
+// traceback module, returning an empty string on failure.
+func formatException(exc PyObjectPtr) string {
+	tb := PyImport_ImportModule("traceback")
+	if tb == NullPyObjectPtr {
+		PyErr_Clear()
+		return ""
+	}
+	defer Py_DecRef(tb)
+
+	format := PyObject_GetAttrString(tb, "format_exception")
+	if format == NullPyObjectPtr {
+		PyErr_Clear()
+		return ""
+	}
+	defer Py_DecRef(format)
+
+	// Formatting runs Python code, which mustn't reach trace functions, e.g.
+	// to be counted against a budget that's already exceeded.
+	ts := PyThreadState_Get()
+	PyThreadState_EnterTracing(ts)
+	lines := PyObject_CallOneArg(format, exc)
+	PyThreadState_LeaveTracing(ts)
+	if lines == NullPyObjectPtr {
+		PyErr_Clear()
+		return ""
+	}
+	defer Py_DecRef(lines)
+
+	var sb strings.Builder
+	for i := int64(0); i < PyList_Size(lines); i++ {
+		s, err := UnicodeToString(PyList_GetItem(lines, i))
+		if err != nil {
+			return ""
+		}
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
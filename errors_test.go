@@ -0,0 +1,113 @@
+package gogopython_test
+
+import (
+	"errors"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+func TestSyntaxError(t *testing.T) {
+	gogopythontest.NewInterpreter(t)
+
+	for _, tt := range []struct {
+		src     string
+		want    py.SyntaxError
+		context string
+	}{
+		{
+			src: "x = (1,\n",
+			want: py.SyntaxError{
+				Exception: py.Exception{Type: "SyntaxError"},
+				Msg:       "'(' was never closed",
+				Line:      1, Column: 5, EndLine: 1,
+				Text: "x = (1,",
+			},
+			context: "x = (1,\n    ^",
+		},
+		{
+			src: "def f():\nreturn 1",
+			want: py.SyntaxError{
+				Exception: py.Exception{Type: "IndentationError"},
+				Msg:       "expected an indented block after function definition on line 1",
+				Line:      2, Column: 1, EndLine: 2, EndColumn: 7,
+				Text: "return 1",
+			},
+			context: "return 1\n^^^^^^",
+		},
+		{
+			src: "if True:\n    x = 1\n  y = 2",
+			want: py.SyntaxError{
+				Exception: py.Exception{Type: "IndentationError"},
+				Msg:       "unindent does not match any outer indentation level",
+				Line:      3, Column: 8, EndLine: 3,
+				Text: "  y = 2",
+			},
+			context: "y = 2\n     ^",
+		},
+		{
+			src: "f(a for a in b, c)",
+			want: py.SyntaxError{
+				Exception: py.Exception{Type: "SyntaxError"},
+				Msg:       "Generator expression must be parenthesized",
+				Line:      1, Column: 3, EndLine: 1, EndColumn: 15,
+				Text: "f(a for a in b, c)",
+			},
+			context: "f(a for a in b, c)\n  ^^^^^^^^^^^^",
+		},
+	} {
+		_, err := py.Compile(tt.src, "script.py", py.PyFileInput, py.CompileOptions{})
+		var got *py.SyntaxError
+		if !errors.As(err, &got) {
+			t.Errorf("compiling %q returned %v, want a *SyntaxError", tt.src, err)
+			continue
+		}
+		tt.want.Filename = "script.py"
+		tt.want.Message = got.Message
+		tt.want.Traceback = got.Traceback
+		if *got != tt.want {
+			t.Errorf("compiling %q returned %+v, want %+v", tt.src, *got, tt.want)
+		}
+		if ctx := got.Context(); ctx != tt.context {
+			t.Errorf("compiling %q: Context() = %q, want %q", tt.src, ctx, tt.context)
+		}
+		var exc *py.Exception
+		if !errors.As(err, &exc) || exc.Type != tt.want.Type {
+			t.Errorf("compiling %q: the *SyntaxError doesn't unwrap to an *Exception", tt.src)
+		}
+	}
+}
+
+func TestSyntaxErrorFromExec(t *testing.T) {
+	// The builtin compile creates the AST types on first use.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{Preload: []string{"ast"}})
+
+	err := interp.Exec("x = 1 +* 2")
+	var syntaxErr *py.SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Line != 1 || syntaxErr.Column != 8 {
+		t.Errorf("running invalid code returned %v, want a *SyntaxError at 1:8", err)
+	}
+	// Syntax errors raised by running code are converted too.
+	err = interp.Exec("compile('(', 'inner.py', 'exec')")
+	if !errors.As(err, &syntaxErr) || syntaxErr.Filename != "inner.py" {
+		t.Errorf("compiling invalid code in Python returned %v, want a *SyntaxError", err)
+	}
+}
+
+func TestSyntaxErrorContext(t *testing.T) {
+	for _, tt := range []struct {
+		err  py.SyntaxError
+		want string
+	}{
+		{py.SyntaxError{}, ""},
+		{py.SyntaxError{Text: "x = $", Line: 1, Column: 5}, "x = $\n    ^"},
+		{py.SyntaxError{Text: "\tx = ☃☃", Line: 1, Column: 6, EndLine: 1, EndColumn: 8}, "x = ☃☃\n    ^^"},
+		// Only a single line is marked for errors spanning several.
+		{py.SyntaxError{Text: "x = [", Line: 1, Column: 5, EndLine: 2, EndColumn: 2}, "x = [\n    ^"},
+	} {
+		if got := tt.err.Context(); got != tt.want {
+			t.Errorf("Context() of %+v = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	_ "embed"
+	"embed"
 	py "github.com/voutilad/gogopython"
 	"log"
 	"os"
@@ -13,6 +13,11 @@ import (
 //go:embed script.py
 var script string
 
+// Python modules shipped inside the binary.
+//
+//go:embed junk.py
+var modules embed.FS
+
 var helperModuleSrc = `
 def content():
 	return b'hey dude'
@@ -30,7 +35,7 @@ print(f"helper module executed, __name__={__name__}")
 `
 
 var program = `
-import example.junk as junk
+import junk
 
 def findme():
 	return "hello"
@@ -150,6 +155,12 @@ func main() {
 		newTs := py.PyThreadState_New(subIntState)
 		py.PyEval_RestoreThread(newTs)
 
+		// Make our embedded modules importable.
+		removeFS, err := py.InstallFS(modules)
+		if err != nil {
+			log.Fatalln("failed to install embedded modules:", err)
+		}
+
 		// Demonstrate running a simple script without global/local state.
 		if py.PyRun_SimpleString(script) != 0 {
 			py.PyErr_Print()
@@ -351,7 +362,7 @@ func main() {
 			if dumps == py.NullPyObjectPtr {
 				log.Fatalln("expected dumps from pickle module attrs")
 			}
-			junkMod := py.PyImport_ImportModule("example.junk")
+			junkMod := py.PyImport_ImportModule("junk")
 			if junkMod == py.NullPyObjectPtr {
 				log.Fatalln("no pickle module found")
 			}
@@ -364,6 +375,7 @@ func main() {
 		}
 
 		// Drop ref counts.
+		removeFS()
 		py.Py_DecRef(globals)
 		py.Py_DecRef(locals)
 
@@ -0,0 +1,258 @@
+package gogopython
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	contextType = reflect.TypeFor[context.Context]()
+	errorType   = reflect.TypeFor[error]()
+)
+
+// ExportFunc creates a new Python function object, with the given name, that
+// calls the provided Go func, converting arguments and results automatically.
+//
+// The Go func may take an optional context.Context as its first parameter,
+// which receives context.Background(), or the context given to
+// ExportFuncContext, followed by any parameters supported by FromPython. If
+// the last parameter is a struct, it may instead be populated from Python
+// keyword arguments, e.g. for func(ctx context.Context, name string, opts
+// Opts):
+//
+//	fn("hello", limit=10, verbose=True)
+//
+// The Go func may return any number of values supported by ToPython with an
+// optional trailing error. No values maps to None, one value is returned
+// as-is and multiple values are returned as a tuple. A non-nil error raises
+// a Python RuntimeError.
+//
+// If arguments can't be converted, a Python TypeError is raised describing
+// the mismatch.
+func ExportFunc(name string, fn any) (PyObjectPtr, error) {
+	return ExportFuncContext(context.Background(), name, fn)
+}
+
+// ExportFuncContext is like ExportFunc, but passes ctx to a Go func taking a
+// context.Context, e.g. to cancel work started from Python when the
+// application shuts down.
+func ExportFuncContext(ctx context.Context, name string, fn any) (PyObjectPtr, error) {
+	e, err := newExport(name, fn)
+	if err != nil {
+		return NullPyObjectPtr, err
+	}
+	e.ctx = ctx
+	obj := NewFunctionWithKeywords(name, NullPyObjectPtr, e.call)
+	if obj == NullPyObjectPtr {
+		PyErr_Clear()
+		return NullPyObjectPtr, errors.New("failed to create function object")
+	}
+	return obj, nil
+}
+
+// export describes a Go func being exposed to Python.
+type export struct {
+	name     string
+	fn       reflect.Value
+	params   []reflect.Type  // Python-facing parameters, excluding any context.
+	hasCtx   bool            // Whether the first Go param is a context.Context.
+	ctx      context.Context // Passed as the context, if nil Background.
+	kwStruct bool            // Whether the last param may come from kwargs.
+	hasErr   bool            // Whether the last result is an error.
+
+	// For methods, converts the Python self (the first positional
+	// argument) into the Go receiver.
+	receiver func(self PyObjectPtr) (reflect.Value, error)
+}
+
+func newExport(name string, fn any) (*export, error) {
+	return newMethodExport(name, fn, nil, nil)
+}
+
+// newMethodExport is like newExport, but if recv is non-nil the first
+// parameter of fn must be of type recv and is provided by receiver.
+func newMethodExport(name string, fn any, recv reflect.Type,
+	receiver func(PyObjectPtr) (reflect.Value, error)) (*export, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("%s: expected a func, got %T", name, fn)
+	}
+	t := v.Type()
+	if t.IsVariadic() {
+		return nil, fmt.Errorf("%s: variadic funcs are not supported", name)
+	}
+
+	e := &export{name: name, fn: v, receiver: receiver}
+	first := 0
+	if recv != nil {
+		if t.NumIn() == 0 || t.In(0) != recv {
+			return nil, fmt.Errorf("%s: first parameter must be %s", name, recv)
+		}
+		first = 1
+	}
+	for i := first; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if in == contextType {
+			if i != first {
+				return nil, fmt.Errorf("%s: context.Context must be the first parameter", name)
+			}
+			e.hasCtx = true
+			continue
+		}
+		if !convertible(in) {
+			return nil, fmt.Errorf("%s: unsupported parameter type %s", name, in)
+		}
+		e.params = append(e.params, in)
+	}
+	if n := len(e.params); n > 0 && e.params[n-1].Kind() == reflect.Struct {
+		e.kwStruct = true
+	}
+
+	for i := 0; i < t.NumOut(); i++ {
+		out := t.Out(i)
+		if out == errorType {
+			if i != t.NumOut()-1 {
+				return nil, fmt.Errorf("%s: error must be the last result", name)
+			}
+			e.hasErr = true
+			continue
+		}
+		if !convertible(out) {
+			return nil, fmt.Errorf("%s: unsupported result type %s", name, out)
+		}
+	}
+	return e, nil
+}
+
+// convertible reports whether values of type t can be converted to and from
+// Python by ToPython and FromPython.
+func convertible(t reflect.Type) bool {
+	if t == pyObjectPtrType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice, reflect.Array, reflect.Pointer:
+		return convertible(t.Elem())
+	case reflect.Map:
+		return convertible(t.Key()) && convertible(t.Elem())
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() && !convertible(f.Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// call is the Python-facing entrypoint for an exported func.
+func (e *export) call(_, args, kwargs PyObjectPtr) PyObjectPtr {
+	in, err := e.arguments(args, kwargs)
+	if err != nil {
+		PyErr_SetString(PyExc_TypeError, err.Error())
+		return NullPyObjectPtr
+	}
+
+	out := e.fn.Call(in)
+	if e.hasErr {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			PyErr_SetString(PyExc_RuntimeError, err.Error())
+			return NullPyObjectPtr
+		}
+		out = out[:len(out)-1]
+	}
+
+	switch len(out) {
+	case 0:
+		Py_IncRef(Py_None)
+		return Py_None
+	case 1:
+		obj, err := toPython(out[0])
+		if err != nil {
+			PyErr_SetString(PyExc_TypeError, fmt.Sprintf("%s() result: %v", e.name, err))
+			return NullPyObjectPtr
+		}
+		return obj
+	}
+
+	tuple := PyTuple_New(int64(len(out)))
+	for i, v := range out {
+		obj, err := toPython(v)
+		if err != nil {
+			Py_DecRef(tuple)
+			PyErr_SetString(PyExc_TypeError, fmt.Sprintf("%s() result %d: %v", e.name, i, err))
+			return NullPyObjectPtr
+		}
+		// Steals our reference to obj.
+		PyTuple_SetItem(tuple, int64(i), obj)
+	}
+	return tuple
+}
+
+// arguments converts the Python args tuple and kwargs dict into the Go values
+// used to call the exported func.
+func (e *export) arguments(args, kwargs PyObjectPtr) ([]reflect.Value, error) {
+	nargs := int(PyTuple_Size(args))
+	in := make([]reflect.Value, 0, len(e.params)+2)
+
+	// Methods are called with self as the first positional argument.
+	first := 0
+	if e.receiver != nil {
+		if nargs == 0 {
+			return nil, fmt.Errorf("%s() missing self", e.name)
+		}
+		self, err := e.receiver(PyTuple_GetItem(args, 0))
+		if err != nil {
+			return nil, fmt.Errorf("%s() self: %w", e.name, err)
+		}
+		in = append(in, self)
+		first = 1
+		nargs--
+	}
+
+	hasKwargs := kwargs != NullPyObjectPtr && PyDict_Size(kwargs) > 0
+
+	want := len(e.params)
+	if hasKwargs {
+		if !e.kwStruct {
+			return nil, fmt.Errorf("%s() takes no keyword arguments", e.name)
+		}
+		// Keyword arguments take the place of the trailing struct.
+		want--
+	}
+	if nargs != want && !(e.kwStruct && !hasKwargs && nargs == want-1) {
+		return nil, fmt.Errorf("%s() takes %d positional arguments but %d were given", e.name, want, nargs)
+	}
+
+	if e.hasCtx {
+		ctx := e.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		in = append(in, reflect.ValueOf(ctx))
+	}
+	for i, t := range e.params {
+		v := reflect.New(t).Elem()
+		if i < nargs {
+			if err := fromPython(PyTuple_GetItem(args, int64(first+i)), v); err != nil {
+				return nil, fmt.Errorf("%s() argument %d: %w", e.name, i+1, err)
+			}
+		} else if hasKwargs {
+			if err := dictToStruct(kwargs, v); err != nil {
+				return nil, fmt.Errorf("%s() keyword arguments: %w", e.name, err)
+			}
+		}
+		in = append(in, v)
+	}
+	return in, nil
+}
@@ -0,0 +1,111 @@
+package gogopython_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// exportFunc binds a Go func exported with ExportFunc in the interpreter's
+// globals.
+func exportFunc(t *testing.T, interp *gogopythontest.Interpreter, name string, fn any) {
+	t.Helper()
+	obj, err := py.ExportFunc(name, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setGlobal(t, interp, name, obj)
+}
+
+type searchOpts struct {
+	Limit   int
+	Verbose bool
+	Tag     string `py:"tag_name"`
+}
+
+func TestExportFunc(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	exportFunc(t, interp, "search", func(ctx context.Context, query string, opts searchOpts) string {
+		if ctx == nil {
+			return "no context"
+		}
+		return fmt.Sprintf("%s %d %t %s", query, opts.Limit, opts.Verbose, opts.Tag)
+	})
+	gogopythontest.RequireEval(t, interp, "search('q')", "q 0 false ")
+	gogopythontest.RequireEval(t, interp, "search('q', limit=10, verbose=True, tag_name='t')", "q 10 true t")
+	gogopythontest.RequireEval(t, interp, "search('q', {'limit': 5})", "q 5 false ")
+
+	exportFunc(t, interp, "none", func() {})
+	gogopythontest.RequireEval(t, interp, "none()", nil)
+	exportFunc(t, interp, "divmod_", func(a, b int) (int, int, error) {
+		if b == 0 {
+			return 0, 0, errors.New("division by zero")
+		}
+		return a / b, a % b, nil
+	})
+	gogopythontest.RequireEval(t, interp, "divmod_(7, 2)", []int{3, 1})
+	exc := gogopythontest.RequireRaises(t, interp, "divmod_(1, 0)", "RuntimeError")
+	if exc.Message != "division by zero" {
+		t.Errorf("returned error raised %q", exc.Message)
+	}
+}
+
+func TestExportFuncTypeErrors(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	exportFunc(t, interp, "double", func(n int) int { return 2 * n })
+	exportFunc(t, interp, "search", func(query string, opts searchOpts) string { return query })
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"double()", "double() takes 1 positional arguments but 0 were given"},
+		{"double(1, 2)", "double() takes 1 positional arguments but 2 were given"},
+		{"double('1')", "double() argument 1: cannot convert Python str to Go int"},
+		{"double(n=1)", "double() takes no keyword arguments"},
+		{"search('q', {}, limit=1)", "search() takes 1 positional arguments but 2 were given"},
+		{"search('q', limit='1')", "search() keyword arguments: key 'limit': cannot convert Python str to Go int"},
+		{"search('q', size=1)", "search() keyword arguments: unexpected key 'size' for gogopython_test.searchOpts"},
+	} {
+		exc := gogopythontest.RequireRaises(t, interp, tt.src, "TypeError")
+		if exc.Message != tt.want {
+			t.Errorf("%s raised %q, want %q", tt.src, exc.Message, tt.want)
+		}
+	}
+}
+
+func TestExportFuncContext(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	obj, err := py.ExportFuncContext(ctx, "lookup", func(ctx context.Context) string {
+		return ctx.Value(key{}).(string)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setGlobal(t, interp, "lookup", obj)
+	gogopythontest.RequireEval(t, interp, "lookup()", "value")
+}
+
+func TestExportFuncUnsupported(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fn   any
+	}{
+		{"not a func", 1},
+		{"variadic", func(...int) {}},
+		{"channel parameter", func(chan int) {}},
+		{"late context", func(int, context.Context) {}},
+		{"early error", func() (error, int) { return nil, 0 }},
+	} {
+		if _, err := py.ExportFunc("f", tt.fn); err == nil {
+			t.Errorf("%s: ExportFunc succeeded", tt.name)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package gogopython_test
+
+import (
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// setGlobal binds a new reference to a name in the interpreter's globals,
+// releasing it.
+func setGlobal(t *testing.T, interp *gogopythontest.Interpreter, name string, obj py.PyObjectPtr) {
+	t.Helper()
+	if obj == py.NullPyObjectPtr {
+		t.Fatalf("creating %s failed: %v", name, py.FetchError())
+	}
+	rc := py.PyDict_SetItemString(interp.Globals(), name, obj)
+	py.Py_DecRef(obj)
+	if rc != 0 {
+		t.Fatalf("setting %s failed: %v", name, py.FetchError())
+	}
+}
+
+func TestNewFunction(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	self := gogopythontest.Eval(t, interp, "'self'")
+	setGlobal(t, interp, "f", py.NewFunction("f", self, func(s, args py.PyObjectPtr) py.PyObjectPtr {
+		if s != self {
+			py.PyErr_SetString(py.PyExc_ValueError, "wrong self")
+			return py.NullPyObjectPtr
+		}
+		return py.PyLong_FromLong(py.PyTuple_Size(args))
+	}))
+	gogopythontest.RequireEval(t, interp, "f(1, 2, 3)", 3)
+	gogopythontest.RequireEval(t, interp, "f.__name__", "f")
+	gogopythontest.RequireRaises(t, interp, "f(x=1)", "TypeError")
+}
+
+func TestNewFunctionWithKeywords(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	setGlobal(t, interp, "f", py.NewFunctionWithKeywords("f", py.NullPyObjectPtr, func(_, args, kwargs py.PyObjectPtr) py.PyObjectPtr {
+		var n int64
+		if kwargs != py.NullPyObjectPtr {
+			n = py.PyDict_Size(kwargs)
+		}
+		return py.PyLong_FromLong(10*py.PyTuple_Size(args) + n)
+	}))
+	gogopythontest.RequireEval(t, interp, "f()", 0)
+	gogopythontest.RequireEval(t, interp, "f(1, 2)", 20)
+	gogopythontest.RequireEval(t, interp, "f(1, x=1, y=2)", 12)
+}
+
+func TestNewFastFunction(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	setGlobal(t, interp, "total", py.NewFastFunction("total", py.NullPyObjectPtr, func(_ py.PyObjectPtr, args *py.PyObjectPtr, nargs int64) py.PyObjectPtr {
+		var sum int64
+		for _, arg := range py.FastCallArgs(args, nargs) {
+			sum += py.PyLong_AsLong(arg)
+		}
+		return py.PyLong_FromLong(sum)
+	}))
+	gogopythontest.RequireEval(t, interp, "total()", 0)
+	gogopythontest.RequireEval(t, interp, "total(1, 2, 3)", 6)
+	gogopythontest.RequireRaises(t, interp, "total(x=1)", "TypeError")
+
+	if args := py.FastCallArgs(nil, 0); args != nil {
+		t.Errorf("FastCallArgs(nil, 0) = %v, want nil", args)
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/voutilad/gogopython
 
-go 1.22.5
+go 1.23
 
 require (
 	github.com/ebitengine/purego v0.8.0-alpha.4
@@ -68,6 +68,8 @@ func LoadLibrary(exe string) error {
 	}
 
 	registerFuncs(lib)
+	instrumentBindings()
+	forgetInterpreters()
 
 	return nil
 }
@@ -230,6 +232,19 @@ func WCharToString(text WCharPtr) (string, error) {
 	return "", errors.New("text too long")
 }
 
+// cString copies a NUL-terminated C string, such as one passed to a callback,
+// to a Go string.
+func cString(p *byte) string {
+	if p == nil {
+		return ""
+	}
+	n := 0
+	for ptr := unsafe.Pointer(p); *(*byte)(ptr) != 0; ptr = unsafe.Add(ptr, 1) {
+		n++
+	}
+	return strings.Clone(unsafe.String(p, n))
+}
+
 // UnicodeToString converts a Python Unicode object (i.e. a Python string)
 // to a Go string.
 //
@@ -313,11 +328,40 @@ func BaseType(obj PyObjectPtr) Type {
 
 // NewFunction creates a new Python function object, with the given name, that
 // calls the provided Go func.
+//
+// The Go func is released once Python garbage collects the function object.
 func NewFunction(name string, self PyObjectPtr, fn func(self, tuple PyObjectPtr) PyObjectPtr) PyObjectPtr {
-	def := PyMethodDef{
-		Name:   unsafe.StringData(name),
-		Flags:  MethodVarArgs,
-		Method: purego.NewCallback(fn),
+	return newCallback(name, self, PyCFunctionDefault, &callback{varargs: fn})
+}
+
+// NewFunctionWithKeywords creates a new Python function object, with the given
+// name, that calls the provided Go func with both positional and keyword
+// arguments.
+//
+// The kwargs dict will be NullPyObjectPtr if the caller provided no keyword
+// arguments.
+func NewFunctionWithKeywords(name string, self PyObjectPtr, fn func(self, args, kwargs PyObjectPtr) PyObjectPtr) PyObjectPtr {
+	return newCallback(name, self, PyCFunctionWithKeywords, &callback{keywords: fn})
+}
+
+// NewFastFunction creates a new Python function object, with the given name,
+// that calls the provided Go func using the "fastcall" (vectorcall) protocol.
+//
+// Positional arguments are passed as a C array of nargs borrowed references,
+// avoiding the allocation of a tuple per call. Use FastCallArgs to view them
+// as a Go slice.
+func NewFastFunction(name string, self PyObjectPtr, fn func(self PyObjectPtr, args *PyObjectPtr, nargs int64) PyObjectPtr) PyObjectPtr {
+	return newCallback(name, self, PyCFunctionFast, &callback{fast: fn})
+}
+
+// FastCallArgs converts the argument array passed to a fastcall function into
+// a Go slice without copying.
+//
+// The slice is only valid for the duration of the call and the items are
+// borrowed references.
+func FastCallArgs(args *PyObjectPtr, nargs int64) []PyObjectPtr {
+	if args == nil || nargs <= 0 {
+		return nil
 	}
-	return PyCFunction_NewEx(&def, self, NullPyObjectPtr)
+	return unsafe.Slice(args, nargs)
 }
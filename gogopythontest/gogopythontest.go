@@ -0,0 +1,330 @@
+// Package gogopythontest provides helpers for testing Go code that embeds
+// Python with gogopython.
+//
+// A test binary shares one Python runtime, started by Main from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(gogopythontest.Main(m))
+//	}
+//
+// Each test then gets its own sub-interpreter from NewInterpreter, which
+// fails the test if it ends with a Python exception set or with more memory
+// allocated by Python than it started with, e.g. because of a missing
+// Py_DecRef.
+package gogopythontest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+)
+
+// PythonEnv is the environment variable naming the Python executable used by
+// Main. Defaults to "python3".
+const PythonEnv = "GOGOPYTHON_PYTHON"
+
+// Main loads and initializes Python, runs the tests and finalizes Python,
+// returning the exit code for os.Exit. It's meant to be called from
+// TestMain.
+//
+// If Python can't be started, the tests fail when PythonEnv is set.
+// Otherwise they still run, and those needing Python are reported as
+// skipped by NewInterpreter or SkipWithoutPython, so go test works without a
+// suitable python3 installed.
+func Main(m *testing.M) int {
+	return MainWithSetup(m, nil)
+}
+
+// unavailable is why Python couldn't be started by Main, if it wasn't.
+var unavailable error
+
+// MainWithSetup is like Main, but calls setup, if not nil, after loading the
+// Python library and before initializing Python, e.g. to register built-in
+// modules with NativeModule.AppendInittab.
+func MainWithSetup(m *testing.M, setup func() error) int {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	exe, required := os.LookupEnv(PythonEnv)
+	if !required {
+		exe = "python3"
+	}
+	if err := start(exe, setup); err != nil {
+		fmt.Fprintf(os.Stderr, "gogopythontest: %v\n", err)
+		if required {
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "gogopythontest: skipping tests needing python, set %s to run them\n", PythonEnv)
+		unavailable = err
+		return m.Run()
+	}
+
+	// Release the GIL, so tests can create interpreters on their own
+	// threads.
+	ts := py.PyEval_SaveThread()
+	code := m.Run()
+	py.PyEval_RestoreThread(ts)
+	if py.Py_FinalizeEx() != 0 && code == 0 {
+		fmt.Fprintln(os.Stderr, "gogopythontest: failed to finalize python")
+		code = 1
+	}
+	return code
+}
+
+// start initializes the main interpreter on the current thread.
+func start(exe string, setup func() error) error {
+	home, paths, err := py.FindPythonHomeAndPaths(exe)
+	if err != nil {
+		return fmt.Errorf("failed to find python home and paths for %s: %w", exe, err)
+	}
+	if err := py.LoadLibrary(exe); err != nil {
+		return fmt.Errorf("failed to load python library for %s: %w", exe, err)
+	}
+	if setup != nil {
+		if err := setup(); err != nil {
+			return fmt.Errorf("setup failed: %w", err)
+		}
+	}
+
+	preConfig := py.PyPreConfig{}
+	py.PyPreConfig_InitIsolatedConfig(&preConfig)
+	if err := statusError("preinitialize python", py.Py_PreInitialize(&preConfig)); err != nil {
+		return err
+	}
+
+	config := py.PyConfig_3_12{}
+	py.PyConfig_InitPythonConfig(&config)
+	defer py.PyConfig_Clear(&config)
+	if err := statusError("set python home", py.PyConfig_SetBytesString(&config, &config.Home, home)); err != nil {
+		return err
+	}
+	path := strings.Join(paths, ":")
+	if err := statusError("set python path", py.PyConfig_SetBytesString(&config, &config.PythonPathEnv, path)); err != nil {
+		return err
+	}
+	return statusError("initialize python", py.Py_InitializeFromConfig(&config))
+}
+
+func statusError(action string, status py.PyStatus) error {
+	if status.Type == 0 {
+		return nil
+	}
+	msg, _ := py.WCharToString(status.ErrMsg)
+	return fmt.Errorf("failed to %s: %s", action, msg)
+}
+
+// SkipWithoutPython skips the test if Main couldn't start Python. Tests
+// using NewInterpreter needn't call it.
+func SkipWithoutPython(t testing.TB) {
+	t.Helper()
+	if unavailable != nil {
+		t.Skipf("python isn't available: %v", unavailable)
+	}
+}
+
+// Options configure an Interpreter.
+type Options struct {
+	// Config is the sub-interpreter's configuration. Defaults to an
+	// isolated interpreter with its own GIL.
+	Config *py.PyInterpreterConfig
+
+	// Imports is the interpreter's import policy.
+	Imports py.ImportPolicy
+
+	// Preload lists modules imported before the test runs, so the memory
+	// they keep alive isn't mistaken for a leak.
+	Preload []string
+
+	// AllowLeaks disables the leak check.
+	AllowLeaks bool
+}
+
+// Interpreter is a sub-interpreter owned by a test, whose thread state is
+// current on the test's goroutine until the test ends.
+//
+// It can only be used from the goroutine of the test that created it, not
+// from its subtests, which need interpreters of their own.
+type Interpreter struct {
+	ts      py.PyThreadStatePtr
+	state   py.PyInterpreterStatePtr
+	globals py.PyObjectPtr
+	owned   []py.PyObjectPtr
+
+	// Leak check.
+	blocks  py.PyObjectPtr // The blocks() helper, or NullPyObjectPtr.
+	modules int64
+	before  int64
+}
+
+// NewInterpreter creates an isolated sub-interpreter with its own GIL for
+// the test, ending it when the test ends.
+func NewInterpreter(t testing.TB) *Interpreter {
+	t.Helper()
+	return NewInterpreterWithOptions(t, Options{})
+}
+
+// NewInterpreterWithOptions creates a sub-interpreter for the test, ending it
+// when the test ends.
+//
+// When the test ends, the objects returned by Eval are released and the
+// interpreter's globals cleared, then the test fails if a Python exception
+// is set or if Python has more memory blocks allocated than when the test
+// started. Memory is only attributed to the interpreter if it doesn't use
+// the main interpreter's allocator, and imports keep memory alive until the
+// interpreter ends, so the leak check is skipped if the test imports modules
+// other than those in Options.Preload.
+//
+// The test is skipped if Main couldn't start Python.
+func NewInterpreterWithOptions(t testing.TB, opts Options) *Interpreter {
+	t.Helper()
+	SkipWithoutPython(t)
+	config := opts.Config
+	if config == nil {
+		config = &py.PyInterpreterConfig{
+			Gil:                        py.OwnGil,
+			CheckMultiInterpExtensions: 1,
+		}
+	}
+
+	// The thread state is bound to the OS thread, so keep the test's
+	// goroutine on it.
+	runtime.LockOSThread()
+	ts, err := py.NewInterpreter(config, opts.Imports)
+	if err != nil {
+		runtime.UnlockOSThread()
+		t.Fatalf("failed to create interpreter: %v", err)
+	}
+	i := &Interpreter{ts: ts, state: py.PyInterpreterState_Get(), globals: newGlobals()}
+	t.Cleanup(func() {
+		i.check(t)
+		py.Py_DecRef(i.blocks)
+		py.Py_DecRef(i.globals)
+		py.Py_EndInterpreter(i.ts)
+		runtime.UnlockOSThread()
+	})
+
+	for _, name := range opts.Preload {
+		mod := py.PyImport_ImportModule(name)
+		if mod == py.NullPyObjectPtr {
+			t.Fatalf("failed to import %s: %v", name, py.FetchError())
+		}
+		py.Py_DecRef(mod)
+	}
+	// FetchError formats tracebacks with the traceback module, which
+	// imports and caches on first use.
+	py.PyErr_SetString(py.PyExc_RuntimeError, "warm up")
+	_ = py.FetchError()
+	if !opts.AllowLeaks {
+		if i.blocks, err = i.compile(blocksSrc, "blocks"); err != nil {
+			t.Fatalf("failed to set up leak check: %v", err)
+		}
+		// The first measurement allocates on its own.
+		i.measure()
+		i.modules, i.before = i.measure()
+	}
+	return i
+}
+
+// Helper measuring the memory allocated by an interpreter. The type
+// attribute cache keeps the names looked up alive, and PyObject_GetAttrString
+// creates a new name every call, so it's cleared first. Interned strings,
+// e.g. the names of new variables, are immortal and not counted, like
+// regrtest's leak check does. The repr of containers keeps a list per thread
+// to detect recursion, which is created on first use.
+const blocksSrc = `
+import gc, sys
+
+def blocks():
+    repr([0])
+    sys._clear_type_cache()
+    gc.collect()
+    return len(sys.modules), sys.getallocatedblocks() - sys.getunicodeinternedsize()
+`
+
+// measure returns the number of loaded modules and of allocated memory
+// blocks.
+func (i *Interpreter) measure() (modules, blocks int64) {
+	res := py.PyObject_CallNoArgs(i.blocks)
+	if res == py.NullPyObjectPtr {
+		py.PyErr_Clear()
+		return 0, 0
+	}
+	defer py.Py_DecRef(res)
+	var counts [2]int64
+	if err := py.FromPython(res, &counts); err != nil {
+		return 0, 0
+	}
+	return counts[0], counts[1]
+}
+
+// check fails the test if it left an exception set or leaked memory.
+func (i *Interpreter) check(t testing.TB) {
+	if err := py.FetchError(); err != nil {
+		t.Errorf("test left a python exception set: %v", err)
+	}
+	for _, obj := range i.owned {
+		py.Py_DecRef(obj)
+	}
+	i.owned = nil
+	py.PyDict_Clear(i.globals)
+	if i.blocks == py.NullPyObjectPtr {
+		return
+	}
+
+	modules, after := i.measure()
+	switch {
+	case modules != i.modules:
+		t.Logf("skipped leak check: the test imported %d modules, consider preloading them", modules-i.modules)
+	case after > i.before:
+		t.Errorf("test leaked %d python memory blocks", after-i.before)
+	}
+}
+
+// State returns the interpreter's state.
+func (i *Interpreter) State() py.PyInterpreterStatePtr {
+	return i.state
+}
+
+// ThreadState returns the thread state of the test's goroutine.
+func (i *Interpreter) ThreadState() py.PyThreadStatePtr {
+	return i.ts
+}
+
+// Globals returns the dict used as globals and locals by Exec and the
+// helpers. It's cleared when the test ends.
+func (i *Interpreter) Globals() py.PyObjectPtr {
+	return i.globals
+}
+
+// newGlobals returns a new dict with builtins, like a module's namespace.
+func newGlobals() py.PyObjectPtr {
+	globals := py.PyDict_New()
+	builtins := py.PyImport_ImportModule("builtins")
+	py.PyDict_SetItemString(globals, "__builtins__", builtins)
+	py.Py_DecRef(builtins)
+	return globals
+}
+
+// compile runs Python source in a fresh namespace and returns a new
+// reference to one of the names it defines.
+func (i *Interpreter) compile(src, name string) (py.PyObjectPtr, error) {
+	globals := newGlobals()
+	defer py.Py_DecRef(globals)
+	res := py.PyRun_String(src, py.PyFileInput, globals, globals)
+	if res == py.NullPyObjectPtr {
+		return py.NullPyObjectPtr, py.FetchError()
+	}
+	py.Py_DecRef(res)
+
+	obj := py.PyDict_GetItemString(globals, name)
+	if obj == py.NullPyObjectPtr {
+		return py.NullPyObjectPtr, errors.New(name + " isn't defined")
+	}
+	py.Py_IncRef(obj)
+	return obj, nil
+}
@@ -0,0 +1,130 @@
+package gogopythontest
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(Main(m))
+}
+
+func TestRequireEval(t *testing.T) {
+	interp := NewInterpreter(t)
+	RequireEval(t, interp, "1+1", 2)
+	RequireEval(t, interp, "'a' * 3", "aaa")
+	RequireEval(t, interp, "{'a': [1, 2]}", map[string][]int{"a": {1, 2}})
+	RequireEval(t, interp, "None", nil)
+}
+
+func TestRequireExec(t *testing.T) {
+	interp := NewInterpreter(t)
+	RequireExec(t, interp, "def double(x):\n    return 2 * x")
+	RequireEval(t, interp, "double(21)", 42)
+}
+
+func TestRequireRaises(t *testing.T) {
+	interp := NewInterpreter(t)
+	exc := RequireRaises(t, interp, "int('x')", "ValueError")
+	if !strings.Contains(exc.Message, "invalid literal") {
+		t.Errorf("unexpected message %q", exc.Message)
+	}
+	RequireNoException(t)
+}
+
+func TestEval(t *testing.T) {
+	interp := NewInterpreter(t)
+	obj := Eval(t, interp, "[1, 2, 3]")
+	if n := py.PyList_Size(obj); n != 3 {
+		t.Errorf("len = %d, want 3", n)
+	}
+}
+
+func TestPreload(t *testing.T) {
+	interp := NewInterpreterWithOptions(t, Options{Preload: []string{"json"}})
+	RequireExec(t, interp, "import json")
+	RequireEval(t, interp, "json.dumps([1])", "[1]")
+}
+
+func TestParallel(t *testing.T) {
+	for _, name := range []string{"a", "b", "c"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			interp := NewInterpreter(t)
+			RequireExec(t, interp, "total = sum(range(100000))")
+			RequireEval(t, interp, "total", 4999950000)
+		})
+	}
+}
+
+// recorder is a testing.TB recording failures and running cleanups on
+// demand.
+type recorder struct {
+	testing.TB
+	errors   []string
+	logs     []string
+	cleanups []func()
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, format)
+}
+
+func (r *recorder) Logf(format string, args ...any) {
+	r.logs = append(r.logs, format)
+}
+
+func (r *recorder) Cleanup(fn func()) {
+	r.cleanups = append(r.cleanups, fn)
+}
+
+func (r *recorder) end() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestExceptionLeftSet(t *testing.T) {
+	r := &recorder{TB: t}
+	NewInterpreter(r)
+	py.PyErr_SetString(py.PyExc_RuntimeError, "oops")
+	r.end()
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "exception") {
+		t.Errorf("errors = %q, want a left exception", r.errors)
+	}
+}
+
+func TestLeak(t *testing.T) {
+	r := &recorder{TB: t}
+	interp := NewInterpreter(r)
+	// Take a reference that's never released.
+	py.Py_IncRef(Eval(r, interp, "[1, 2, 3]"))
+	r.end()
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "leaked") {
+		t.Errorf("errors = %q, want a leak", r.errors)
+	}
+}
+
+func TestNoLeak(t *testing.T) {
+	r := &recorder{TB: t}
+	interp := NewInterpreter(r)
+	RequireExec(r, interp, "class A:\n    pass\nitems = [A() for _ in range(100)]")
+	Eval(r, interp, "{'a': 'b' * 10}")
+	r.end()
+	if len(r.errors) != 0 {
+		t.Errorf("errors = %q, want none", r.errors)
+	}
+}
+
+func TestLeakCheckSkippedOnImport(t *testing.T) {
+	r := &recorder{TB: t}
+	interp := NewInterpreter(r)
+	RequireExec(r, interp, "import json")
+	r.end()
+	if len(r.errors) != 0 || len(r.logs) != 1 {
+		t.Errorf("errors = %q, logs = %q, want a skipped check", r.errors, r.logs)
+	}
+}
@@ -0,0 +1,95 @@
+package gogopythontest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+)
+
+// Exec runs Python statements in the interpreter's globals, returning the
+// exception they raised, if any.
+func (i *Interpreter) Exec(src string) error {
+	res := py.PyRun_String(src, py.PyFileInput, i.globals, i.globals)
+	if res == py.NullPyObjectPtr {
+		return py.FetchError()
+	}
+	py.Py_DecRef(res)
+	return nil
+}
+
+// Eval evaluates a Python expression in the interpreter's globals, failing
+// the test if it raises. The result is released when the test ends.
+func Eval(t testing.TB, interp *Interpreter, expr string) py.PyObjectPtr {
+	t.Helper()
+	res := py.PyRun_String(expr, py.PyEvalInput, interp.globals, interp.globals)
+	if res == py.NullPyObjectPtr {
+		t.Fatalf("%s raised %v", expr, py.FetchError())
+	}
+	interp.owned = append(interp.owned, res)
+	return res
+}
+
+// RequireExec runs Python statements in the interpreter's globals, failing
+// the test if they raise.
+func RequireExec(t testing.TB, interp *Interpreter, src string) {
+	t.Helper()
+	if err := interp.Exec(src); err != nil {
+		t.Fatalf("exec raised %v", err)
+	}
+}
+
+// RequireEval evaluates a Python expression in the interpreter's globals and
+// fails the test unless it equals want once converted with FromPython to
+// want's type. E.g.
+//
+//	RequireEval(t, interp, "1+1", 2)
+//	RequireEval(t, interp, "{'a': [1]}", map[string][]int{"a": {1}})
+func RequireEval(t testing.TB, interp *Interpreter, expr string, want any) {
+	t.Helper()
+	res := py.PyRun_String(expr, py.PyEvalInput, interp.globals, interp.globals)
+	if res == py.NullPyObjectPtr {
+		t.Fatalf("%s raised %v", expr, py.FetchError())
+	}
+	defer py.Py_DecRef(res)
+
+	if want == nil {
+		if res != py.Py_None {
+			t.Fatalf("%s = %s, want None", expr, py.TypeName(res))
+		}
+		return
+	}
+	got := reflect.New(reflect.TypeOf(want))
+	if err := py.FromPython(res, got.Interface()); err != nil {
+		t.Fatalf("%s: %v", expr, err)
+	}
+	if !reflect.DeepEqual(got.Elem().Interface(), want) {
+		t.Fatalf("%s = %#v, want %#v", expr, got.Elem().Interface(), want)
+	}
+}
+
+// RequireRaises runs Python statements in the interpreter's globals and fails
+// the test unless they raise an exception of the given type, e.g.
+// "ValueError", which is returned.
+func RequireRaises(t testing.TB, interp *Interpreter, src, excType string) *py.Exception {
+	t.Helper()
+	err := interp.Exec(src)
+	if err == nil {
+		t.Fatalf("no %s raised", excType)
+	}
+	var exc *py.Exception
+	if !errors.As(err, &exc) || exc.Type != excType {
+		t.Fatalf("raised %v, want %s", err, excType)
+	}
+	return exc
+}
+
+// RequireNoException fails the test if a Python exception is set, clearing
+// it.
+func RequireNoException(t testing.TB) {
+	t.Helper()
+	if err := py.FetchError(); err != nil {
+		t.Fatalf("python exception set: %v", err)
+	}
+}
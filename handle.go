@@ -0,0 +1,37 @@
+package gogopython
+
+import "sync"
+
+// Go values referenced from Python objects can't be handed to Python
+// directly as the Go garbage collector doesn't know about references held by
+// Python. Instead, values are stored in a table and Python holds on to an
+// opaque, non-zero handle that's released when the Python object is freed.
+var handles = struct {
+	sync.Mutex
+	next   uintptr
+	values map[uintptr]any
+}{values: make(map[uintptr]any)}
+
+// newHandle stores v in the handle table and returns its handle.
+func newHandle(v any) uintptr {
+	handles.Lock()
+	defer handles.Unlock()
+	handles.next++
+	handles.values[handles.next] = v
+	return handles.next
+}
+
+// handleValue returns the value for handle h, if it exists.
+func handleValue(h uintptr) (any, bool) {
+	handles.Lock()
+	defer handles.Unlock()
+	v, ok := handles.values[h]
+	return v, ok
+}
+
+// releaseHandle removes handle h from the table.
+func releaseHandle(h uintptr) {
+	handles.Lock()
+	defer handles.Unlock()
+	delete(handles.values, h)
+}
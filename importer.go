@@ -0,0 +1,246 @@
+package gogopython
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sync/atomic"
+)
+
+// Python finder, loader and importlib.resources support for modules served
+// from an fs.FS. The Go side only provides stat, read and list functions.
+const fsImporterSrc = `
+import importlib.abc
+import importlib.resources.abc
+import importlib.util
+import io
+import sys
+
+
+class GoFSPath(importlib.resources.abc.Traversable):
+    def __init__(self, fs, path):
+        self._fs = fs
+        self._path = path
+
+    @property
+    def name(self):
+        return self._path.rsplit("/", 1)[-1]
+
+    def _join(self, name):
+        return name if self._path in ("", ".") else self._path + "/" + name
+
+    def iterdir(self):
+        if not self.is_dir():
+            raise NotADirectoryError(self._path)
+        return (GoFSPath(self._fs, self._join(name)) for name in self._fs.list(self._path or "."))
+
+    def is_dir(self):
+        return self._fs.stat(self._path or ".") == "dir"
+
+    def is_file(self):
+        return self._fs.stat(self._path) == "file"
+
+    def joinpath(self, *descendants):
+        path = self
+        for d in descendants:
+            for part in str(d).split("/"):
+                if part:
+                    path = GoFSPath(self._fs, path._join(part))
+        return path
+
+    def __truediv__(self, child):
+        return self.joinpath(child)
+
+    def open(self, mode="r", *args, **kwargs):
+        if mode not in ("r", "rb"):
+            raise ValueError(f"invalid mode: {mode!r}")
+        if not self.is_file():
+            raise FileNotFoundError(self._path)
+        data = io.BytesIO(self._fs.read(self._path))
+        if mode == "rb":
+            return data
+        return io.TextIOWrapper(data, *args, **kwargs)
+
+    def __str__(self):
+        return self._fs.origin + self._path
+
+
+class GoFSReader(importlib.resources.abc.TraversableResources):
+    def __init__(self, fs, path):
+        self._fs = fs
+        self._path = path
+
+    def files(self):
+        return GoFSPath(self._fs, self._path)
+
+
+class GoFSLoader(importlib.abc.InspectLoader):
+    def __init__(self, fs, fullname, path, is_package):
+        self._fs = fs
+        self._fullname = fullname
+        self._path = path
+        self._is_package = is_package
+
+    def create_module(self, spec):
+        return None
+
+    def exec_module(self, module):
+        exec(self.get_code(module.__name__), module.__dict__)
+
+    def is_package(self, fullname):
+        return self._is_package
+
+    def get_source(self, fullname):
+        return importlib.util.decode_source(self._fs.read(self._path))
+
+    def get_code(self, fullname):
+        return compile(self.get_source(fullname), self._fs.origin + self._path, "exec", dont_inherit=True)
+
+    def get_resource_reader(self, fullname):
+        if not self._is_package:
+            return None
+        return GoFSReader(self._fs, self._path.rsplit("/", 1)[0])
+
+
+class GoFSFinder(importlib.abc.MetaPathFinder):
+    def __init__(self, origin, stat, read, list):
+        self.origin = origin
+        self.stat = stat
+        self.read = read
+        self.list = list
+
+    def find_spec(self, fullname, path=None, target=None):
+        # Only serve submodules of packages we loaded.
+        if path is not None and not any(str(p).startswith(self.origin) for p in path):
+            return None
+        base = fullname.replace(".", "/")
+        init = base + "/__init__.py"
+        if self.stat(init) == "file":
+            loader = GoFSLoader(self, fullname, init, True)
+            spec = importlib.util.spec_from_loader(fullname, loader, origin=self.origin + init, is_package=True)
+            spec.submodule_search_locations = [self.origin + base]
+        elif self.stat(base + ".py") == "file":
+            loader = GoFSLoader(self, fullname, base + ".py", False)
+            spec = importlib.util.spec_from_loader(fullname, loader, origin=self.origin + base + ".py")
+        else:
+            return None
+        spec.has_location = True
+        return spec
+
+    def invalidate_caches(self):
+        pass
+`
+
+// Counter used to give each installed fs.FS a distinct origin.
+var fsImporters atomic.Int64
+
+// InstallFS adds a finder to sys.meta_path in the current interpreter that
+// imports modules and packages from fsys, e.g. an embed.FS, so Python code
+// can be shipped inside the Go binary.
+//
+// Module "a.b" is found at "a/b.py" or, as a package, "a/b/__init__.py".
+// Packages support importlib.resources, serving data files from fsys. File
+// names in tracebacks are prefixed with "gofs:N/" to identify the source.
+//
+// The fsys may be used by several interpreters concurrently. The returned
+// func removes the finder. Both require the caller to hold the GIL.
+func InstallFS(fsys fs.FS) (remove func(), err error) {
+	stat, err := ExportFunc("stat", func(name string) string {
+		info, err := fs.Stat(fsys, name)
+		switch {
+		case err != nil:
+			return ""
+		case info.IsDir():
+			return "dir"
+		case info.Mode().IsRegular():
+			return "file"
+		}
+		return ""
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer Py_DecRef(stat)
+
+	read, err := ExportFunc("read", func(name string) ([]byte, error) {
+		return fs.ReadFile(fsys, path.Clean(name))
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer Py_DecRef(read)
+
+	list, err := ExportFunc("list", func(name string) ([]string, error) {
+		entries, err := fs.ReadDir(fsys, path.Clean(name))
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name()
+		}
+		return names, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer Py_DecRef(list)
+
+	class, err := compileHelper(fsImporterSrc, "GoFSFinder")
+	if err != nil {
+		return nil, err
+	}
+	defer Py_DecRef(class)
+
+	origin, err := ToPython(fmt.Sprintf("gofs:%d/", fsImporters.Add(1)))
+	if err != nil {
+		return nil, err
+	}
+	args := newTuple(origin, stat, read, list)
+	Py_DecRef(origin)
+	finder := PyObject_CallObject(class, args)
+	Py_DecRef(args)
+	if finder == NullPyObjectPtr {
+		return nil, FetchError()
+	}
+
+	metaPath, err := sysAttr("meta_path")
+	if err != nil {
+		Py_DecRef(finder)
+		return nil, err
+	}
+	defer Py_DecRef(metaPath)
+	if PyList_Append(metaPath, finder) != 0 {
+		Py_DecRef(finder)
+		return nil, FetchError()
+	}
+
+	return func() {
+		if metaPath, err := sysAttr("meta_path"); err == nil {
+			if res := callMethod(metaPath, "remove", finder); res != NullPyObjectPtr {
+				Py_DecRef(res)
+			}
+			Py_DecRef(metaPath)
+		}
+		PyErr_Clear()
+		Py_DecRef(finder)
+	}, nil
+}
+
+// sysAttr returns a new reference to an attribute of the sys module.
+func sysAttr(name string) (PyObjectPtr, error) {
+	sys := PyImport_ImportModule("sys")
+	if sys == NullPyObjectPtr {
+		return NullPyObjectPtr, FetchError()
+	}
+	defer Py_DecRef(sys)
+	obj := PyObject_GetAttrString(sys, name)
+	if obj == NullPyObjectPtr {
+		if err := FetchError(); err != nil {
+			return NullPyObjectPtr, err
+		}
+		return NullPyObjectPtr, errors.New("sys." + name + " not found")
+	}
+	return obj, nil
+}
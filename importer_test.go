@@ -0,0 +1,47 @@
+package gogopython_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+func TestInstallFS(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{
+		Preload: []string{"importlib.resources", "traceback"},
+		// The finder's classes and imported modules live in sys.modules.
+		AllowLeaks: true,
+	})
+
+	remove, err := py.InstallFS(fstest.MapFS{
+		"greet.py":           {Data: []byte("def hello(name):\n    return f'hello {name}'\n")},
+		"pkg/__init__.py":    {Data: []byte("from . import sub\n")},
+		"pkg/sub.py":         {Data: []byte("VALUE = 42\n")},
+		"pkg/data/names.txt": {Data: []byte("alice\nbob\n")},
+		"broken.py":          {Data: []byte("def f():\n    raise ValueError('broken')\n")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireExec(t, interp, "import greet, pkg, broken, importlib.resources")
+	gogopythontest.RequireEval(t, interp, "greet.hello('go')", "hello go")
+	gogopythontest.RequireEval(t, interp, "pkg.sub.VALUE", 42)
+	gogopythontest.RequireEval(t, interp, "pkg.__spec__.submodule_search_locations[0].endswith('/pkg')", true)
+	gogopythontest.RequireEval(t, interp, "importlib.resources.files('pkg').joinpath('data/names.txt').read_text()", "alice\nbob\n")
+	gogopythontest.RequireEval(t, interp, "sorted(p.name for p in importlib.resources.files('pkg').iterdir())", []string{"__init__.py", "data", "sub.py"})
+	gogopythontest.RequireEval(t, interp, "importlib.resources.files('pkg').joinpath('data').is_dir()", true)
+	gogopythontest.RequireRaises(t, interp, "import missing", "ModuleNotFoundError")
+
+	// Tracebacks show the source from the fs.
+	exc := gogopythontest.RequireRaises(t, interp, "broken.f()", "ValueError")
+	if !strings.Contains(exc.Traceback, "/broken.py") || !strings.Contains(exc.Traceback, "raise ValueError('broken')") {
+		t.Errorf("traceback doesn't show the module source:\n%s", exc.Traceback)
+	}
+
+	remove()
+	gogopythontest.RequireExec(t, interp, "import sys\ndel sys.modules['greet']")
+	gogopythontest.RequireRaises(t, interp, "import greet", "ModuleNotFoundError")
+}
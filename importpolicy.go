@@ -0,0 +1,383 @@
+package gogopython
+
+import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ImportPolicy restricts which modules an interpreter can import.
+//
+// A policy is enforced by blocking denied modules in sys.modules and by a
+// guard at the front of sys.meta_path, which a script can undo, and by the
+// process-wide audit hook, which it can't. The hook checks the events
+// Python raises when loading a module whatever the import machinery used:
+// "import" for extension modules, and "exec" when running the code of a
+// source, bytecode or frozen module. Modules are recognized by the name of
+// the file their code was compiled from, relative to the entries of
+// sys.path when the policy is applied. So even if a script restores
+// sys.modules or sys.meta_path, a denied module found on that path still
+// can't be loaded.
+//
+// Built-in modules, listed in sys.builtin_module_names, raise no event when
+// loaded, so they're only blocked by sys.modules and the guard. Most are
+// loaded at startup anyway.
+//
+// Python sees a violation as a ModuleNotFoundError, which FetchError returns
+// as an *ImportDeniedError.
+type ImportPolicy struct {
+	// Deny lists modules that can never be imported, including their
+	// submodules, e.g. "ctypes", "_posixsubprocess" and "socket".
+	Deny []string
+
+	// Allow, if not empty, lists the only modules, including their
+	// submodules, that can be imported besides those already imported when
+	// the policy is applied. Modules imported by allowed modules must be
+	// listed too. Deny takes precedence.
+	Allow []string
+}
+
+// ImportDeniedError is returned when Python code tried to import a module
+// denied by the interpreter's ImportPolicy.
+type ImportDeniedError struct {
+	Exception
+
+	Module string // Module is the full name of the denied module.
+}
+
+func (e *ImportDeniedError) Unwrap() error {
+	return &e.Exception
+}
+
+// importPolicy is an applied ImportPolicy.
+type importPolicy struct {
+	ImportPolicy
+	loaded map[string]bool // Modules imported when the policy was applied.
+	roots  []string        // Absolute sys.path entries when the policy was applied.
+}
+
+// Python meta path finder that asks Go whether a module may be imported.
+const importGuardSrc = `
+class ImportGuard:
+    def __init__(self, check):
+        self._check = check
+
+    def find_spec(self, fullname, path=None, target=None):
+        self._check(fullname)
+        return None
+
+    def invalidate_caches(self):
+        pass
+`
+
+var importPolicies = struct {
+	sync.RWMutex
+	policies map[int64]*importPolicy
+}{policies: make(map[int64]*importPolicy)}
+
+// SetImportPolicy applies an import policy to the current interpreter.
+// A policy can't be removed or replaced once applied, and is forgotten when
+// the interpreter ends. An empty policy does nothing.
+//
+// Requires the caller to hold the GIL.
+func SetImportPolicy(policy ImportPolicy) error {
+	if len(policy.Deny) == 0 && len(policy.Allow) == 0 {
+		return nil
+	}
+	id := PyInterpreterState_GetID(PyInterpreterState_Get())
+
+	importPolicies.RLock()
+	_, exists := importPolicies.policies[id]
+	importPolicies.RUnlock()
+	if exists {
+		return fmt.Errorf("interpreter %d already has an import policy", id)
+	}
+	if err := installAuditHook(); err != nil {
+		return err
+	}
+
+	var err error
+	modules := PyImport_GetModuleDict()
+	p := &importPolicy{ImportPolicy: policy}
+	var loaded []string
+	for name, err := range Values[string](modules) {
+		if err != nil {
+			return err
+		}
+		loaded = append(loaded, name)
+	}
+	if len(policy.Allow) > 0 {
+		p.loaded = make(map[string]bool, len(loaded))
+		for _, name := range loaded {
+			p.loaded[name] = true
+		}
+	}
+	if p.roots, err = importRoots(); err != nil {
+		return err
+	}
+
+	// Block denied modules, including any that are already loaded.
+	for _, name := range loaded {
+		if p.denies(name) {
+			if PyDict_SetItemString(modules, name, Py_None) != 0 {
+				return FetchError()
+			}
+		}
+	}
+	for _, name := range policy.Deny {
+		if PyDict_SetItemString(modules, name, Py_None) != 0 {
+			return FetchError()
+		}
+	}
+
+	check := NewFunction("check", NullPyObjectPtr, func(_, args PyObjectPtr) PyObjectPtr {
+		var name string
+		if PyTuple_Size(args) != 1 || FromPython(PyTuple_GetItem(args, 0), &name) != nil {
+			PyErr_SetString(PyExc_TypeError, "check() takes a module name")
+			return NullPyObjectPtr
+		}
+		if p.denies(name) {
+			raiseImportDenied(name)
+			return NullPyObjectPtr
+		}
+		Py_IncRef(Py_None)
+		return Py_None
+	})
+	if check == NullPyObjectPtr {
+		return FetchError()
+	}
+	defer Py_DecRef(check)
+
+	class, err := compileHelper(importGuardSrc, "ImportGuard")
+	if err != nil {
+		return err
+	}
+	defer Py_DecRef(class)
+	guard := PyObject_CallOneArg(class, check)
+	if guard == NullPyObjectPtr {
+		return FetchError()
+	}
+	defer Py_DecRef(guard)
+
+	metaPath, err := sysAttr("meta_path")
+	if err != nil {
+		return err
+	}
+	defer Py_DecRef(metaPath)
+	if PyList_Insert(metaPath, 0, guard) != 0 {
+		return FetchError()
+	}
+
+	err = onInterpreterEnd(func() {
+		importPolicies.Lock()
+		delete(importPolicies.policies, id)
+		importPolicies.Unlock()
+	})
+	if err != nil {
+		return err
+	}
+	importPolicies.Lock()
+	importPolicies.policies[id] = p
+	importPolicies.Unlock()
+	return nil
+}
+
+// NewInterpreter creates a sub-interpreter using Py_NewInterpreterFromConfig
+// and applies an import policy before any other code runs in it.
+//
+// Like Py_NewInterpreterFromConfig, the new interpreter's thread state is
+// made current. If the policy can't be applied, the interpreter is ended and
+// no thread state is current.
+func NewInterpreter(config *PyInterpreterConfig, imports ImportPolicy) (PyThreadStatePtr, error) {
+	var ts PyThreadStatePtr
+	if status := Py_NewInterpreterFromConfig(&ts, config); status.Type != 0 {
+		msg, _ := WCharToString(status.ErrMsg)
+		return NullThreadState, fmt.Errorf("failed to create interpreter: %s", msg)
+	}
+	if err := SetImportPolicy(imports); err != nil {
+		Py_EndInterpreter(ts)
+		return NullThreadState, err
+	}
+	return ts, nil
+}
+
+// importRoots returns the str entries of sys.path as absolute paths.
+func importRoots() ([]string, error) {
+	path, err := sysAttr("path")
+	if err != nil {
+		return nil, err
+	}
+	defer Py_DecRef(path)
+	var roots []string
+	for item, err := range Iter(path) {
+		if err != nil {
+			return nil, err
+		}
+		var entry string
+		if FromPython(item, &entry) != nil {
+			PyErr_Clear()
+			continue
+		}
+		root, err := filepath.Abs(entry)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+	return roots, nil
+}
+
+// moduleForFile returns the name of the module whose code is compiled from
+// filename, or "" if it's not a module found on the policy's roots. Frozen
+// modules are named by their filename, e.g. "<frozen os>".
+func (p *importPolicy) moduleForFile(filename string) string {
+	if name, ok := strings.CutPrefix(filename, "<frozen "); ok {
+		return strings.TrimSuffix(name, ">")
+	}
+	// Roots may be nested, e.g. lib/python3.12 and its lib-dynload, so the
+	// longest one holding a valid module path wins.
+	var module string
+	var longest int
+	for _, root := range p.roots {
+		rel, ok := strings.CutPrefix(filename, root+string(filepath.Separator))
+		if !ok || len(root) < longest {
+			continue
+		}
+		if name, ok := moduleName(rel); ok {
+			module, longest = name, len(root)
+		}
+	}
+	return module
+}
+
+// moduleName converts the path of a source file relative to its root, e.g.
+// "json/decoder.py" or "json/__init__.py", into a module name.
+func moduleName(rel string) (string, bool) {
+	rel, ok := strings.CutSuffix(rel, ".py")
+	if !ok {
+		return "", false
+	}
+	rel = strings.TrimSuffix(rel, string(filepath.Separator)+"__init__")
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, part := range parts {
+		if !token.IsIdentifier(part) {
+			return "", false
+		}
+	}
+	return strings.Join(parts, "."), true
+}
+
+// denies reports whether the policy prevents importing the named module.
+func (p *importPolicy) denies(name string) bool {
+	if matchModule(name, p.Deny) {
+		return true
+	}
+	return len(p.Allow) > 0 && !p.loaded[name] && !matchModule(name, p.Allow)
+}
+
+// matchModule reports whether name is one of modules, or a submodule of one.
+func matchModule(name string, modules []string) bool {
+	for _, m := range modules {
+		if name == m || strings.HasPrefix(name, m+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkImport enforces the current interpreter's import policy for an
+// "import" audit event, returning false with an exception set if denied.
+func checkImport(id int64, args PyObjectPtr) bool {
+	importPolicies.RLock()
+	p, ok := importPolicies.policies[id]
+	importPolicies.RUnlock()
+	if !ok {
+		return true
+	}
+	var name string
+	if FromPython(PyTuple_GetItem(args, 0), &name) != nil {
+		PyErr_Clear()
+		return true
+	}
+	if p.denies(name) {
+		raiseImportDenied(name)
+		return false
+	}
+	return true
+}
+
+// checkExec enforces the current interpreter's import policy for an "exec"
+// audit event, which is raised when the import machinery runs a module's
+// code, returning false with an exception set if denied.
+func checkExec(id int64, args PyObjectPtr) bool {
+	importPolicies.RLock()
+	p, ok := importPolicies.policies[id]
+	importPolicies.RUnlock()
+	if !ok {
+		return true
+	}
+	// Args are (code,).
+	obj := PyObject_GetAttrString(PyTuple_GetItem(args, 0), "co_filename")
+	if obj == NullPyObjectPtr {
+		PyErr_Clear()
+		return true
+	}
+	defer Py_DecRef(obj)
+	var filename string
+	if FromPython(obj, &filename) != nil {
+		PyErr_Clear()
+		return true
+	}
+	if name := p.moduleForFile(filename); name != "" && p.denies(name) {
+		raiseImportDenied(name)
+		return false
+	}
+	return true
+}
+
+// raiseImportDenied raises a ModuleNotFoundError for a denied module.
+func raiseImportDenied(name string) {
+	msg, _ := ToPython(fmt.Sprintf("import of '%s' is denied by the import policy", name))
+	args := newTuple(msg)
+	Py_DecRef(msg)
+	defer Py_DecRef(args)
+
+	kwargs := PyDict_New()
+	defer Py_DecRef(kwargs)
+	module, _ := ToPython(name)
+	PyDict_SetItemString(kwargs, "name", module)
+	Py_DecRef(module)
+
+	exc := PyObject_Call(PyExc_ModuleNotFoundError, args, kwargs)
+	if exc == NullPyObjectPtr {
+		// The error from creating the exception is raised instead.
+		return
+	}
+	PyErr_SetRaisedException(exc)
+}
+
+// newImportDenied returns an *ImportDeniedError if exc is an ImportError for
+// a module denied by the current interpreter's import policy.
+func newImportDenied(exc PyObjectPtr) *ImportDeniedError {
+	id := PyInterpreterState_GetID(PyInterpreterState_Get())
+	importPolicies.RLock()
+	p, ok := importPolicies.policies[id]
+	importPolicies.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	obj := PyObject_GetAttrString(exc, "name")
+	if obj == NullPyObjectPtr {
+		PyErr_Clear()
+		return nil
+	}
+	defer Py_DecRef(obj)
+	var name string
+	if FromPython(obj, &name) != nil || !p.denies(name) {
+		return nil
+	}
+	return &ImportDeniedError{Exception: *newException(exc), Module: name}
+}
@@ -0,0 +1,83 @@
+package gogopython_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// Removes the import guard and the blocked sys.modules entry before
+// importing a module with importlib, which raises no "import" audit event.
+const importBypassSrc = `
+import importlib, sys
+sys.meta_path[:] = [f for f in sys.meta_path if type(f).__name__ != "ImportGuard"]
+sys.modules.pop(%[1]q, None)
+importlib.import_module(%[1]q)
+`
+
+func TestImportPolicyBypass(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		policy py.ImportPolicy
+		module string
+	}{
+		{"deny source module", py.ImportPolicy{Deny: []string{"shlex"}}, "shlex"},
+		{"deny package", py.ImportPolicy{Deny: []string{"ctypes"}}, "ctypes"},
+		{"deny extension module", py.ImportPolicy{Deny: []string{"_csv"}}, "_csv"},
+		{"deny frozen module", py.ImportPolicy{Deny: []string{"zipimport"}}, "zipimport"},
+		{"allow", py.ImportPolicy{Allow: []string{"gc", "importlib", "warnings"}}, "shlex"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{
+				Imports: tt.policy,
+				Preload: []string{"importlib"},
+			})
+			exc := gogopythontest.RequireRaises(t, interp, fmt.Sprintf(importBypassSrc, tt.module), "ModuleNotFoundError")
+			if exc.Message != fmt.Sprintf("import of '%s' is denied by the import policy", tt.module) {
+				t.Errorf("raised %q", exc.Message)
+			}
+			gogopythontest.RequireEval(t, interp, fmt.Sprintf("%q in sys.modules", tt.module), false)
+		})
+	}
+}
+
+func TestSetImportPolicy(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{
+		// The policy keeps state in the interpreter's dict until it ends.
+		AllowLeaks: true,
+	})
+	if err := py.SetImportPolicy(py.ImportPolicy{}); err != nil {
+		t.Fatalf("SetImportPolicy(empty) = %v", err)
+	}
+	gogopythontest.RequireExec(t, interp, "import shlex")
+
+	if err := py.SetImportPolicy(py.ImportPolicy{Deny: []string{"json"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := py.SetImportPolicy(py.ImportPolicy{Deny: []string{"csv"}}); err == nil {
+		t.Error("a second policy was applied")
+	}
+
+	gogopythontest.RequireExec(t, interp, "import shlex")
+	err := interp.Exec("import json.decoder")
+	var denied *py.ImportDeniedError
+	if !errors.As(err, &denied) || denied.Module != "json.decoder" {
+		t.Errorf("importing a denied module raised %v, want an *ImportDeniedError", err)
+	}
+}
+
+func TestImportPolicyAllow(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{
+		// The leak check needs gc.
+		Imports: py.ImportPolicy{Allow: []string{"gc", "colorsys", "xml"}, Deny: []string{"xml.dom"}},
+	})
+	// Modules imported before the policy was applied stay importable.
+	gogopythontest.RequireExec(t, interp, "import sys, io")
+	gogopythontest.RequireExec(t, interp, "import colorsys, xml, xml.etree")
+	gogopythontest.RequireRaises(t, interp, "import shlex", "ModuleNotFoundError")
+	// Deny takes precedence over Allow.
+	gogopythontest.RequireRaises(t, interp, "import xml.dom", "ModuleNotFoundError")
+}
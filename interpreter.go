@@ -0,0 +1,63 @@
+package gogopython
+
+import (
+	"errors"
+	"sync"
+)
+
+// Funcs run when an interpreter ends, keyed by interpreter id.
+var interpreterCleanups = struct {
+	sync.Mutex
+	funcs map[int64][]func()
+}{funcs: make(map[int64][]func())}
+
+// Key of the capsule running the cleanups in the interpreter's dict.
+const interpreterCleanupKey = "gogopython.cleanup"
+
+// interpreterEnd is stored in a capsule in the interpreter's dict, which is
+// cleared when the interpreter ends, with Py_EndInterpreter or
+// Py_FinalizeEx, whether or not Python code ran atexit handlers.
+type interpreterEnd struct {
+	id int64
+}
+
+func (e *interpreterEnd) finalize() {
+	interpreterCleanups.Lock()
+	funcs := interpreterCleanups.funcs[e.id]
+	delete(interpreterCleanups.funcs, e.id)
+	interpreterCleanups.Unlock()
+	for _, fn := range funcs {
+		fn()
+	}
+}
+
+// onInterpreterEnd registers fn to release Go state kept for the current
+// interpreter when it ends. The fn is called with the GIL held, while the
+// interpreter is torn down, so it must not run Python code.
+//
+// Requires the caller to hold the GIL.
+func onInterpreterEnd(fn func()) error {
+	interp := PyInterpreterState_Get()
+	id := PyInterpreterState_GetID(interp)
+	dict := PyInterpreterState_GetDict(interp)
+	if dict == NullPyObjectPtr {
+		return errors.New("interpreter has no dict")
+	}
+	if PyDict_GetItemString(dict, interpreterCleanupKey) == NullPyObjectPtr {
+		capsule, err := NewCapsule(interpreterCleanupKey, &interpreterEnd{id: id})
+		if err != nil {
+			return err
+		}
+		rc := PyDict_SetItemString(dict, interpreterCleanupKey, capsule)
+		Py_DecRef(capsule)
+		if rc != 0 {
+			PyErr_Clear()
+			return errors.New("failed to register interpreter cleanup")
+		}
+	}
+
+	interpreterCleanups.Lock()
+	interpreterCleanups.funcs[id] = append(interpreterCleanups.funcs[id], fn)
+	interpreterCleanups.Unlock()
+	return nil
+}
@@ -0,0 +1,195 @@
+package gogopython
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+	"runtime"
+	"sync"
+
+	"github.com/ebitengine/purego"
+)
+
+// Iter returns a Go iterator over the items of a Python iterable, such as a
+// list, generator or any object supporting iter().
+//
+// Items are borrowed references that are only valid for the body of the
+// loop. Use Py_IncRef to hold on to an item.
+//
+// Iteration ends when the Python iterator is exhausted. If Python raises an
+// exception, it's yielded as an error with a NullPyObjectPtr item and
+// iteration ends.
+//
+// The caller must hold the GIL for the whole loop. The goroutine is locked
+// to its OS thread while iterating.
+func Iter(iterable PyObjectPtr) iter.Seq2[PyObjectPtr, error] {
+	return func(yield func(PyObjectPtr, error) bool) {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		it := PyObject_GetIter(iterable)
+		if it == NullPyObjectPtr {
+			yield(NullPyObjectPtr, FetchError())
+			return
+		}
+		defer Py_DecRef(it)
+
+		for {
+			item := PyIter_Next(it)
+			if item == NullPyObjectPtr {
+				// NULL without an exception means StopIteration.
+				if err := FetchError(); err != nil {
+					yield(NullPyObjectPtr, err)
+				}
+				return
+			}
+			more := yield(item, nil)
+			Py_DecRef(item)
+			if !more {
+				return
+			}
+		}
+	}
+}
+
+// Values is like Iter, but converts each item into a T using FromPython.
+//
+// A conversion failure is yielded as an error and ends iteration.
+func Values[T any](iterable PyObjectPtr) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for item, err := range Iter(iterable) {
+			var v T
+			if err == nil {
+				err = FromPython(item, &v)
+			}
+			if !yield(v, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// NewIterator creates a Python iterator object producing the values of seq,
+// converted using ToPython.
+//
+// The GIL is released while waiting on seq, so seq must not call into
+// Python. If the Python iterator is collected before seq is exhausted, seq
+// is stopped.
+//
+// Returns a new reference. Requires the caller to hold the GIL.
+func NewIterator[T any](seq iter.Seq[T]) (PyObjectPtr, error) {
+	// iter.Pull can't be used as its coroutines can't be resumed from
+	// within a callback from C, so run seq in its own goroutine instead.
+	ch := make(chan T)
+	done := make(chan struct{})
+	var start sync.Once
+	it := &goIterator{
+		next: func() (any, bool) {
+			start.Do(func() {
+				go func() {
+					defer close(ch)
+					for v := range seq {
+						select {
+						case ch <- v:
+						case <-done:
+							return
+						}
+					}
+				}()
+			})
+			v, ok := <-ch
+			return v, ok
+		},
+		stop: func() { close(done) },
+	}
+	return wrapIterator(it)
+}
+
+// NewChanIterator creates a Python iterator object producing values received
+// from ch, converted using ToPython, until ch is closed.
+//
+// The GIL is released while waiting to receive.
+//
+// Returns a new reference. Requires the caller to hold the GIL.
+func NewChanIterator[T any](ch <-chan T) (PyObjectPtr, error) {
+	it := &goIterator{
+		next: func() (any, bool) {
+			v, ok := <-ch
+			return v, ok
+		},
+	}
+	return wrapIterator(it)
+}
+
+// goIterator is the Go value backing a Python iterator made from Go.
+type goIterator struct {
+	mu   sync.Mutex
+	next func() (any, bool)
+	stop func()
+	done bool
+}
+
+func (it *goIterator) finalize() {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.stop != nil && !it.done {
+		it.stop()
+	}
+	it.done = true
+}
+
+var iteratorClass struct {
+	once  sync.Once
+	class *Class
+	err   error
+}
+
+func wrapIterator(it *goIterator) (PyObjectPtr, error) {
+	iteratorClass.once.Do(func() {
+		b := NewClass[goIterator]("GoIterator")
+		b.slots = []PyTypeSlot{
+			{Slot: TypeSlotIter, Value: PyObject_SelfIter},
+			{Slot: TypeSlotIterNext, Value: purego.NewCallback(iteratorNext)},
+		}
+		iteratorClass.class, iteratorClass.err = b.Build()
+	})
+	if iteratorClass.err != nil {
+		return NullPyObjectPtr, iteratorClass.err
+	}
+	return iteratorClass.class.Wrap(it)
+}
+
+// iteratorNext implements tp_iternext for Go iterators.
+func iteratorNext(self PyObjectPtr) (result PyObjectPtr) {
+	defer recoverCallback(nil, &result)
+
+	v, err := iteratorClass.class.value(self)
+	if err != nil {
+		PyErr_SetString(PyExc_TypeError, err.Error())
+		return NullPyObjectPtr
+	}
+	it := v.(*goIterator)
+
+	// Don't hold the GIL while Go produces the next value.
+	ts := PyEval_SaveThread()
+	it.mu.Lock()
+	var val any
+	ok := false
+	if !it.done {
+		val, ok = it.next()
+		it.done = !ok
+	}
+	it.mu.Unlock()
+	PyEval_RestoreThread(ts)
+
+	if !ok {
+		// Returning NULL without an exception raises StopIteration.
+		return NullPyObjectPtr
+	}
+	obj, err := toPython(reflect.ValueOf(&val).Elem())
+	if err != nil {
+		PyErr_SetString(PyExc_TypeError, fmt.Sprintf("iterator value: %v", err))
+		return NullPyObjectPtr
+	}
+	return obj
+}
@@ -0,0 +1,119 @@
+package gogopython_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+func TestIterSeq(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+	gogopythontest.RequireExec(t, interp, `
+def gen():
+    yield 1
+    yield 2
+    raise ValueError("broken")`)
+
+	gen := gogopythontest.Eval(t, interp, "gen()")
+	var items []int64
+	var exc *py.Exception
+	for item, err := range py.Iter(gen) {
+		if err != nil {
+			if !errors.As(err, &exc) || exc.Type != "ValueError" {
+				t.Errorf("iteration failed with %v, want the ValueError", err)
+			}
+			continue
+		}
+		items = append(items, py.PyLong_AsLong(item))
+	}
+	if len(items) != 2 || exc == nil {
+		t.Errorf("Iter yielded %v and %v", items, exc)
+	}
+
+	// Breaking out of the loop stops early.
+	list := gogopythontest.Eval(t, interp, "[1, 2, 3]")
+	n := 0
+	for range py.Iter(list) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("Iter continued after break, %d items", n)
+	}
+
+	for _, err := range py.Iter(py.Py_None) {
+		if !errors.As(err, &exc) || exc.Type != "TypeError" {
+			t.Errorf("iterating None yielded %v, want a TypeError", err)
+		}
+	}
+	gogopythontest.RequireNoException(t)
+}
+
+func TestValues(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	list := gogopythontest.Eval(t, interp, "['a', 'b', 3, 'c']")
+	var got []string
+	var failed error
+	for v, err := range py.Values[string](list) {
+		if err != nil {
+			failed = err
+			continue
+		}
+		got = append(got, v)
+	}
+	var conv *py.ConversionError
+	if len(got) != 2 || !errors.As(failed, &conv) || conv.PythonType != "int" {
+		t.Errorf("Values yielded %v and %v, want 2 strings and a conversion error", got, failed)
+	}
+}
+
+func TestChanIterator(t *testing.T) {
+	// The iterator type is created once per interpreter.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for _, s := range []string{"a", "b"} {
+			ch <- s
+		}
+	}()
+	it, err := py.NewChanIterator(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setGlobal(t, interp, "it", it)
+	gogopythontest.RequireEval(t, interp, "list(it)", []string{"a", "b"})
+	gogopythontest.RequireEval(t, interp, "next(it, None)", nil)
+}
+
+func TestIteratorStops(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+
+	stopped := make(chan struct{})
+	it, err := py.NewIterator(func(yield func(int) bool) {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setGlobal(t, interp, "it", it)
+	gogopythontest.RequireEval(t, interp, "[next(it), next(it)]", []int{0, 1})
+
+	// Collecting the Python iterator stops the sequence.
+	gogopythontest.RequireExec(t, interp, "del it")
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Error("the sequence wasn't stopped")
+	}
+}
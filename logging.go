@@ -0,0 +1,197 @@
+package gogopython
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// LogOptions configure how Python log records are forwarded to slog.
+type LogOptions struct {
+	// Logger is the name of the Python logger to attach to. Defaults to
+	// the root logger, capturing records from all loggers that propagate.
+	Logger string
+
+	// Level, if set, is applied to the Python logger so records below it
+	// aren't created at all. Use SetLogLevel to change it later.
+	Level slog.Leveler
+}
+
+// Python logging.Handler calling back into Go. Extra fields are reduced to
+// simple values so no Python objects escape the call.
+const logHandlerSrc = `
+import logging
+
+_standard = set(vars(logging.LogRecord("", 0, "", 0, "", None, None)))
+_standard.update(("message", "asctime", "taskName"))
+_formatter = logging.Formatter()
+
+class GoHandler(logging.Handler):
+    def __init__(self, emit):
+        super().__init__()
+        self._emit = emit
+
+    def emit(self, record):
+        try:
+            exc = ""
+            if record.exc_info and record.exc_info[0] is not None:
+                exc = _formatter.formatException(record.exc_info)
+            elif record.exc_text:
+                exc = record.exc_text
+            extra = {}
+            for k, v in record.__dict__.items():
+                if k in _standard or k.startswith("_"):
+                    continue
+                if v is None or isinstance(v, (bool, int, float, str)):
+                    extra[k] = v
+                else:
+                    extra[k] = repr(v)
+            self._emit(record.levelno, record.name, record.getMessage(),
+                       record.created, exc, extra)
+        except Exception:
+            self.handleError(record)
+`
+
+// InstallLogHandler adds a handler to a Python logger in the current
+// interpreter that forwards log records to h.
+//
+// Python levels are mapped onto slog levels so that DEBUG, INFO, WARNING
+// and ERROR match their slog equivalents, with CRITICAL above Error. Each
+// record has the logger name and interpreter id as attributes, along with
+// any exception traceback and extra fields from the record.
+//
+// The returned func removes the handler. Both require the caller to hold
+// the GIL.
+func InstallLogHandler(h slog.Handler, opts LogOptions) (remove func(), err error) {
+	id := PyInterpreterState_GetID(PyInterpreterState_Get())
+	h = h.WithAttrs([]slog.Attr{slog.Int64("interpreter", id)})
+
+	emit, err := ExportFunc("emit", func(level int, name, msg string, created float64, exc string, extra map[string]any) {
+		lvl := slogLevel(level)
+		ctx := context.Background()
+		if !h.Enabled(ctx, lvl) {
+			return
+		}
+		sec, frac := math.Modf(created)
+		r := slog.NewRecord(time.Unix(int64(sec), int64(frac*1e9)), lvl, msg, 0)
+		r.AddAttrs(slog.String("logger", name))
+		if exc != "" {
+			r.AddAttrs(slog.String("exception", exc))
+		}
+		for _, k := range slices.Sorted(maps.Keys(extra)) {
+			r.AddAttrs(slog.Any(k, extra[k]))
+		}
+		// There's nowhere to report a failure from the handler.
+		_ = h.Handle(ctx, r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer Py_DecRef(emit)
+
+	class, err := compileHelper(logHandlerSrc, "GoHandler")
+	if err != nil {
+		return nil, err
+	}
+	defer Py_DecRef(class)
+
+	handler := PyObject_CallOneArg(class, emit)
+	if handler == NullPyObjectPtr {
+		return nil, FetchError()
+	}
+
+	logger, err := getLogger(opts.Logger)
+	if err != nil {
+		Py_DecRef(handler)
+		return nil, err
+	}
+	if opts.Level != nil {
+		if err := setLoggerLevel(logger, opts.Level.Level()); err != nil {
+			Py_DecRef(logger)
+			Py_DecRef(handler)
+			return nil, err
+		}
+	}
+	res := callMethod(logger, "addHandler", handler)
+	if res == NullPyObjectPtr {
+		err := FetchError()
+		Py_DecRef(logger)
+		Py_DecRef(handler)
+		return nil, err
+	}
+	Py_DecRef(res)
+
+	return func() {
+		if res := callMethod(logger, "removeHandler", handler); res != NullPyObjectPtr {
+			Py_DecRef(res)
+		}
+		PyErr_Clear()
+		Py_DecRef(logger)
+		Py_DecRef(handler)
+	}, nil
+}
+
+// SetLogLevel sets the level of a Python logger in the current interpreter
+// from a slog level. An empty name refers to the root logger.
+//
+// Requires the caller to hold the GIL.
+func SetLogLevel(name string, level slog.Level) error {
+	logger, err := getLogger(name)
+	if err != nil {
+		return err
+	}
+	defer Py_DecRef(logger)
+	return setLoggerLevel(logger, level)
+}
+
+// getLogger returns a new reference to the named Python logger.
+func getLogger(name string) (PyObjectPtr, error) {
+	logging := PyImport_ImportModule("logging")
+	if logging == NullPyObjectPtr {
+		return NullPyObjectPtr, FetchError()
+	}
+	defer Py_DecRef(logging)
+
+	var logger PyObjectPtr
+	if name == "" {
+		logger = callMethod(logging, "getLogger")
+	} else {
+		s, err := ToPython(name)
+		if err != nil {
+			return NullPyObjectPtr, err
+		}
+		logger = callMethod(logging, "getLogger", s)
+		Py_DecRef(s)
+	}
+	if logger == NullPyObjectPtr {
+		return NullPyObjectPtr, FetchError()
+	}
+	return logger, nil
+}
+
+func setLoggerLevel(logger PyObjectPtr, level slog.Level) error {
+	lvl := PyLong_FromLong(int64(pythonLevel(level)))
+	defer Py_DecRef(lvl)
+	res := callMethod(logger, "setLevel", lvl)
+	if res == NullPyObjectPtr {
+		return FetchError()
+	}
+	Py_DecRef(res)
+	return nil
+}
+
+// slogLevel maps a Python logging level onto a slog level. Python levels are
+// 10 apart from DEBUG (10) to CRITICAL (50), while slog levels are 4 apart
+// from Debug (-4) to Error (8).
+func slogLevel(level int) slog.Level {
+	return slog.Level((level - 20) * 4 / 10)
+}
+
+// pythonLevel is the inverse of slogLevel. Python treats 0 as NOTSET, so
+// the result is at least 1.
+func pythonLevel(level slog.Level) int {
+	return max(20+int(level)*10/4, 1)
+}
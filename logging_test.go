@@ -0,0 +1,100 @@
+package gogopython_test
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// newTextHandler returns a slog handler writing records without their time.
+func newTextHandler(b *strings.Builder) slog.Handler {
+	return slog.NewTextHandler(b, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+}
+
+func TestInstallLogHandler(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{
+		Preload: []string{"logging"},
+		// Python keeps the loggers used by the test.
+		AllowLeaks: true,
+	})
+	id := py.PyInterpreterState_GetID(interp.State())
+
+	var out strings.Builder
+	remove, err := py.InstallLogHandler(newTextHandler(&out), py.LogOptions{Logger: "app", Level: slog.LevelInfo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireExec(t, interp, `
+import logging
+log = logging.getLogger("app")
+log.debug("hidden")
+log.warning("hello %s", "world", extra={"user": "bob", "ids": [1]})
+logging.getLogger("app.child").critical("critical")
+try:
+    1 / 0
+except ZeroDivisionError:
+    log.exception("failed")`)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		fmt.Sprintf(`level=WARN msg="hello world" interpreter=%d logger=app ids=[1] user=bob`, id),
+		fmt.Sprintf(`level=ERROR+4 msg=critical interpreter=%d logger=app.child`, id),
+		fmt.Sprintf(`level=ERROR msg=failed interpreter=%d logger=app exception="Traceback`, id),
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d records, want %d:\n%s", len(lines), len(want), out.String())
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i]) {
+			t.Errorf("record %d = %s, want %s", i, line, want[i])
+		}
+	}
+	if !strings.Contains(lines[2], "ZeroDivisionError") {
+		t.Errorf("record 2 has no traceback: %s", lines[2])
+	}
+
+	if err := py.SetLogLevel("app", slog.LevelDebug); err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireEval(t, interp, "log.level", 10)
+	out.Reset()
+	gogopythontest.RequireExec(t, interp, `log.debug("shown")`)
+	if !strings.Contains(out.String(), "msg=shown") {
+		t.Errorf("debug record not forwarded: %q", out.String())
+	}
+
+	remove()
+	gogopythontest.RequireEval(t, interp, "log.handlers", []any{})
+}
+
+func TestSetLogLevel(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{Preload: []string{"logging"}})
+	gogopythontest.RequireExec(t, interp, "import logging")
+
+	for level, want := range map[slog.Level]int{
+		slog.LevelDebug:       10,
+		slog.LevelInfo:        20,
+		slog.LevelWarn:        30,
+		slog.LevelError:       40,
+		slog.LevelError + 4:   50,
+		slog.LevelDebug - 100: 1,
+	} {
+		if err := py.SetLogLevel("", level); err != nil {
+			t.Fatal(err)
+		}
+		gogopythontest.RequireEval(t, interp, "logging.getLogger().level", want)
+	}
+	gogopythontest.RequireExec(t, interp, "logging.getLogger().setLevel(logging.WARNING)")
+}
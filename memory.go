@@ -0,0 +1,313 @@
+package gogopython
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ebitengine/purego"
+)
+
+// MemoryStats are the memory counters of one interpreter, as tracked once
+// TrackMemory is called. They cover the mem and obj domains, where Python
+// objects live, not raw allocations.
+type MemoryStats struct {
+	InUse  int64  // InUse is the number of bytes currently allocated.
+	Peak   int64  // Peak is the highest InUse seen.
+	Allocs uint64 // Allocs counts allocations, including reallocations.
+	Frees  uint64 // Frees counts released blocks.
+	Failed uint64 // Failed counts allocations refused for exceeding Limit.
+	Limit  int64  // Limit is the memory budget in bytes, or 0 for none.
+}
+
+// memBlock is a tracked allocation.
+type memBlock struct {
+	size   int64
+	interp int64
+}
+
+// memoryTracker holds the state of the allocator hooks.
+type memoryTracker struct {
+	sync.Mutex
+	installed bool
+	prev      [3]PyMemAllocatorEx // The wrapped allocators, by domain.
+	blocks    map[uintptr]memBlock
+	stats     map[int64]*MemoryStats
+}
+
+var memTracker = &memoryTracker{
+	blocks: make(map[uintptr]memBlock),
+	stats:  make(map[int64]*MemoryStats),
+}
+
+// TrackMemory wraps the allocators of the mem and obj domains with hooks
+// that account for the bytes allocated by each interpreter, whatever
+// PyPreConfig.Allocator chose. Calling it again has no effect.
+//
+// Call it after Py_PreInitialize and before Py_InitializeFromConfig, so no
+// other thread is allocating and all of the interpreter's memory is seen.
+// The hooks can't be removed.
+//
+// Allocations are attributed to the interpreter of the current thread state
+// and not tracked if there is none. The raw domain isn't hooked: it's used
+// without the GIL, by threads Go doesn't know about, where calling into Go
+// is expensive. So memory from PyMem_RawMalloc, or that extensions get from
+// malloc(3) directly, isn't seen. Every other allocation calls into Go,
+// including on threads started by Python, so expect Python to be noticeably
+// slower.
+func TrackMemory() {
+	memTracker.Lock()
+	defer memTracker.Unlock()
+	if memTracker.installed {
+		return
+	}
+	hooks := PyMemAllocatorEx{
+		Malloc:  purego.NewCallback(memMalloc),
+		Calloc:  purego.NewCallback(memCalloc),
+		Realloc: purego.NewCallback(memRealloc),
+		Free:    purego.NewCallback(memFree),
+	}
+	// The hooks find the allocator they wrap by the domain in their
+	// context.
+	for domain := PyMem_DomainMem; domain <= PyMem_DomainObj; domain++ {
+		PyMem_GetAllocator(domain, &memTracker.prev[domain])
+	}
+	for domain := PyMem_DomainMem; domain <= PyMem_DomainObj; domain++ {
+		hooks.Ctx = uintptr(domain)
+		PyMem_SetAllocator(domain, &hooks)
+	}
+	memTracker.installed = true
+}
+
+// SetMemoryLimit sets the memory budget of the current interpreter in bytes.
+// A limit of 0 or less removes it.
+//
+// Allocations that would exceed the budget fail, which Python raises as a
+// MemoryError. Leave some headroom: a limit below what the interpreter
+// already uses fails almost everything, including handling the MemoryError.
+//
+// The budget only bounds the mem and obj domains, see TrackMemory. Memory
+// from the raw domain, e.g. large buffers of some extension modules, and
+// from malloc(3) isn't counted, so it doesn't bound all the memory an
+// interpreter can use.
+//
+// Requires TrackMemory and the caller to hold the GIL.
+func SetMemoryLimit(limit int64) error {
+	id := PyInterpreterState_GetID(PyInterpreterState_Get())
+
+	memTracker.Lock()
+	defer memTracker.Unlock()
+	if !memTracker.installed {
+		return errors.New("memory isn't tracked, call TrackMemory first")
+	}
+	memTracker.statsFor(id).Limit = max(limit, 0)
+	return nil
+}
+
+// MemoryUsage returns the memory counters of the interpreter with the given
+// id, which are dropped when it ends. It can be called from any goroutine,
+// without the GIL.
+func MemoryUsage(interp int64) MemoryStats {
+	memTracker.Lock()
+	defer memTracker.Unlock()
+	if s, ok := memTracker.stats[interp]; ok {
+		return *s
+	}
+	return MemoryStats{}
+}
+
+// statsFor returns the counters of an interpreter, creating them if needed.
+// Requires t to be locked.
+func (t *memoryTracker) statsFor(interp int64) *MemoryStats {
+	s, ok := t.stats[interp]
+	if !ok {
+		s = &MemoryStats{}
+		t.stats[interp] = s
+	}
+	return s
+}
+
+// reserve reports whether an interpreter may allocate size bytes, counting a
+// refusal. Requires t to be locked.
+func (t *memoryTracker) reserve(interp int64, size int64) bool {
+	s := t.statsFor(interp)
+	if s.Limit > 0 && s.InUse+size > s.Limit {
+		s.Failed++
+		return false
+	}
+	return true
+}
+
+// add records a block, counting it as an allocation if alloc is set.
+// Requires t to be locked.
+func (t *memoryTracker) add(ptr uintptr, b memBlock, alloc bool) {
+	// A block allocated through a nested call, e.g. an extension's obj
+	// allocator using the mem domain, is already tracked and counted.
+	_, nested := t.remove(ptr)
+	t.blocks[ptr] = b
+	s := t.statsFor(b.interp)
+	s.InUse += b.size
+	s.Peak = max(s.Peak, s.InUse)
+	if alloc && !nested {
+		s.Allocs++
+	}
+}
+
+// remove forgets a block, returning it if it was tracked. Requires t to be
+// locked.
+func (t *memoryTracker) remove(ptr uintptr) (memBlock, bool) {
+	b, ok := t.blocks[ptr]
+	if ok {
+		delete(t.blocks, ptr)
+		if s, ok := t.stats[b.interp]; ok {
+			s.InUse -= b.size
+		}
+	}
+	return b, ok
+}
+
+// forget drops the counters and blocks of an ended interpreter. Blocks in
+// pymalloc arenas aren't freed one by one when the interpreter ends, so
+// they'd stay tracked otherwise.
+func (t *memoryTracker) forget(interp int64) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.stats, interp)
+	for ptr, b := range t.blocks {
+		if b.interp == interp {
+			delete(t.blocks, ptr)
+		}
+	}
+}
+
+// forgetInterpreters wraps the bindings ending interpreters to drop their
+// memory counters. It's called by LoadLibrary. This can't be done from
+// onInterpreterEnd, as the interpreter still allocates, and would be
+// counted again, while it's torn down after its dict is cleared.
+func forgetInterpreters() {
+	endInterpreter := Py_EndInterpreter
+	Py_EndInterpreter = func(ts PyThreadStatePtr) {
+		id := PyInterpreterState_GetID(PyThreadState_GetInterpreter(ts))
+		endInterpreter(ts)
+		memTracker.forget(id)
+	}
+	finalize := Py_FinalizeEx
+	Py_FinalizeEx = func() int32 {
+		// Finalizing ends all interpreters.
+		defer func() {
+			memTracker.Lock()
+			clear(memTracker.stats)
+			clear(memTracker.blocks)
+			memTracker.Unlock()
+		}()
+		return finalize()
+	}
+}
+
+// currentInterpreter returns the id of the current thread state's
+// interpreter, if there is a thread state. It must not allocate.
+func currentInterpreter() (int64, bool) {
+	ts := PyThreadState_GetUnchecked()
+	if ts == NullThreadState {
+		return 0, false
+	}
+	return PyInterpreterState_GetID(PyThreadState_GetInterpreter(ts)), true
+}
+
+// The allocator hooks, whose context is the domain of the allocator they
+// wrap. The lock is never held while calling the wrapped allocators, as they
+// may call back into the hooks of another domain.
+
+func memMalloc(ctx, size uintptr) uintptr {
+	interp, tracked, ok := memReserve(size)
+	if !ok {
+		return 0
+	}
+	prev := &memTracker.prev[ctx]
+	ptr, _, _ := purego.SyscallN(prev.Malloc, prev.Ctx, size)
+	if tracked {
+		memAllocated(ptr, size, interp)
+	}
+	return ptr
+}
+
+func memCalloc(ctx, nelem, elsize uintptr) uintptr {
+	interp, tracked, ok := memReserve(nelem * elsize)
+	if !ok {
+		return 0
+	}
+	prev := &memTracker.prev[ctx]
+	ptr, _, _ := purego.SyscallN(prev.Calloc, prev.Ctx, nelem, elsize)
+	if tracked {
+		memAllocated(ptr, nelem*elsize, interp)
+	}
+	return ptr
+}
+
+// memReserve returns the interpreter charged for an allocation of size
+// bytes, if any, and whether the allocation may proceed.
+func memReserve(size uintptr) (interp int64, tracked, ok bool) {
+	interp, tracked = currentInterpreter()
+	if !tracked {
+		return 0, false, true
+	}
+	memTracker.Lock()
+	defer memTracker.Unlock()
+	return interp, true, memTracker.reserve(interp, int64(size))
+}
+
+// memAllocated records a block allocated for an interpreter.
+func memAllocated(ptr, size uintptr, interp int64) {
+	if ptr == 0 {
+		return
+	}
+	memTracker.Lock()
+	memTracker.add(ptr, memBlock{size: int64(size), interp: interp}, true)
+	memTracker.Unlock()
+}
+
+func memRealloc(ctx, ptr, size uintptr) uintptr {
+	prev := &memTracker.prev[ctx]
+	interp, ok := currentInterpreter()
+
+	// Forget the old block before it's freed, so it isn't confused with a
+	// block reusing its address on another thread.
+	memTracker.Lock()
+	old, tracked := memTracker.remove(ptr)
+	if ok && !memTracker.reserve(interp, int64(size)) {
+		if tracked {
+			memTracker.add(ptr, old, false)
+		}
+		memTracker.Unlock()
+		return 0
+	}
+	memTracker.Unlock()
+
+	moved, _, _ := purego.SyscallN(prev.Realloc, prev.Ctx, ptr, size)
+
+	memTracker.Lock()
+	defer memTracker.Unlock()
+	switch {
+	case moved == 0:
+		if tracked {
+			memTracker.add(ptr, old, false)
+		}
+	case ok:
+		memTracker.add(moved, memBlock{size: int64(size), interp: interp}, true)
+	case tracked:
+		// Keep charging the interpreter that allocated the block.
+		memTracker.add(moved, memBlock{size: int64(size), interp: old.interp}, true)
+	}
+	return moved
+}
+
+func memFree(ctx, ptr uintptr) {
+	if ptr != 0 {
+		memTracker.Lock()
+		if b, ok := memTracker.remove(ptr); ok {
+			memTracker.statsFor(b.interp).Frees++
+		}
+		memTracker.Unlock()
+	}
+	prev := &memTracker.prev[ctx]
+	purego.SyscallN(prev.Free, prev.Ctx, ptr)
+}
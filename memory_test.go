@@ -0,0 +1,91 @@
+package gogopython_test
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// Tracking memory slows Python down, so tests needing it run again in a
+// child process, which TestMain starts with TrackMemory when this variable
+// is set.
+const trackMemoryEnv = "GOGOPYTHON_TEST_TRACK_MEMORY"
+
+// inTrackedProcess reports whether memory is tracked. If not, it runs the
+// test in a child process tracking memory and reports its result.
+func inTrackedProcess(t *testing.T) bool {
+	t.Helper()
+	if os.Getenv(trackMemoryEnv) != "" {
+		return true
+	}
+	gogopythontest.SkipWithoutPython(t)
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$", "-test.count=1")
+	cmd.Env = append(os.Environ(), trackMemoryEnv+"=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("tracked run failed: %v\n%s", err, out)
+	}
+	return false
+}
+
+func TestMemoryLimit(t *testing.T) {
+	if !inTrackedProcess(t) {
+		return
+	}
+	interp := gogopythontest.NewInterpreter(t)
+	id := py.PyInterpreterState_GetID(interp.State())
+
+	before := py.MemoryUsage(id)
+	if before.InUse <= 0 || before.Allocs == 0 {
+		t.Fatalf("MemoryUsage(%d) = %+v, want memory in use", id, before)
+	}
+	if err := py.SetMemoryLimit(before.InUse + 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireExec(t, interp, "small = bytearray(1000)")
+	gogopythontest.RequireRaises(t, interp, "big = bytearray(10 << 20)", "MemoryError")
+	if err := py.SetMemoryLimit(0); err != nil {
+		t.Fatal(err)
+	}
+
+	after := py.MemoryUsage(id)
+	if after.Failed == 0 || after.Limit != 0 || after.Peak < before.InUse {
+		t.Errorf("MemoryUsage(%d) = %+v after exceeding the limit", id, after)
+	}
+	gogopythontest.RequireExec(t, interp, "big = bytearray(10 << 20)")
+	if usage := py.MemoryUsage(id); usage.InUse < 10<<20 {
+		t.Errorf("MemoryUsage(%d).InUse = %d, want the 10 MiB buffer counted", id, usage.InUse)
+	}
+}
+
+func TestMemoryUsageOfEndedInterpreter(t *testing.T) {
+	if !inTrackedProcess(t) {
+		return
+	}
+	var id int64
+	t.Run("interpreter", func(t *testing.T) {
+		interp := gogopythontest.NewInterpreter(t)
+		id = py.PyInterpreterState_GetID(interp.State())
+		if py.MemoryUsage(id).InUse == 0 {
+			t.Error("no memory in use")
+		}
+	})
+	if usage := py.MemoryUsage(id); usage != (py.MemoryStats{}) {
+		t.Errorf("MemoryUsage(%d) = %+v after the interpreter ended, want none", id, usage)
+	}
+}
+
+func TestMemoryLimitUntracked(t *testing.T) {
+	if os.Getenv(trackMemoryEnv) != "" {
+		t.Skip("memory is tracked")
+	}
+	interp := gogopythontest.NewInterpreter(t)
+	if err := py.SetMemoryLimit(1 << 20); err == nil {
+		t.Error("SetMemoryLimit succeeded without TrackMemory")
+	}
+	if usage := py.MemoryUsage(py.PyInterpreterState_GetID(interp.State())); usage != (py.MemoryStats{}) {
+		t.Errorf("MemoryUsage = %+v without TrackMemory, want none", usage)
+	}
+}
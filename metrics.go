@@ -0,0 +1,247 @@
+package gogopython
+
+import (
+	"errors"
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives measurements of Python activity, to be backed by e.g.
+// Prometheus or expvar. Implementations must be safe for concurrent use and
+// must not call into Python.
+type Metrics interface {
+	// Count adds delta to the named counter.
+	Count(name string, delta int64)
+	// Gauge sets the named gauge.
+	Gauge(name string, value int64)
+	// Observe records a duration in the named histogram.
+	Observe(name string, d time.Duration)
+}
+
+// Names of the metrics reported to Metrics.
+const (
+	// MetricGILWait is a histogram of the time spent waiting to acquire the
+	// GIL in PyGILState_Ensure, PyEval_RestoreThread and
+	// PyEval_AcquireThread.
+	MetricGILWait = "gil_wait"
+
+	// MetricExec is a histogram of the time spent running Python code with
+	// PyRun_SimpleString, PyRun_String, PyEval_EvalCode, PyObject_Call and
+	// PyObject_CallObject. Nested calls, e.g. from a Go callback, are
+	// included in the time of their caller too.
+	MetricExec = "exec"
+
+	// MetricCallbacks counts the calls of Go funcs from Python, and
+	// MetricCallbacksInFlight is the number of them currently running.
+	MetricCallbacks         = "callbacks"
+	MetricCallbacksInFlight = "callbacks_in_flight"
+
+	// MetricToPython and MetricFromPython count the calls of ToPython and
+	// FromPython, and MetricConversionErrors those that failed.
+	MetricToPython         = "to_python"
+	MetricFromPython       = "from_python"
+	MetricConversionErrors = "conversion_errors"
+
+	// MetricInterpreters is the number of live interpreters, including the
+	// main interpreter.
+	MetricInterpreters = "interpreters"
+)
+
+var metrics struct {
+	sink         atomic.Pointer[Metrics]
+	inFlight     atomic.Int64
+	interpreters atomic.Int64
+}
+
+// SetMetrics sets where measurements are reported. A nil m stops reporting.
+// It can be called at any time after LoadLibrary, from any goroutine.
+func SetMetrics(m Metrics) error {
+	if PyEval_RestoreThread == nil {
+		return errors.New("python library isn't loaded")
+	}
+	if m == nil {
+		metrics.sink.Store(nil)
+		return nil
+	}
+	metrics.sink.Store(&m)
+	m.Gauge(MetricInterpreters, metrics.interpreters.Load())
+	m.Gauge(MetricCallbacksInFlight, metrics.inFlight.Load())
+	return nil
+}
+
+// currentMetrics returns the Metrics, or nil if there are none.
+func currentMetrics() Metrics {
+	if m := metrics.sink.Load(); m != nil {
+		return *m
+	}
+	return nil
+}
+
+// instrumentBindings wraps the measured bindings. It's called by LoadLibrary
+// once the bindings are registered, so they're never reassigned while in
+// use. The wrappers only measure while there are Metrics.
+func instrumentBindings() {
+	// Waiting for the GIL.
+	ensure := PyGILState_Ensure
+	PyGILState_Ensure = func() PyGILState {
+		if m := currentMetrics(); m != nil {
+			defer observeSince(m, MetricGILWait, time.Now())
+		}
+		return ensure()
+	}
+	restore := PyEval_RestoreThread
+	PyEval_RestoreThread = func(ts PyThreadStatePtr) {
+		if m := currentMetrics(); m != nil {
+			defer observeSince(m, MetricGILWait, time.Now())
+		}
+		restore(ts)
+	}
+	acquire := PyEval_AcquireThread
+	PyEval_AcquireThread = func(ts PyThreadStatePtr) {
+		if m := currentMetrics(); m != nil {
+			defer observeSince(m, MetricGILWait, time.Now())
+		}
+		acquire(ts)
+	}
+
+	// Running Python code.
+	simpleString := PyRun_SimpleString
+	PyRun_SimpleString = func(script string) int32 {
+		if m := currentMetrics(); m != nil {
+			defer observeSince(m, MetricExec, time.Now())
+		}
+		return simpleString(script)
+	}
+	runString := PyRun_String
+	PyRun_String = func(str string, start StartToken, globals, locals PyObjectPtr) PyObjectPtr {
+		if m := currentMetrics(); m != nil {
+			defer observeSince(m, MetricExec, time.Now())
+		}
+		return runString(str, start, globals, locals)
+	}
+	evalCode := PyEval_EvalCode
+	PyEval_EvalCode = func(co PyCodeObjectPtr, globals, locals PyObjectPtr) PyObjectPtr {
+		if m := currentMetrics(); m != nil {
+			defer observeSince(m, MetricExec, time.Now())
+		}
+		return evalCode(co, globals, locals)
+	}
+	call := PyObject_Call
+	PyObject_Call = func(callable, args, kwargs PyObjectPtr) PyObjectPtr {
+		if m := currentMetrics(); m != nil {
+			defer observeSince(m, MetricExec, time.Now())
+		}
+		return call(callable, args, kwargs)
+	}
+	callObject := PyObject_CallObject
+	PyObject_CallObject = func(callable, args PyObjectPtr) PyObjectPtr {
+		if m := currentMetrics(); m != nil {
+			defer observeSince(m, MetricExec, time.Now())
+		}
+		return callObject(callable, args)
+	}
+
+	// Live interpreters, counted even without Metrics so the gauge is right
+	// when they're set. The main interpreter may already be running.
+	if Py_IsInitialized() == 1 {
+		metrics.interpreters.Store(1)
+	}
+	initialize := Py_InitializeFromConfig
+	Py_InitializeFromConfig = func(config *PyConfig_3_12) PyStatus {
+		status := initialize(config)
+		if status.Type == 0 {
+			gaugeInterpreters(metrics.interpreters.Add(1))
+		}
+		return status
+	}
+	finalize := Py_FinalizeEx
+	Py_FinalizeEx = func() int32 {
+		// Finalizing ends the sub-interpreters too.
+		defer func() {
+			metrics.interpreters.Store(0)
+			gaugeInterpreters(0)
+		}()
+		return finalize()
+	}
+	newInterpreter := Py_NewInterpreterFromConfig
+	Py_NewInterpreterFromConfig = func(state *PyThreadStatePtr, c *PyInterpreterConfig) PyStatus {
+		status := newInterpreter(state, c)
+		if status.Type == 0 {
+			gaugeInterpreters(metrics.interpreters.Add(1))
+		}
+		return status
+	}
+	endInterpreter := Py_EndInterpreter
+	Py_EndInterpreter = func(ts PyThreadStatePtr) {
+		defer func() { gaugeInterpreters(metrics.interpreters.Add(-1)) }()
+		endInterpreter(ts)
+	}
+}
+
+func observeSince(m Metrics, name string, start time.Time) {
+	m.Observe(name, time.Since(start))
+}
+
+// gaugeInterpreters reports n live interpreters.
+func gaugeInterpreters(n int64) {
+	if m := currentMetrics(); m != nil {
+		m.Gauge(MetricInterpreters, n)
+	}
+}
+
+// trackCallback records the start of a Go callback, returning a func to call
+// when it returns.
+func trackCallback() func() {
+	m := currentMetrics()
+	if m == nil {
+		return func() {}
+	}
+	m.Count(MetricCallbacks, 1)
+	m.Gauge(MetricCallbacksInFlight, metrics.inFlight.Add(1))
+	return func() {
+		m.Gauge(MetricCallbacksInFlight, metrics.inFlight.Add(-1))
+	}
+}
+
+// countConversion records a call of ToPython or FromPython.
+func countConversion(name string, err error) {
+	if m := currentMetrics(); m != nil {
+		m.Count(name, 1)
+		if err != nil {
+			m.Count(MetricConversionErrors, 1)
+		}
+	}
+}
+
+// ExpvarMetrics is a Metrics publishing to an expvar.Map. Counters and
+// gauges are published under their names, histograms as the count and the
+// total seconds of the observations, under the name with "_count" and
+// "_seconds" appended.
+type ExpvarMetrics struct {
+	m *expvar.Map
+}
+
+// NewExpvarMetrics publishes the metrics as an expvar.Map with the given
+// name, e.g. "python". Like expvar.Publish, it panics if the name is in use.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	return &ExpvarMetrics{m: expvar.NewMap(name)}
+}
+
+func (e *ExpvarMetrics) Count(name string, delta int64) {
+	e.m.Add(name, delta)
+}
+
+func (e *ExpvarMetrics) Gauge(name string, value int64) {
+	v, ok := e.m.Get(name).(*expvar.Int)
+	if !ok {
+		v = new(expvar.Int)
+		e.m.Set(name, v)
+	}
+	v.Set(value)
+}
+
+func (e *ExpvarMetrics) Observe(name string, d time.Duration) {
+	e.m.Add(name+"_count", 1)
+	e.m.AddFloat(name+"_seconds", d.Seconds())
+}
@@ -0,0 +1,175 @@
+package gogopython_test
+
+import (
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// recordingMetrics is a Metrics keeping what's reported.
+type recordingMetrics struct {
+	mu       sync.Mutex
+	counts   map[string]int64
+	gauges   map[string]int64
+	observed map[string]int
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{
+		counts:   make(map[string]int64),
+		gauges:   make(map[string]int64),
+		observed: make(map[string]int),
+	}
+}
+
+func (m *recordingMetrics) Count(name string, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[name] += delta
+}
+
+func (m *recordingMetrics) Gauge(name string, value int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[name] = value
+}
+
+func (m *recordingMetrics) Observe(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observed[name]++
+}
+
+func (m *recordingMetrics) count(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[name]
+}
+
+func (m *recordingMetrics) gauge(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gauges[name]
+}
+
+func (m *recordingMetrics) observations(name string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.observed[name]
+}
+
+func TestSetMetrics(t *testing.T) {
+	gogopythontest.SkipWithoutPython(t)
+	m := newRecordingMetrics()
+	if err := py.SetMetrics(m); err != nil {
+		t.Fatal(err)
+	}
+	// The gauges are reported when setting the Metrics.
+	live := m.gauge(py.MetricInterpreters)
+	if live < 1 {
+		t.Errorf("%d live interpreters reported", live)
+	}
+	// Cleanups run last to first, so this runs after ending the
+	// interpreter.
+	t.Cleanup(func() {
+		if got := m.gauge(py.MetricInterpreters); got != live {
+			t.Errorf("%d live interpreters after ending one, want %d", got, live)
+		}
+		if err := py.SetMetrics(nil); err != nil {
+			t.Error(err)
+		}
+	})
+	interp := gogopythontest.NewInterpreter(t)
+	if got := m.gauge(py.MetricInterpreters); got != live+1 {
+		t.Errorf("%d live interpreters after creating one, want %d", got, live+1)
+	}
+
+	var inFlight int64
+	setGlobal(t, interp, "f", py.NewFunction("f", py.NullPyObjectPtr, func(_, _ py.PyObjectPtr) py.PyObjectPtr {
+		inFlight = m.gauge(py.MetricCallbacksInFlight)
+		return py.PyLong_FromLong(1)
+	}))
+	execs := m.observations(py.MetricExec)
+	gogopythontest.RequireExec(t, interp, "f()\nf()")
+	if got := m.count(py.MetricCallbacks); got != 2 {
+		t.Errorf("%d callbacks counted, want 2", got)
+	}
+	if got := m.gauge(py.MetricCallbacksInFlight); inFlight != 1 || got != 0 {
+		t.Errorf("%d callbacks in flight during the call and %d after, want 1 and 0", inFlight, got)
+	}
+	if got := m.observations(py.MetricExec); got != execs+1 {
+		t.Errorf("%d runs observed, want %d", got, execs+1)
+	}
+
+	waits := m.observations(py.MetricGILWait)
+	ts := py.PyEval_SaveThread()
+	py.PyEval_RestoreThread(ts)
+	if got := m.observations(py.MetricGILWait); got != waits+1 {
+		t.Errorf("%d GIL waits observed, want %d", got, waits+1)
+	}
+
+	// Other conversions are counted too, e.g. by the leak check.
+	toPython, fromPython := m.count(py.MetricToPython), m.count(py.MetricFromPython)
+	obj, err := py.ToPython(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer py.Py_DecRef(obj)
+	var s string
+	if err := py.FromPython(obj, &s); err == nil {
+		t.Error("converted an int to a string")
+	}
+	if got := m.count(py.MetricToPython); got != toPython+1 {
+		t.Errorf("%d conversions to Python counted, want %d", got, toPython+1)
+	}
+	if got := m.count(py.MetricFromPython); got != fromPython+1 {
+		t.Errorf("%d conversions from Python counted, want %d", got, fromPython+1)
+	}
+	if got := m.count(py.MetricConversionErrors); got != 1 {
+		t.Errorf("%d conversion errors counted, want 1", got)
+	}
+
+	// Nothing is reported without Metrics.
+	if err := py.SetMetrics(nil); err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireExec(t, interp, "f()")
+	if got := m.count(py.MetricCallbacks); got != 2 {
+		t.Errorf("%d callbacks counted after removing the Metrics", got)
+	}
+	// The live interpreters are counted regardless.
+	if err := py.SetMetrics(m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	m := py.NewExpvarMetrics("gogopython_test")
+	m.Count(py.MetricCallbacks, 2)
+	m.Count(py.MetricCallbacks, 1)
+	m.Gauge(py.MetricInterpreters, 3)
+	m.Gauge(py.MetricInterpreters, 2)
+	m.Observe(py.MetricExec, 1500*time.Millisecond)
+	m.Observe(py.MetricExec, 500*time.Millisecond)
+
+	vars := expvar.Get("gogopython_test").(*expvar.Map)
+	for name, want := range map[string]string{
+		py.MetricCallbacks:          "3",
+		py.MetricInterpreters:       "2",
+		py.MetricExec + "_count":    "2",
+		py.MetricExec + "_seconds":  "2",
+		py.MetricGILWait + "_count": "",
+	} {
+		var got string
+		if v := vars.Get(name); v != nil {
+			got = v.String()
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package gogopython
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/ebitengine/purego"
+)
+
+// ModuleBuilder defines a native Python module implemented in Go.
+//
+// A builder only describes the module. Python objects for its functions and
+// constants are created separately in each interpreter that imports it, so a
+// single module definition can be safely used by sub-interpreters, including
+// those with their own GIL.
+//
+// Errors from the builder methods are collected and reported by Build.
+type ModuleBuilder struct {
+	name    string
+	doc     string
+	ctx     context.Context
+	funcs   []moduleFunc
+	consts  []moduleConst
+	classes []*Class
+	subs    []*ModuleBuilder
+	errs    []error
+}
+
+type moduleFunc struct {
+	name string
+	fn   func(self, args, kwargs PyObjectPtr) PyObjectPtr
+	e    *export // Nil for raw funcs.
+}
+
+type moduleConst struct {
+	name  string
+	value any
+}
+
+// NewModuleBuilder starts the definition of a module with the given name.
+func NewModuleBuilder(name string) *ModuleBuilder {
+	b := &ModuleBuilder{name: name}
+	if name == "" || strings.Contains(name, ".") {
+		b.errs = append(b.errs, fmt.Errorf("invalid module name '%s'", name))
+	}
+	return b
+}
+
+// Doc sets the module docstring.
+func (b *ModuleBuilder) Doc(doc string) *ModuleBuilder {
+	b.doc = doc
+	return b
+}
+
+// Context sets the context passed to the module's functions taking a
+// context.Context, instead of context.Background().
+func (b *ModuleBuilder) Context(ctx context.Context) *ModuleBuilder {
+	b.ctx = ctx
+	return b
+}
+
+// Func adds a function to the module, converting arguments and results as
+// described by ExportFunc.
+func (b *ModuleBuilder) Func(name string, fn any) *ModuleBuilder {
+	e, err := newExport(name, fn)
+	if err != nil {
+		b.errs = append(b.errs, fmt.Errorf("module %s: %w", b.name, err))
+		return b
+	}
+	b.funcs = append(b.funcs, moduleFunc{name: name, fn: e.call, e: e})
+	return b
+}
+
+// RawFunc adds a function to the module that receives the raw Python args
+// tuple and kwargs dict, like NewFunctionWithKeywords.
+func (b *ModuleBuilder) RawFunc(name string, fn func(self, args, kwargs PyObjectPtr) PyObjectPtr) *ModuleBuilder {
+	b.funcs = append(b.funcs, moduleFunc{name: name, fn: fn})
+	return b
+}
+
+// Const adds a constant to the module. The value is converted using ToPython
+// in each interpreter, so it must be a plain Go value and not a PyObjectPtr.
+func (b *ModuleBuilder) Const(name string, value any) *ModuleBuilder {
+	if value != nil && !convertible(reflect.TypeOf(value)) {
+		b.errs = append(b.errs, fmt.Errorf("module %s: unsupported constant type %T", b.name, value))
+		return b
+	}
+	if _, ok := value.(PyObjectPtr); ok {
+		b.errs = append(b.errs, fmt.Errorf("module %s: constant %s cannot be a PyObjectPtr", b.name, name))
+		return b
+	}
+	b.consts = append(b.consts, moduleConst{name: name, value: value})
+	return b
+}
+
+// Class adds a Go-backed class to the module.
+func (b *ModuleBuilder) Class(c *Class) *ModuleBuilder {
+	b.classes = append(b.classes, c)
+	return b
+}
+
+// Submodule adds a child module, importable as "parent.child".
+func (b *ModuleBuilder) Submodule(sub *ModuleBuilder) *ModuleBuilder {
+	b.subs = append(b.subs, sub)
+	return b
+}
+
+// Build validates the module definition and creates the PyModuleDef used to
+// register it with Python.
+func (b *ModuleBuilder) Build() (*NativeModule, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	b.setContext()
+
+	m := &NativeModule{builder: b}
+	m.name = append([]byte(b.name), 0)
+	m.doc = append([]byte(b.doc), 0)
+	m.slots = [...]PyModuleDefSlot{
+		{Slot: ModuleSlotExec, Value: moduleExecTrampoline()},
+		{Slot: ModuleSlotMultipleInterpreters, Value: ModulePerInterpreterGilSupported},
+		{},
+	}
+	m.def = PyModuleDef{
+		Base:  PyModuleDefHeadInit,
+		Name:  &m.name[0],
+		Doc:   &m.doc[0],
+		Slots: &m.slots[0],
+	}
+	m.pinner.Pin(&m.def)
+	m.pinner.Pin(&m.slots[0])
+	m.pinner.Pin(&m.name[0])
+	m.pinner.Pin(&m.doc[0])
+
+	modules.Lock()
+	modules.defs[&m.def] = m
+	modules.Unlock()
+
+	return m, nil
+}
+
+// setContext passes the builder's context to its functions and those of its
+// submodules without a context of their own.
+func (b *ModuleBuilder) setContext() {
+	for _, f := range b.funcs {
+		if f.e != nil {
+			f.e.ctx = b.ctx
+		}
+	}
+	for _, sub := range b.subs {
+		if sub.ctx == nil {
+			sub.ctx = b.ctx
+		}
+		sub.setContext()
+	}
+}
+
+func (b *ModuleBuilder) validate() error {
+	errs := b.errs
+	for _, sub := range b.subs {
+		if err := sub.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NativeModule is a built module definition.
+//
+// Modules are never freed as Python may reference their definition at any
+// point in the life of the process.
+type NativeModule struct {
+	builder *ModuleBuilder
+
+	def    PyModuleDef
+	slots  [3]PyModuleDefSlot
+	name   []byte
+	doc    []byte
+	pinner runtime.Pinner
+
+	appendOnce sync.Once
+	initFunc   uintptr
+}
+
+// Name returns the name of the module.
+func (m *NativeModule) Name() string {
+	return m.builder.name
+}
+
+// Definition returns the underlying PyModuleDef.
+func (m *NativeModule) Definition() *PyModuleDef {
+	return &m.def
+}
+
+// AppendInittab registers the module as a built-in module, importable from
+// any interpreter.
+//
+// This must be called after LoadLibrary and before Python is initialized.
+func (m *NativeModule) AppendInittab() error {
+	err := errors.New("module already registered")
+	m.appendOnce.Do(func() {
+		// The init function takes no arguments, so each module needs its
+		// own callback.
+		m.initFunc = purego.NewCallback(func() PyObjectPtr {
+			return PyModuleDef_Init(&m.def)
+		})
+		if PyImport_AppendInittab(&m.name[0], m.initFunc) != 0 {
+			err = errors.New("failed to extend the inittab")
+			return
+		}
+		err = nil
+	})
+	return err
+}
+
+// Install creates the module in the current interpreter and adds it to
+// sys.modules, making it importable without registering it in the inittab.
+//
+// Returns a borrowed reference to the module. Requires the caller to hold
+// the GIL.
+func (m *NativeModule) Install() (PyObjectPtr, error) {
+	module := PyModule_New(m.builder.name)
+	if module == NullPyObjectPtr {
+		PyErr_Clear()
+		return NullPyObjectPtr, fmt.Errorf("failed to create module %s", m.builder.name)
+	}
+	defer Py_DecRef(module)
+
+	if m.builder.doc != "" {
+		doc, err := ToPython(m.builder.doc)
+		if err != nil {
+			return NullPyObjectPtr, err
+		}
+		PyObject_SetAttrString(module, "__doc__", doc)
+		Py_DecRef(doc)
+	}
+	if err := m.builder.populate(module, m.builder.name); err != nil {
+		return NullPyObjectPtr, err
+	}
+	if PyDict_SetItemString(PyImport_GetModuleDict(), m.builder.name, module) != 0 {
+		PyErr_Clear()
+		return NullPyObjectPtr, fmt.Errorf("failed to add %s to sys.modules", m.builder.name)
+	}
+	return module, nil
+}
+
+// populate adds the functions, constants and submodules to a module object
+// in the current interpreter.
+func (b *ModuleBuilder) populate(module PyObjectPtr, fullname string) error {
+	for _, f := range b.funcs {
+		fn := NewFunctionWithKeywords(f.name, NullPyObjectPtr, f.fn)
+		if fn == NullPyObjectPtr {
+			PyErr_Clear()
+			return fmt.Errorf("failed to create function %s.%s", fullname, f.name)
+		}
+		rc := PyModule_AddObjectRef(module, f.name, fn)
+		Py_DecRef(fn)
+		if rc != 0 {
+			PyErr_Clear()
+			return fmt.Errorf("failed to add function %s.%s", fullname, f.name)
+		}
+	}
+
+	for _, c := range b.consts {
+		val, err := ToPython(c.value)
+		if err != nil {
+			return fmt.Errorf("constant %s.%s: %w", fullname, c.name, err)
+		}
+		rc := PyModule_AddObjectRef(module, c.name, val)
+		Py_DecRef(val)
+		if rc != 0 {
+			PyErr_Clear()
+			return fmt.Errorf("failed to add constant %s.%s", fullname, c.name)
+		}
+	}
+
+	for _, c := range b.classes {
+		tp, err := c.typeObject(module)
+		if err != nil {
+			return err
+		}
+		if PyModule_AddObjectRef(module, c.Name(), tp) != 0 {
+			PyErr_Clear()
+			return fmt.Errorf("failed to add class %s.%s", fullname, c.Name())
+		}
+	}
+
+	for _, sub := range b.subs {
+		name := fullname + "." + sub.name
+		child := PyModule_New(name)
+		if child == NullPyObjectPtr {
+			PyErr_Clear()
+			return fmt.Errorf("failed to create module %s", name)
+		}
+		err := sub.populate(child, name)
+		if err == nil {
+			// Submodules need to be in sys.modules for "import a.b" to work.
+			if PyDict_SetItemString(PyImport_GetModuleDict(), name, child) != 0 ||
+				PyModule_AddObjectRef(module, sub.name, child) != 0 {
+				PyErr_Clear()
+				err = fmt.Errorf("failed to add submodule %s", name)
+			}
+		}
+		Py_DecRef(child)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Registry of built modules keyed by their definition, used to find the Go
+// module when Python calls the exec slot.
+var modules = struct {
+	sync.Mutex
+	defs map[*PyModuleDef]*NativeModule
+}{defs: make(map[*PyModuleDef]*NativeModule)}
+
+var moduleExec struct {
+	once sync.Once
+	fn   uintptr
+}
+
+// moduleExecTrampoline returns the Py_mod_exec function shared by all Go
+// modules.
+func moduleExecTrampoline() uintptr {
+	moduleExec.once.Do(func() {
+		moduleExec.fn = purego.NewCallback(execModule)
+	})
+	return moduleExec.fn
+}
+
+func execModule(module PyObjectPtr) (result int32) {
+	defer func() {
+		if r := recover(); r != nil {
+			PyErr_SetString(PyExc_RuntimeError, fmt.Sprintf("module init panicked: %v", r))
+			result = -1
+		}
+	}()
+
+	def := PyModule_GetDef(module)
+	modules.Lock()
+	m, ok := modules.defs[def]
+	modules.Unlock()
+	if !ok {
+		PyErr_SetString(PyExc_RuntimeError, "unknown Go module definition")
+		return -1
+	}
+
+	if err := m.builder.populate(module, m.builder.name); err != nil {
+		PyErr_SetString(PyExc_ImportError, err.Error())
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,84 @@
+package gogopython_test
+
+import (
+	"context"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+func TestModuleBuilder(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "module")
+	mod, err := py.NewModuleBuilder("_moduletest").
+		Doc("A module for tests.").
+		Context(ctx).
+		Func("add", func(a, b int) int { return a + b }).
+		Func("origin", func(ctx context.Context) string { return ctx.Value(key{}).(string) }).
+		RawFunc("nargs", func(_, args, _ py.PyObjectPtr) py.PyObjectPtr {
+			return py.PyLong_FromLong(py.PyTuple_Size(args))
+		}).
+		Const("VERSION", "1.0").
+		Const("LIMITS", []int{1, 2}).
+		Submodule(py.NewModuleBuilder("sub").
+			Func("origin", func(ctx context.Context) string { return ctx.Value(key{}).(string) }).
+			Const("DEPTH", 1)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mod.Name() != "_moduletest" || mod.Definition() == nil {
+		t.Errorf("Name() = %q, Definition() = %v", mod.Name(), mod.Definition())
+	}
+	if _, err := mod.Install(); err != nil {
+		t.Fatal(err)
+	}
+
+	gogopythontest.RequireExec(t, interp, "import _moduletest as m\nimport _moduletest.sub")
+	gogopythontest.RequireEval(t, interp, "m.__doc__", "A module for tests.")
+	gogopythontest.RequireEval(t, interp, "m.add(1, 2)", 3)
+	gogopythontest.RequireEval(t, interp, "m.origin()", "module")
+	gogopythontest.RequireEval(t, interp, "m.nargs(1, 2, 3)", 3)
+	gogopythontest.RequireEval(t, interp, "m.VERSION", "1.0")
+	gogopythontest.RequireEval(t, interp, "m.LIMITS", []int{1, 2})
+	gogopythontest.RequireEval(t, interp, "_moduletest.sub.DEPTH", 1)
+	gogopythontest.RequireEval(t, interp, "m.sub.origin()", "module")
+}
+
+func TestModuleBuilderErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		builder *py.ModuleBuilder
+	}{
+		{"empty name", py.NewModuleBuilder("")},
+		{"dotted name", py.NewModuleBuilder("a.b")},
+		{"unsupported func", py.NewModuleBuilder("m").Func("f", func(chan int) {})},
+		{"unsupported constant", py.NewModuleBuilder("m").Const("C", make(chan int))},
+		{"object constant", py.NewModuleBuilder("m").Const("C", py.NullPyObjectPtr)},
+		{"invalid submodule", py.NewModuleBuilder("m").Submodule(py.NewModuleBuilder("a.b"))},
+	} {
+		if _, err := tt.builder.Build(); err == nil {
+			t.Errorf("%s: Build succeeded", tt.name)
+		}
+	}
+}
+
+func TestModuleInittab(t *testing.T) {
+	gogopythontest.SkipWithoutPython(t)
+	if err := inittabModule.AppendInittab(); err == nil {
+		t.Error("a module was registered twice")
+	}
+	// Each interpreter gets its own module object.
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			interp := gogopythontest.NewInterpreter(t)
+			gogopythontest.RequireExec(t, interp, "import _bindingtest")
+			gogopythontest.RequireEval(t, interp, "_bindingtest.answer", 42)
+			gogopythontest.RequireEval(t, interp, "hasattr(_bindingtest, 'seen')", false)
+			gogopythontest.RequireExec(t, interp, "_bindingtest.seen = True")
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package gogopython
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"strings"
+	"time"
+)
+
+// pprofFrame is a stack frame in a pprof sample.
+type pprofFrame struct {
+	name      string
+	filename  string
+	startLine int64 // startLine is the first line of the function.
+	line      int64
+}
+
+// pprofLabel is a label on a pprof sample.
+type pprofLabel struct {
+	key   string
+	value string
+}
+
+type pprofSample struct {
+	locations []uint64
+	labels    []pprofLabel
+	values    []int64
+}
+
+type pprofLocation struct {
+	function uint64
+	line     int64
+}
+
+// pprofBuilder aggregates samples into a profile in pprof's protobuf format,
+// see https://github.com/google/pprof/blob/main/proto/profile.proto.
+type pprofBuilder struct {
+	strings   []string
+	stringIDs map[string]int64
+	functions map[pprofFrame]uint64 // Keyed by frame, without the line.
+	locations map[pprofLocation]uint64
+	samples   map[string]*pprofSample
+	order     []*pprofSample
+}
+
+func newPprofBuilder() *pprofBuilder {
+	return &pprofBuilder{
+		strings:   []string{""},
+		stringIDs: map[string]int64{"": 0},
+		functions: make(map[pprofFrame]uint64),
+		locations: make(map[pprofLocation]uint64),
+		samples:   make(map[string]*pprofSample),
+	}
+}
+
+// add adds values to the sample for a stack, leaf first, and labels.
+func (b *pprofBuilder) add(stack []pprofFrame, labels []pprofLabel, values ...int64) {
+	locations := make([]uint64, len(stack))
+	for i, frame := range stack {
+		line := frame.line
+		frame.line = 0
+		fn, ok := b.functions[frame]
+		if !ok {
+			fn = uint64(len(b.functions) + 1)
+			b.functions[frame] = fn
+		}
+		loc := pprofLocation{function: fn, line: line}
+		id, ok := b.locations[loc]
+		if !ok {
+			id = uint64(len(b.locations) + 1)
+			b.locations[loc] = id
+		}
+		locations[i] = id
+	}
+
+	var key strings.Builder
+	for _, id := range locations {
+		key.WriteString(string(binary.AppendUvarint(nil, id)))
+	}
+	for _, label := range labels {
+		key.WriteString("\x00" + label.key + "=" + label.value)
+	}
+	s, ok := b.samples[key.String()]
+	if !ok {
+		s = &pprofSample{locations: locations, labels: labels, values: make([]int64, len(values))}
+		b.samples[key.String()] = s
+		b.order = append(b.order, s)
+	}
+	for i, v := range values {
+		s.values[i] += v
+	}
+}
+
+// str returns the string table index of s.
+func (b *pprofBuilder) str(s string) int64 {
+	id, ok := b.stringIDs[s]
+	if !ok {
+		id = int64(len(b.strings))
+		b.strings = append(b.strings, s)
+		b.stringIDs[s] = id
+	}
+	return id
+}
+
+// write writes the gzipped profile. The sample types are [type, unit] pairs
+// matching the values passed to add, the period type is the unit of period.
+func (b *pprofBuilder) write(w io.Writer, sampleTypes [][2]string, periodType [2]string, period int64, start time.Time, duration time.Duration) error {
+	var p protobuf
+	for _, st := range sampleTypes {
+		p.message(1, b.valueType(st))
+	}
+	for _, s := range b.order {
+		var m protobuf
+		m.packed(1, s.locations)
+		values := make([]uint64, len(s.values))
+		for i, v := range s.values {
+			values[i] = uint64(v)
+		}
+		m.packed(2, values)
+		for _, label := range s.labels {
+			var l protobuf
+			l.varint(1, uint64(b.str(label.key)))
+			l.varint(2, uint64(b.str(label.value)))
+			m.message(3, l)
+		}
+		p.message(2, m)
+	}
+	for loc, id := range b.locations {
+		var m, line protobuf
+		m.varint(1, id)
+		line.varint(1, loc.function)
+		line.varint(2, uint64(loc.line))
+		m.message(4, line)
+		p.message(4, m)
+	}
+	for fn, id := range b.functions {
+		var m protobuf
+		m.varint(1, id)
+		m.varint(2, uint64(b.str(fn.name)))
+		m.varint(3, uint64(b.str(fn.name)))
+		m.varint(4, uint64(b.str(fn.filename)))
+		m.varint(5, uint64(fn.startLine))
+		p.message(5, m)
+	}
+	p.varint(9, uint64(start.UnixNano()))
+	p.varint(10, uint64(duration.Nanoseconds()))
+	p.message(11, b.valueType(periodType))
+	p.varint(12, uint64(period))
+	// The string table goes last, after everything has been interned.
+	for _, s := range b.strings {
+		p.bytes(6, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(p.buf); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (b *pprofBuilder) valueType(vt [2]string) protobuf {
+	var m protobuf
+	m.varint(1, uint64(b.str(vt[0])))
+	m.varint(2, uint64(b.str(vt[1])))
+	return m
+}
+
+// protobuf is a minimal protocol buffer encoder.
+type protobuf struct {
+	buf []byte
+}
+
+func (p *protobuf) key(field, wireType uint64) {
+	p.buf = binary.AppendUvarint(p.buf, field<<3|wireType)
+}
+
+func (p *protobuf) varint(field, v uint64) {
+	p.key(field, 0)
+	p.buf = binary.AppendUvarint(p.buf, v)
+}
+
+func (p *protobuf) bytes(field uint64, b []byte) {
+	p.key(field, 2)
+	p.buf = binary.AppendUvarint(p.buf, uint64(len(b)))
+	p.buf = append(p.buf, b...)
+}
+
+func (p *protobuf) message(field uint64, m protobuf) {
+	p.bytes(field, m.buf)
+}
+
+func (p *protobuf) packed(field uint64, vs []uint64) {
+	var b []byte
+	for _, v := range vs {
+		b = binary.AppendUvarint(b, v)
+	}
+	p.bytes(field, b)
+}
@@ -0,0 +1,142 @@
+package gogopython
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Profiler is a sampling profiler for Python code. It periodically captures
+// the Python stack of every thread of the profiled interpreters and writes
+// them as a pprof profile, so Python hot spots can be viewed with
+// go tool pprof, alongside Go profiles.
+//
+// Every thread is sampled, including those waiting on I/O or for the GIL, so
+// the profile shows wall time rather than CPU time. Samples are labeled with
+// the "interpreter" and "thread" ids.
+type Profiler struct {
+	interval time.Duration
+	interps  []PyInterpreterStatePtr
+	start    time.Time
+	stop     chan struct{}
+	done     chan struct{}
+
+	mu       sync.Mutex
+	builder  *pprofBuilder
+	duration time.Duration
+}
+
+// StartProfiler starts sampling the given interpreters every interval, or
+// every 10ms if interval isn't positive.
+//
+// Each sample briefly takes the interpreter's GIL from a separate OS thread,
+// so samples are biased towards the points where Python switches threads.
+// The interpreters must not be ended before the profiler is stopped.
+func StartProfiler(interval time.Duration, interps ...PyInterpreterStatePtr) *Profiler {
+	if interval <= 0 {
+		interval = 10 * time.Millisecond
+	}
+	p := &Profiler{
+		interval: interval,
+		interps:  interps,
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		builder:  newPprofBuilder(),
+	}
+	go p.run()
+	return p
+}
+
+// Stop stops sampling. It waits for a sample in progress, so the caller must
+// not hold the GIL of a profiled interpreter.
+func (p *Profiler) Stop() {
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	<-p.done
+}
+
+// WriteProfile writes the samples taken so far as a gzipped pprof profile.
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.builder.order) == 0 {
+		return errors.New("no samples were taken")
+	}
+	duration := p.duration
+	if duration == 0 {
+		duration = time.Since(p.start)
+	}
+	return p.builder.write(w,
+		[][2]string{{"samples", "count"}, {"wall", "nanoseconds"}},
+		[2]string{"wall", "nanoseconds"}, p.interval.Nanoseconds(),
+		p.start, duration)
+}
+
+func (p *Profiler) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			p.mu.Lock()
+			p.duration = time.Since(p.start)
+			p.mu.Unlock()
+			return
+		case <-ticker.C:
+			for _, interp := range p.interps {
+				callInInterpreter(interp, func() { p.sample(interp) })
+			}
+		}
+	}
+}
+
+// sample records the stacks of the interpreter's threads. Requires the
+// interpreter's GIL.
+func (p *Profiler) sample(interp PyInterpreterStatePtr) {
+	id := PyInterpreterState_GetID(interp)
+	for ts := PyInterpreterState_ThreadHead(interp); ts != NullThreadState; ts = PyThreadState_Next(ts) {
+		// Threads not running Python code, including ours, have no frame.
+		frame := PyThreadState_GetFrame(ts)
+		if frame == NullPyFrameObjectPtr {
+			continue
+		}
+		stack := pythonStack(frame)
+		labels := []pprofLabel{
+			{key: "interpreter", value: strconv.FormatInt(id, 10)},
+			{key: "thread", value: strconv.FormatUint(PyThreadState_GetID(ts), 10)},
+		}
+		p.mu.Lock()
+		p.builder.add(stack, labels, 1, p.interval.Nanoseconds())
+		p.mu.Unlock()
+	}
+}
+
+// pythonStack walks the stack from frame outwards, stealing the reference to
+// frame.
+func pythonStack(frame PyFrameObjectPtr) []pprofFrame {
+	var stack []pprofFrame
+	for frame != NullPyFrameObjectPtr {
+		code := PyObjectPtr(PyFrame_GetCode(frame))
+		name, filename, firstLine := codeInfo(code)
+		f := pprofFrame{
+			name:      name,
+			filename:  filename,
+			startLine: int64(firstLine),
+			line:      int64(PyFrame_GetLineNumber(frame)),
+		}
+		Py_DecRef(code)
+		stack = append(stack, f)
+
+		back := PyFrame_GetBack(frame)
+		Py_DecRef(PyObjectPtr(frame))
+		frame = back
+	}
+	return stack
+}
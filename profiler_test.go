@@ -0,0 +1,85 @@
+package gogopython_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+func TestProfiler(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+	gogopythontest.RequireExec(t, interp, `
+import time
+
+def hot(deadline):
+    while time.monotonic() < deadline:
+        pass
+
+def main():
+    hot(time.monotonic() + 0.3)`)
+
+	p := py.StartProfiler(time.Millisecond, interp.State())
+	gogopythontest.RequireExec(t, interp, "main()")
+	// Stop waits for a sample in progress, which needs the GIL.
+	ts := py.PyEval_SaveThread()
+	p.Stop()
+	py.PyEval_RestoreThread(ts)
+
+	path := filepath.Join(t.TempDir(), "python.pprof")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.WriteProfile(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found, not parsing the profile")
+	}
+	out, err := exec.Command(gotool, "tool", "pprof", "-traces", path).CombinedOutput()
+	if err != nil {
+		t.Fatalf("go tool pprof failed: %v\n%s", err, out)
+	}
+	traces := string(out)
+	for _, want := range []string{
+		"Type: wall",
+		"hot\n",
+		"main\n",
+		fmt.Sprintf("interpreter:  %d\n", py.PyInterpreterState_GetID(interp.State())),
+		"thread:  ",
+	} {
+		if !strings.Contains(traces, want) {
+			t.Errorf("profile doesn't contain %q:\n%s", want, traces)
+		}
+	}
+	if strings.Index(traces, "hot\n") > strings.Index(traces, "main\n") {
+		t.Errorf("hot isn't called by main:\n%s", traces)
+	}
+}
+
+func TestProfilerNoSamples(t *testing.T) {
+	interp := gogopythontest.NewInterpreter(t)
+
+	// An idle interpreter has no frames to sample.
+	p := py.StartProfiler(time.Millisecond, interp.State())
+	ts := py.PyEval_SaveThread()
+	time.Sleep(20 * time.Millisecond)
+	p.Stop()
+	py.PyEval_RestoreThread(ts)
+
+	if err := p.WriteProfile(new(strings.Builder)); err == nil {
+		t.Error("wrote a profile without samples")
+	}
+}
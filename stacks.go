@@ -0,0 +1,164 @@
+package gogopython
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+)
+
+// ThreadStack is the Python stack of a thread, as dumped by DumpStacks.
+type ThreadStack struct {
+	Interpreter int64  // Interpreter is the id of the thread's interpreter.
+	Thread      uint64 // Thread is the thread's id, as by threading.get_ident.
+	Current     bool   // Current is set for the thread calling DumpStacks.
+
+	// Traceback is the formatted stack, most recent call first, like
+	// faulthandler's output: one "  File "name", line N in func" line per
+	// frame, or "  <no Python frame>".
+	Traceback string
+}
+
+// DumpStacks returns the Python stacks of all threads of all interpreters.
+//
+// Like faulthandler, it doesn't need the GIL, so it works even when Python is
+// stuck, but the stacks are read without locking: they're a best effort
+// snapshot and threads or interpreters being created or torn down meanwhile
+// can crash the process. Python limits a dump to 100 threads per interpreter
+// and 100 frames per thread.
+func DumpStacks() ([]ThreadStack, error) {
+	if Py_IsInitialized() != 1 {
+		return nil, errors.New("python isn't initialized")
+	}
+	current := PyThreadState_GetUnchecked()
+
+	var stacks []ThreadStack
+	for interp := PyInterpreterState_Head(); interp != NullInterpreterState; interp = PyInterpreterState_Next(interp) {
+		dump, err := dumpTracebacks(interp, current)
+		if err != nil {
+			return stacks, err
+		}
+		id := PyInterpreterState_GetID(interp)
+		for _, stack := range parseTracebacks(dump) {
+			stack.Interpreter = id
+			stacks = append(stacks, stack)
+		}
+	}
+	return stacks, nil
+}
+
+// dumpTracebacks returns the tracebacks of the threads of an interpreter as
+// written by Py_DumpTracebackThreads.
+func dumpTracebacks(interp PyInterpreterStatePtr, current PyThreadStatePtr) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	// Read concurrently, so a dump larger than the pipe buffer can't block.
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	msg := Py_DumpTracebackThreads(int32(w.Fd()), interp, current)
+	w.Close()
+	dump := <-out
+	if msg != nil {
+		return dump, errors.New(cString(msg))
+	}
+	return dump, nil
+}
+
+// parseTracebacks splits a dump into the stacks of the threads, which start
+// with a "Thread 0x..." or "Current thread 0x..." header line.
+func parseTracebacks(dump string) []ThreadStack {
+	var stacks []ThreadStack
+	var tb strings.Builder
+	flush := func() {
+		if len(stacks) > 0 {
+			stacks[len(stacks)-1].Traceback = tb.String()
+		}
+		tb.Reset()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(dump))
+	for scanner.Scan() {
+		line := scanner.Text()
+		header, current := strings.CutPrefix(line, "Current thread 0x")
+		if !current {
+			var ok bool
+			if header, ok = strings.CutPrefix(line, "Thread 0x"); !ok {
+				if len(stacks) > 0 && line != "" {
+					tb.WriteString(line + "\n")
+				}
+				continue
+			}
+		}
+		flush()
+		hex, _, _ := strings.Cut(header, " ")
+		thread, _ := strconv.ParseUint(hex, 16, 64)
+		stacks = append(stacks, ThreadStack{Thread: thread, Current: current})
+	}
+	flush()
+	return stacks
+}
+
+// WriteStacks writes the Python stacks of all threads of all interpreters to
+// w, as returned by DumpStacks.
+func WriteStacks(w io.Writer) error {
+	stacks, err := DumpStacks()
+	for _, stack := range stacks {
+		current := ""
+		if stack.Current {
+			current = " (current)"
+		}
+		if _, err := fmt.Fprintf(w, "Interpreter %d, thread 0x%x%s (most recent call first):\n%s\n",
+			stack.Interpreter, stack.Thread, current, stack.Traceback); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// StacksHandler returns an http.Handler serving the Python stacks of all
+// threads of all interpreters as text, for use as a debug endpoint like
+// net/http/pprof's goroutine profile.
+func StacksHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := WriteStacks(w); err != nil {
+			fmt.Fprintf(w, "error: %v\n", err)
+		}
+	})
+}
+
+// DumpStacksOnSignal writes the Python stacks to w whenever one of the
+// signals is received, e.g. syscall.SIGUSR1, until stop is called.
+func DumpStacksOnSignal(w io.Writer, sigs ...os.Signal) (stop func()) {
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sigs...)
+	go func() {
+		for {
+			select {
+			case <-c:
+				if err := WriteStacks(w); err != nil {
+					fmt.Fprintf(w, "error: %v\n", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
+}
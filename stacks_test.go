@@ -0,0 +1,163 @@
+package gogopython_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// newStacksInterpreter returns an interpreter running a Python thread blocked
+// in waiting(), and calling dump() from outer().
+func newStacksInterpreter(t *testing.T, dump func()) *gogopythontest.Interpreter {
+	t.Helper()
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{
+		Config: &py.PyInterpreterConfig{
+			AllowThreads:               1,
+			Gil:                        py.OwnGil,
+			CheckMultiInterpExtensions: 1,
+		},
+		Preload: []string{"threading"},
+		// The thread's state is only freed when the interpreter ends.
+		AllowLeaks: true,
+	})
+	setGlobal(t, interp, "dump", py.NewFunction("dump", py.NullPyObjectPtr, func(_, _ py.PyObjectPtr) py.PyObjectPtr {
+		dump()
+		return py.PyLong_FromLong(0)
+	}))
+	gogopythontest.RequireExec(t, interp, `exec(compile("""
+import threading
+
+def outer():
+    return dump()
+
+def waiting(started, done):
+    started.set()
+    done.wait()
+
+started, done = threading.Event(), threading.Event()
+thread = threading.Thread(target=waiting, args=(started, done))
+thread.start()
+started.wait()
+""", "job.py", "exec"))`)
+	t.Cleanup(func() {
+		gogopythontest.RequireExec(t, interp, "done.set()\nthread.join()")
+	})
+	return interp
+}
+
+func TestDumpStacks(t *testing.T) {
+	var stacks []py.ThreadStack
+	var dumpErr error
+	interp := newStacksInterpreter(t, func() { stacks, dumpErr = py.DumpStacks() })
+	id := py.PyInterpreterState_GetID(interp.State())
+
+	gogopythontest.RequireExec(t, interp, "outer()")
+	if dumpErr != nil {
+		t.Fatal(dumpErr)
+	}
+	var current, waiting *py.ThreadStack
+	for i, stack := range stacks {
+		if stack.Interpreter != id {
+			continue
+		}
+		if stack.Current {
+			current = &stacks[i]
+		} else if strings.Contains(stack.Traceback, "in waiting") {
+			waiting = &stacks[i]
+		}
+	}
+	if current == nil || waiting == nil {
+		t.Fatalf("current or waiting thread missing from %+v", stacks)
+	}
+
+	// Stacks are most recent call first.
+	want := "  File \"job.py\", line 5 in outer\n  File \"<string>\", line 1 in <module>\n"
+	if current.Traceback != want {
+		t.Errorf("current thread traceback:\n%s\nwant:\n%s", current.Traceback, want)
+	}
+	if !strings.Contains(waiting.Traceback, "line 9 in waiting\n") || !strings.Contains(waiting.Traceback, "/threading.py") {
+		t.Errorf("waiting thread traceback:\n%s", waiting.Traceback)
+	}
+	gogopythontest.RequireEval(t, interp, "thread.ident", waiting.Thread)
+
+	// Threads not running Python code have no frames.
+	stacks, err := py.DumpStacks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stack := range stacks {
+		if stack.Current && stack.Traceback != "  <no Python frame>\n" {
+			t.Errorf("current thread traceback outside of Python:\n%s", stack.Traceback)
+		}
+	}
+}
+
+func TestWriteStacks(t *testing.T) {
+	var out strings.Builder
+	interp := newStacksInterpreter(t, func() {
+		if err := py.WriteStacks(&out); err != nil {
+			t.Error(err)
+		}
+	})
+	id := py.PyInterpreterState_GetID(interp.State())
+
+	gogopythontest.RequireExec(t, interp, "outer()")
+	header := fmt.Sprintf("Interpreter %d, thread 0x", id)
+	if !strings.Contains(out.String(), header) || !strings.Contains(out.String(), " (current) (most recent call first):\n  File \"job.py\", line 5 in outer\n") {
+		t.Errorf("WriteStacks wrote:\n%s", out.String())
+	}
+
+	rec := httptest.NewRecorder()
+	py.StacksHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/python/stacks", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("handler served Content-Type %q", ct)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, header) || !strings.Contains(body, "in waiting\n") {
+		t.Errorf("handler served:\n%s", body)
+	}
+}
+
+// syncBuilder is a strings.Builder safe for concurrent use.
+type syncBuilder struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (b *syncBuilder) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.Write(p)
+}
+
+func (b *syncBuilder) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.String()
+}
+
+func TestDumpStacksOnSignal(t *testing.T) {
+	newStacksInterpreter(t, func() {})
+
+	var out syncBuilder
+	stop := py.DumpStacksOnSignal(&out, syscall.SIGUSR1)
+	defer stop()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	// The stacks are read without the GIL, so the dump doesn't wait for the
+	// test to release it.
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(out.String(), "in waiting\n"); {
+		if time.Now().After(deadline) {
+			t.Fatalf("no stacks dumped on SIGUSR1:\n%s", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
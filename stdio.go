@@ -0,0 +1,259 @@
+package gogopython
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Streams are Go replacements for an interpreter's standard streams. Nil
+// fields leave the corresponding stream unchanged.
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Python wrapper giving the Go stream the full text file interface, e.g.
+// iteration, writelines and closing.
+const goStreamSrc = `
+import io
+
+class GoStream(io.TextIOBase):
+    def __init__(self, stream, name, mode):
+        self._stream = stream
+        self._name = name
+        self._mode = mode
+
+    @property
+    def name(self):
+        return self._name
+
+    @property
+    def mode(self):
+        return self._mode
+
+    @property
+    def encoding(self):
+        return "utf-8"
+
+    @property
+    def errors(self):
+        return "strict"
+
+    def readable(self):
+        return self._mode == "r"
+
+    def writable(self):
+        return self._mode == "w"
+
+    def isatty(self):
+        return False
+
+    def _check(self, readable):
+        if self.closed:
+            raise ValueError("I/O operation on closed file.")
+        if readable and not self.readable():
+            raise io.UnsupportedOperation("not readable")
+        if not readable and not self.writable():
+            raise io.UnsupportedOperation("not writable")
+
+    def write(self, s):
+        self._check(False)
+        if not isinstance(s, str):
+            raise TypeError(f"write() argument must be str, not {type(s).__name__}")
+        return self._stream.write(s)
+
+    def flush(self):
+        super().flush()
+        if self.writable():
+            self._stream.flush()
+
+    def read(self, size=-1):
+        self._check(True)
+        return self._stream.read(-1 if size is None else size)
+
+    def readline(self, size=-1):
+        self._check(True)
+        return self._stream.readline(-1 if size is None else size)
+`
+
+// goStream is the Go side of a redirected stream.
+type goStream struct {
+	mu sync.Mutex
+	w  io.Writer
+	r  *bufio.Reader
+}
+
+// Write writes text, returning the number of characters written as Python
+// expects.
+func (s *goStream) Write(text string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := io.WriteString(s.w, text); err != nil {
+		return 0, err
+	}
+	return utf8.RuneCountInString(text), nil
+}
+
+// Flush flushes the writer if it supports it.
+func (s *goStream) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Read reads up to size characters, or until EOF if size is negative.
+func (s *goStream) Read(size int) (string, error) {
+	return s.read(size, false)
+}
+
+// Readline reads up to size characters, stopping after a newline.
+func (s *goStream) Readline(size int) (string, error) {
+	return s.read(size, true)
+}
+
+func (s *goStream) read(size int, line bool) (string, error) {
+	// Don't hold the GIL while waiting on the reader.
+	ts := PyEval_SaveThread()
+	defer PyEval_RestoreThread(ts)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	for n := 0; size < 0 || n < size; n++ {
+		r, _, err := s.r.ReadRune()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", err
+		}
+		b.WriteRune(r)
+		if line && r == '\n' {
+			break
+		}
+	}
+	return b.String(), nil
+}
+
+var streamClass struct {
+	once  sync.Once
+	class *Class
+	err   error
+}
+
+// RedirectStreams replaces sys.stdin, sys.stdout and sys.stderr in the
+// current interpreter with file objects backed by the given Go readers and
+// writers. Other interpreters are unaffected, so each can capture its own
+// output, including anything written by PyErr_Print.
+//
+// The returned func restores the previous streams. It, like RedirectStreams,
+// requires the caller to hold the GIL.
+func RedirectStreams(s Streams) (restore func(), err error) {
+	streamClass.once.Do(func() {
+		streamClass.class, streamClass.err = NewClass[goStream]("GoStreamBackend").
+			Method("write", (*goStream).Write).
+			Method("flush", (*goStream).Flush).
+			Method("read", (*goStream).Read).
+			Method("readline", (*goStream).Readline).
+			Build()
+	})
+	if streamClass.err != nil {
+		return nil, streamClass.err
+	}
+
+	wrapper, err := compileHelper(goStreamSrc, "GoStream")
+	if err != nil {
+		return nil, err
+	}
+	defer Py_DecRef(wrapper)
+
+	sys := PyImport_ImportModule("sys")
+	if sys == NullPyObjectPtr {
+		return nil, FetchError()
+	}
+	defer Py_DecRef(sys)
+
+	type replacement struct {
+		name, mode string
+		stream     *goStream
+	}
+	var replace []replacement
+	if s.Stdin != nil {
+		replace = append(replace, replacement{"stdin", "r", &goStream{r: bufio.NewReader(s.Stdin)}})
+	}
+	if s.Stdout != nil {
+		replace = append(replace, replacement{"stdout", "w", &goStream{w: s.Stdout}})
+	}
+	if s.Stderr != nil {
+		replace = append(replace, replacement{"stderr", "w", &goStream{w: s.Stderr}})
+	}
+
+	// Create all the new streams before replacing any.
+	streams := make([]PyObjectPtr, 0, len(replace))
+	release := func() {
+		for _, obj := range streams {
+			Py_DecRef(obj)
+		}
+	}
+	for _, r := range replace {
+		backend, err := streamClass.class.Wrap(r.stream)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		name, _ := ToPython("<" + r.name + ">")
+		mode, _ := ToPython(r.mode)
+		args := newTuple(backend, name, mode)
+		obj := PyObject_CallObject(wrapper, args)
+		Py_DecRef(args)
+		Py_DecRef(mode)
+		Py_DecRef(name)
+		Py_DecRef(backend)
+		if obj == NullPyObjectPtr {
+			err := FetchError()
+			release()
+			return nil, err
+		}
+		streams = append(streams, obj)
+	}
+
+	previous := make([]PyObjectPtr, len(replace))
+	for i, r := range replace {
+		previous[i] = PyObject_GetAttrString(sys, r.name)
+		if previous[i] == NullPyObjectPtr {
+			// The stream may not exist, e.g. if deleted by a script.
+			PyErr_Clear()
+		}
+		PyObject_SetAttrString(sys, r.name, streams[i])
+	}
+
+	return func() {
+		sys := PyImport_ImportModule("sys")
+		if sys == NullPyObjectPtr {
+			PyErr_Clear()
+		}
+		for i, r := range replace {
+			if res := callMethod(streams[i], "flush"); res != NullPyObjectPtr {
+				Py_DecRef(res)
+			}
+			PyErr_Clear()
+			if previous[i] != NullPyObjectPtr {
+				if sys != NullPyObjectPtr {
+					PyObject_SetAttrString(sys, r.name, previous[i])
+				}
+				Py_DecRef(previous[i])
+			}
+		}
+		release()
+		Py_DecRef(sys)
+	}, nil
+}
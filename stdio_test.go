@@ -0,0 +1,59 @@
+package gogopython_test
+
+import (
+	"strings"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+func TestRedirectStreams(t *testing.T) {
+	// The stream classes are created once per interpreter.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+	gogopythontest.RequireExec(t, interp, "import sys\noriginal = sys.stdout")
+
+	var stdout, stderr strings.Builder
+	restore, err := py.RedirectStreams(py.Streams{
+		Stdin:  strings.NewReader("first line\nsecond ☃\nrest"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireEval(t, interp, "input()", "first line")
+	gogopythontest.RequireEval(t, interp, "sys.stdin.readline()", "second ☃\n")
+	gogopythontest.RequireEval(t, interp, "sys.stdin.read()", "rest")
+	gogopythontest.RequireEval(t, interp, "sys.stdout.write('☃')", 1)
+	gogopythontest.RequireExec(t, interp, "print('out', end='')\nsys.stderr.writelines(['a', 'b'])")
+	gogopythontest.RequireRaises(t, interp, "sys.stdout.write(b'bytes')", "TypeError")
+	gogopythontest.RequireRaises(t, interp, "sys.stdout.read()", "UnsupportedOperation")
+
+	// Errors printed by Python go to the redirected stderr.
+	py.PyErr_SetString(py.PyExc_ValueError, "printed")
+	py.PyErr_Print()
+
+	restore()
+	gogopythontest.RequireEval(t, interp, "sys.stdout is original", true)
+	if got := stdout.String(); got != "☃out" {
+		t.Errorf("stdout = %q", got)
+	}
+	if got := stderr.String(); !strings.HasPrefix(got, "ab") || !strings.Contains(got, "ValueError: printed") {
+		t.Errorf("stderr = %q", got)
+	}
+}
+
+func TestRedirectStreamsPartial(t *testing.T) {
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+	gogopythontest.RequireExec(t, interp, "import sys\nstdin, stderr = sys.stdin, sys.stderr")
+
+	var stdout strings.Builder
+	restore, err := py.RedirectStreams(py.Streams{Stdout: &stdout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+	gogopythontest.RequireEval(t, interp, "sys.stdin is stdin and sys.stderr is stderr", true)
+	gogopythontest.RequireEval(t, interp, "(sys.stdout.name, sys.stdout.mode, sys.stdout.isatty())", []any{"<stdout>", "w", false})
+}
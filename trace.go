@@ -0,0 +1,191 @@
+package gogopython
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ebitengine/purego"
+)
+
+// TraceEvent is an execution event reported to a TraceFunc.
+type TraceEvent struct {
+	What PyTraceWhat
+
+	// Function is the qualified name of the running Python function, or of
+	// the called function for PyTrace_CCall, PyTrace_CException and
+	// PyTrace_CReturn events.
+	Function string
+	Filename string // Filename is the file of the running Python code.
+	Line     int    // Line is the line number of the running Python code.
+
+	// Frame is the running Python frame and Arg the event argument: the
+	// value being returned for PyTrace_Return (None if returning because of
+	// an exception), the (type, value, traceback) tuple for
+	// PyTrace_Exception, or the called function for C events.
+	//
+	// Both are borrowed references that are only valid during the call.
+	Frame PyFrameObjectPtr
+	Arg   PyObjectPtr
+}
+
+// TraceFunc receives execution events. It's called with the GIL held.
+//
+// Returning an error raises it in the traced Python code as a RuntimeError,
+// or as the Python exception already set by the TraceFunc, if any. The
+// TraceFunc stays installed, so e.g. a step limit keeps failing the code run
+// afterwards until it's reset or removed.
+type TraceFunc func(TraceEvent) error
+
+// Name of the capsules carrying TraceFuncs.
+const traceCapsuleName = "gogopython.trace"
+
+var traceTrampoline struct {
+	once sync.Once
+	fn   PyTraceFunc
+}
+
+// SetTrace sets fn as the trace function of the current thread, like
+// sys.settrace. Trace functions receive PyTrace_Call, PyTrace_Exception,
+// PyTrace_Line and PyTrace_Return events, and PyTrace_Opcode events if the
+// frame's f_trace_opcodes is set. A nil fn removes the trace function.
+//
+// Requires the caller to hold the GIL.
+func SetTrace(fn TraceFunc) error {
+	return setTraceFunc(PyEval_SetTrace, fn)
+}
+
+// SetTraceAllThreads is like SetTrace, but sets the trace function of all
+// threads of the current interpreter.
+//
+// Requires the caller to hold the GIL.
+func SetTraceAllThreads(fn TraceFunc) error {
+	return setTraceFunc(PyEval_SetTraceAllThreads, fn)
+}
+
+// SetProfile sets fn as the profile function of the current thread, like
+// sys.setprofile. Profile functions receive the call, return and exception
+// events of both Python and C functions, but not line events. A nil fn
+// removes the profile function.
+//
+// Requires the caller to hold the GIL.
+func SetProfile(fn TraceFunc) error {
+	return setTraceFunc(PyEval_SetProfile, fn)
+}
+
+// SetProfileAllThreads is like SetProfile, but sets the profile function of
+// all threads of the current interpreter.
+//
+// Requires the caller to hold the GIL.
+func SetProfileAllThreads(fn TraceFunc) error {
+	return setTraceFunc(PyEval_SetProfileAllThreads, fn)
+}
+
+func setTraceFunc(set func(PyTraceFunc, PyObjectPtr), fn TraceFunc) error {
+	if fn == nil {
+		set(0, NullPyObjectPtr)
+		return nil
+	}
+	traceTrampoline.once.Do(func() {
+		traceTrampoline.fn = purego.NewCallback(traceDispatch)
+	})
+
+	// Python keeps a reference to the capsule, releasing fn when it's
+	// replaced.
+	capsule, err := NewCapsule(traceCapsuleName, fn)
+	if err != nil {
+		return err
+	}
+	defer Py_DecRef(capsule)
+	set(traceTrampoline.fn, capsule)
+	return FetchError()
+}
+
+// traceDispatch is the Py_tracefunc passing events to the TraceFunc in the
+// capsule obj.
+func traceDispatch(obj PyObjectPtr, frame PyFrameObjectPtr, what PyTraceWhat, arg PyObjectPtr) (result int32) {
+	defer func() {
+		if r := recover(); r != nil {
+			PyErr_SetString(PyExc_RuntimeError, fmt.Sprintf("trace function panicked: %v", r))
+			result = -1
+		}
+	}()
+
+	fn, err := CapsuleValue[TraceFunc](obj, traceCapsuleName)
+	if err != nil {
+		PyErr_SetString(PyExc_RuntimeError, err.Error())
+		return -1
+	}
+
+	ev := TraceEvent{What: what, Frame: frame, Arg: arg}
+	if frame != NullPyFrameObjectPtr {
+		code := PyObjectPtr(PyFrame_GetCode(frame))
+		ev.Function, ev.Filename, _ = codeInfo(code)
+		Py_DecRef(code)
+		ev.Line = int(PyFrame_GetLineNumber(frame))
+	}
+	switch what {
+	case PyTrace_CCall, PyTrace_CException, PyTrace_CReturn:
+		ev.Function = qualName(arg)
+	}
+
+	if err := fn(ev); err != nil {
+		if PyErr_Occurred() == NullPyObjectPtr {
+			PyErr_SetString(PyExc_RuntimeError, err.Error())
+		}
+		return -1
+	}
+	return 0
+}
+
+// codeInfo returns the qualified name, filename and first line of a code
+// object, using "<unknown>" for missing names.
+func codeInfo(code PyObjectPtr) (name, filename string, firstLine int) {
+	name, filename = "<unknown>", "<unknown>"
+	for _, attr := range []struct {
+		name string
+		dst  any
+	}{
+		{"co_qualname", &name},
+		{"co_filename", &filename},
+		{"co_firstlineno", &firstLine},
+	} {
+		obj := PyObject_GetAttrString(code, attr.name)
+		if obj == NullPyObjectPtr {
+			PyErr_Clear()
+			continue
+		}
+		_ = FromPython(obj, attr.dst)
+		Py_DecRef(obj)
+	}
+	return name, filename, firstLine
+}
+
+// qualName returns the qualified name of a function, including the module
+// for functions of C modules, e.g. "time.sleep".
+func qualName(fn PyObjectPtr) string {
+	var name, module string
+	for _, attr := range []struct {
+		name string
+		dst  *string
+	}{
+		{"__qualname__", &name},
+		{"__module__", &module},
+	} {
+		obj := PyObject_GetAttrString(fn, attr.name)
+		if obj == NullPyObjectPtr {
+			PyErr_Clear()
+			continue
+		}
+		if obj != Py_None {
+			_ = FromPython(obj, attr.dst)
+		}
+		Py_DecRef(obj)
+	}
+	switch {
+	case name == "":
+		return "<unknown>"
+	case module == "" || module == "builtins":
+		return name
+	}
+	return module + "." + name
+}
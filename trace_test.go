@@ -0,0 +1,210 @@
+package gogopython_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+// traced is the part of a TraceEvent that's valid after the call.
+type traced struct {
+	What     py.PyTraceWhat
+	Function string
+	Line     int
+}
+
+// newTraceInterpreter returns an interpreter with functions defined in
+// job.py.
+func newTraceInterpreter(t *testing.T) *gogopythontest.Interpreter {
+	t.Helper()
+	// Tracing instruments code with per interpreter monitoring data.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{AllowLeaks: true})
+	gogopythontest.RequireExec(t, interp, `exec(compile("""
+def add(a, b):
+    return a + b
+
+def fail():
+    raise KeyError('k')
+""", "job.py", "exec"))`)
+	return interp
+}
+
+// record returns a TraceFunc appending the events of job.py to events.
+func record(events *[]traced) py.TraceFunc {
+	return func(ev py.TraceEvent) error {
+		if ev.Filename == "job.py" {
+			*events = append(*events, traced{ev.What, ev.Function, ev.Line})
+		}
+		return nil
+	}
+}
+
+func TestSetTrace(t *testing.T) {
+	interp := newTraceInterpreter(t)
+
+	var events []traced
+	var returned []int
+	err := py.SetTrace(func(ev py.TraceEvent) error {
+		if ev.What == py.PyTrace_Return && ev.Filename == "job.py" {
+			returned = append(returned, int(py.PyLong_AsLong(ev.Arg)))
+		}
+		return record(&events)(ev)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireEval(t, interp, "add(1, 2)", 3)
+	want := []traced{
+		{py.PyTrace_Call, "add", 2},
+		{py.PyTrace_Line, "add", 3},
+		{py.PyTrace_Return, "add", 3},
+	}
+	if !slices.Equal(events, want) || !slices.Equal(returned, []int{3}) {
+		t.Errorf("events = %v returning %v, want %v returning [3]", events, returned, want)
+	}
+
+	events = nil
+	var exc, ret py.PyObjectPtr
+	err = py.SetTrace(func(ev py.TraceEvent) error {
+		switch {
+		case ev.Filename != "job.py":
+		case ev.What == py.PyTrace_Exception:
+			exc = ev.Arg
+		case ev.What == py.PyTrace_Return:
+			ret = ev.Arg
+		}
+		return record(&events)(ev)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireRaises(t, interp, "fail()", "KeyError")
+	want = []traced{
+		{py.PyTrace_Call, "fail", 5},
+		{py.PyTrace_Line, "fail", 6},
+		{py.PyTrace_Exception, "fail", 6},
+		{py.PyTrace_Return, "fail", 6},
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	// The exception event gets a tuple, the return of a raising function
+	// None.
+	if exc == py.NullPyObjectPtr || ret != py.Py_None {
+		t.Errorf("exception event arg %x, return event arg %x", exc, ret)
+	}
+
+	if err := py.SetTrace(nil); err != nil {
+		t.Fatal(err)
+	}
+	events = nil
+	gogopythontest.RequireEval(t, interp, "add(1, 2)", 3)
+	if len(events) != 0 {
+		t.Errorf("removed trace function got %v", events)
+	}
+}
+
+func TestSetTraceErrors(t *testing.T) {
+	interp := newTraceInterpreter(t)
+
+	steps := 0
+	limit := func(ev py.TraceEvent) error {
+		if ev.What != py.PyTrace_Line || ev.Filename != "job.py" {
+			return nil
+		}
+		if steps++; steps > 1 {
+			return errors.New("step limit reached")
+		}
+		return nil
+	}
+	if err := py.SetTrace(limit); err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireEval(t, interp, "add(1, 2)", 3)
+	exc := gogopythontest.RequireRaises(t, interp, "add(1, 2)", "RuntimeError")
+	if exc.Message != "step limit reached" {
+		t.Errorf("limit raised %q", exc.Message)
+	}
+	// The trace function stays installed.
+	gogopythontest.RequireRaises(t, interp, "add(1, 2)", "RuntimeError")
+
+	// Python exceptions set by the trace function are kept.
+	err := py.SetTrace(func(ev py.TraceEvent) error {
+		if ev.What == py.PyTrace_Call && ev.Function == "add" {
+			py.PyErr_SetString(py.PyExc_ValueError, "no adding")
+			return errors.New("ignored")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc = gogopythontest.RequireRaises(t, interp, "add(1, 2)", "ValueError")
+	if exc.Message != "no adding" {
+		t.Errorf("trace function raised %q", exc.Message)
+	}
+
+	err = py.SetTrace(func(ev py.TraceEvent) error {
+		if ev.Function == "add" {
+			panic("boom")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exc = gogopythontest.RequireRaises(t, interp, "add(1, 2)", "RuntimeError")
+	if exc.Message != "trace function panicked: boom" {
+		t.Errorf("panicking trace function raised %q", exc.Message)
+	}
+
+	if err := py.SetTrace(nil); err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireEval(t, interp, "add(1, 2)", 3)
+}
+
+func TestSetProfile(t *testing.T) {
+	interp := newTraceInterpreter(t)
+
+	var events []traced
+	err := py.SetProfile(func(ev py.TraceEvent) error {
+		switch ev.What {
+		case py.PyTrace_CCall, py.PyTrace_CException, py.PyTrace_CReturn:
+			events = append(events, traced{ev.What, ev.Function, 0})
+			return nil
+		}
+		return record(&events)(ev)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireExec(t, interp, `
+import time
+add(1, 2)
+time.monotonic()
+try:
+    len(1)
+except TypeError:
+    pass`)
+	if err := py.SetProfile(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Profile functions get C calls, qualified by their module, but no
+	// line events.
+	want := []traced{
+		{py.PyTrace_Call, "add", 2},
+		{py.PyTrace_Return, "add", 3},
+		{py.PyTrace_CCall, "time.monotonic", 0},
+		{py.PyTrace_CReturn, "time.monotonic", 0},
+		{py.PyTrace_CCall, "len", 0},
+		{py.PyTrace_CException, "len", 0},
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
@@ -0,0 +1,203 @@
+package gogopython
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+)
+
+// AllocationSite is a source line that allocated memory still traced by
+// tracemalloc when a snapshot was taken.
+type AllocationSite struct {
+	Filename string
+	Line     int
+	Size     int64 // Size is the total size of the blocks in bytes.
+	Count    int64 // Count is the number of blocks.
+}
+
+// AllocationDiff is the change at an allocation site between two snapshots.
+type AllocationDiff struct {
+	AllocationSite       // AllocationSite is the site in the newer snapshot.
+	SizeDiff       int64 // SizeDiff is the change in size, in bytes.
+	CountDiff      int64 // CountDiff is the change in the number of blocks.
+}
+
+// MallocSnapshot is a tracemalloc snapshot, with the traces grouped by the
+// line that allocated them.
+//
+// It only holds copies of the snapshot data, so it's safe to use after
+// releasing the GIL or from other goroutines.
+type MallocSnapshot struct {
+	Time   time.Time
+	Traced int64 // Traced is the traced memory in bytes.
+	Peak   int64 // Peak is the peak traced memory in bytes.
+
+	// Sites are the allocation sites, largest first. Allocations made by
+	// tracemalloc itself are excluded.
+	Sites []AllocationSite
+}
+
+// Python helpers wrapping the tracemalloc module.
+const traceMallocSrc = `
+import tracemalloc
+
+def start(frames):
+    tracemalloc.start(frames)
+
+def stop():
+    tracemalloc.stop()
+
+def snapshot():
+    if not tracemalloc.is_tracing():
+        return None
+    snap = tracemalloc.take_snapshot().filter_traces((
+        tracemalloc.Filter(False, tracemalloc.__file__),
+        tracemalloc.Filter(False, "<unknown>"),
+    ))
+    traced, peak = tracemalloc.get_traced_memory()
+    sites = [
+        {"filename": s.traceback[0].filename, "line": s.traceback[0].lineno,
+         "size": s.size, "count": s.count}
+        for s in snap.statistics("lineno")
+    ]
+    return {"traced": traced, "peak": peak, "sites": sites}
+`
+
+// StartTraceMalloc starts tracing Python memory allocations, storing
+// tracebacks of up to frames frames. Tracing can also be enabled at startup
+// with PyConfig_3_12.TraceMalloc.
+//
+// In Python 3.12 tracemalloc traces the whole process, including all
+// sub-interpreters, but can only be used from interpreters sharing the main
+// interpreter's GIL.
+//
+// Requires the caller to hold the GIL.
+func StartTraceMalloc(frames int) error {
+	res, err := callTraceMalloc("start", max(frames, 1))
+	if err != nil {
+		return err
+	}
+	Py_DecRef(res)
+	return nil
+}
+
+// StopTraceMalloc stops tracing Python memory allocations and clears the
+// traces.
+//
+// Requires the caller to hold the GIL.
+func StopTraceMalloc() error {
+	res, err := callTraceMalloc("stop")
+	if err != nil {
+		return err
+	}
+	Py_DecRef(res)
+	return nil
+}
+
+// TakeMallocSnapshot takes a snapshot of the memory traced by tracemalloc.
+// Tracing must have been started.
+//
+// Requires the caller to hold the GIL.
+func TakeMallocSnapshot() (*MallocSnapshot, error) {
+	res, err := callTraceMalloc("snapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer Py_DecRef(res)
+	if res == Py_None {
+		return nil, errors.New("tracemalloc is not tracing")
+	}
+
+	snap := &MallocSnapshot{Time: time.Now()}
+	if err := FromPython(res, snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// callTraceMalloc calls one of the tracemalloc helpers, returning a new
+// reference to the result.
+func callTraceMalloc(name string, args ...any) (PyObjectPtr, error) {
+	fn, err := compileHelper(traceMallocSrc, name)
+	if err != nil {
+		return NullPyObjectPtr, err
+	}
+	defer Py_DecRef(fn)
+
+	items := make([]PyObjectPtr, len(args))
+	for i, arg := range args {
+		if items[i], err = ToPython(arg); err != nil {
+			return NullPyObjectPtr, err
+		}
+		defer Py_DecRef(items[i])
+	}
+	tuple := newTuple(items...)
+	defer Py_DecRef(tuple)
+
+	res := PyObject_Call(fn, tuple, NullPyObjectPtr)
+	if res == NullPyObjectPtr {
+		return NullPyObjectPtr, FetchError()
+	}
+	return res, nil
+}
+
+// Top returns up to n of the largest allocation sites.
+func (s *MallocSnapshot) Top(n int) []AllocationSite {
+	return s.Sites[:min(max(n, 0), len(s.Sites))]
+}
+
+// Compare returns how each allocation site changed since an older snapshot,
+// sorted by the absolute size difference, largest first. Sites that didn't
+// change are omitted.
+func (s *MallocSnapshot) Compare(old *MallocSnapshot) []AllocationDiff {
+	type key struct {
+		filename string
+		line     int
+	}
+	before := make(map[key]AllocationSite, len(old.Sites))
+	for _, site := range old.Sites {
+		before[key{site.Filename, site.Line}] = site
+	}
+
+	var diffs []AllocationDiff
+	for _, site := range s.Sites {
+		k := key{site.Filename, site.Line}
+		prev := before[k]
+		delete(before, k)
+		diffs = append(diffs, AllocationDiff{
+			AllocationSite: site,
+			SizeDiff:       site.Size - prev.Size,
+			CountDiff:      site.Count - prev.Count,
+		})
+	}
+	// Sites that were freed entirely.
+	for k, prev := range before {
+		diffs = append(diffs, AllocationDiff{
+			AllocationSite: AllocationSite{Filename: k.filename, Line: k.line},
+			SizeDiff:       -prev.Size,
+			CountDiff:      -prev.Count,
+		})
+	}
+
+	diffs = slices.DeleteFunc(diffs, func(d AllocationDiff) bool {
+		return d.SizeDiff == 0 && d.CountDiff == 0
+	})
+	slices.SortFunc(diffs, func(a, b AllocationDiff) int {
+		if c := cmp.Compare(abs(b.SizeDiff), abs(a.SizeDiff)); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Filename, b.Filename); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Line, b.Line)
+	})
+	return diffs
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
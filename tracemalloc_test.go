@@ -0,0 +1,95 @@
+package gogopython_test
+
+import (
+	"reflect"
+	"testing"
+
+	py "github.com/voutilad/gogopython"
+	"github.com/voutilad/gogopython/gogopythontest"
+)
+
+func TestTraceMalloc(t *testing.T) {
+	// tracemalloc can only be used from interpreters sharing the main GIL.
+	interp := gogopythontest.NewInterpreterWithOptions(t, gogopythontest.Options{
+		Config: &py.PyInterpreterConfig{
+			UseMainObMalloc: 1,
+			AllowThreads:    1,
+			Gil:             py.SharedGil,
+		},
+		Preload: []string{"tracemalloc"},
+		// The traces are only freed when tracing stops.
+		AllowLeaks: true,
+	})
+	if _, err := py.TakeMallocSnapshot(); err == nil {
+		t.Error("took a snapshot without tracing")
+	}
+	if err := py.StartTraceMalloc(1); err != nil {
+		t.Fatal(err)
+	}
+	defer py.StopTraceMalloc()
+
+	before, err := py.TakeMallocSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gogopythontest.RequireExec(t, interp, `exec(compile("\ndata = [bytearray(10_000) for _ in range(100)]", "alloc.py", "exec"))`)
+	after, err := py.TakeMallocSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Traced < before.Traced+1_000_000 || after.Peak < after.Traced || !after.Time.After(before.Time) {
+		t.Errorf("snapshots traced %d then %d bytes, peak %d", before.Traced, after.Traced, after.Peak)
+	}
+
+	var site *py.AllocationDiff
+	for _, d := range after.Compare(before) {
+		if d.Filename == "alloc.py" && d.Line == 2 {
+			site = &d
+			break
+		}
+	}
+	if site == nil || site.SizeDiff < 1_000_000 || site.CountDiff < 100 || site.Size != site.SizeDiff {
+		t.Errorf("Compare found %+v for the allocating line", site)
+	}
+	if top := after.Top(1); len(top) != 1 || top[0].Filename != "alloc.py" {
+		t.Errorf("Top(1) = %+v, want the allocating line", top)
+	}
+
+	if err := py.StopTraceMalloc(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := py.TakeMallocSnapshot(); err == nil {
+		t.Error("took a snapshot after stopping tracing")
+	}
+}
+
+func TestMallocSnapshotCompare(t *testing.T) {
+	old := &py.MallocSnapshot{Sites: []py.AllocationSite{
+		{Filename: "a.py", Line: 1, Size: 100, Count: 1},
+		{Filename: "a.py", Line: 2, Size: 50, Count: 5},
+		{Filename: "b.py", Line: 1, Size: 10, Count: 1},
+	}}
+	snap := &py.MallocSnapshot{Sites: []py.AllocationSite{
+		{Filename: "c.py", Line: 3, Size: 300, Count: 3},
+		{Filename: "a.py", Line: 1, Size: 100, Count: 1},
+		{Filename: "a.py", Line: 2, Size: 10, Count: 1},
+	}}
+	want := []py.AllocationDiff{
+		{AllocationSite: py.AllocationSite{Filename: "c.py", Line: 3, Size: 300, Count: 3}, SizeDiff: 300, CountDiff: 3},
+		{AllocationSite: py.AllocationSite{Filename: "a.py", Line: 2, Size: 10, Count: 1}, SizeDiff: -40, CountDiff: -4},
+		{AllocationSite: py.AllocationSite{Filename: "b.py", Line: 1}, SizeDiff: -10, CountDiff: -1},
+	}
+	if got := snap.Compare(old); !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare() = %+v, want %+v", got, want)
+	}
+
+	if top := snap.Top(2); !reflect.DeepEqual(top, snap.Sites[:2]) {
+		t.Errorf("Top(2) = %+v", top)
+	}
+	if top := snap.Top(10); len(top) != 3 {
+		t.Errorf("Top(10) returned %d sites, want all 3", len(top))
+	}
+	if top := snap.Top(-1); len(top) != 0 {
+		t.Errorf("Top(-1) returned %d sites", len(top))
+	}
+}
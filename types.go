@@ -9,6 +9,9 @@ type PyTypeObjectPtr PyObjectPtr
 // PyCodeObjectPtr is a pointer to an underlying Python code object.
 type PyCodeObjectPtr PyObjectPtr
 
+// PyFrameObjectPtr is a pointer to an underlying Python frame object.
+type PyFrameObjectPtr PyObjectPtr
+
 // WCharPtr is a pointer to a Python wchar_t string.
 type WCharPtr *byte
 
@@ -24,6 +27,9 @@ const NullPyTypeObjectPtr PyTypeObjectPtr = 0
 // NullPyCodeObjectPtr represents a NULL pointer to a Python code object.
 const NullPyCodeObjectPtr PyCodeObjectPtr = 0
 
+// NullPyFrameObjectPtr represents a NULL pointer to a Python frame object.
+const NullPyFrameObjectPtr PyFrameObjectPtr = 0
+
 type EncodingErrors = string
 
 const (
@@ -153,11 +159,38 @@ type PyStatus struct {
 	ExitCode int32
 }
 
+// PyCompilerFlags controls compilation. FeatureVersion is the minor version
+// of Python 3 whose grammar is accepted when producing an AST with
+// PyCF_OnlyAST, so a zero value then only accepts Python 3.0 syntax.
 type PyCompilerFlags struct {
-	Flags          int32
+	Flags          CompilerFlag
 	FeatureVersion int32
 }
 
+// CompilerFlag is a flag for PyCompilerFlags.Flags.
+type CompilerFlag = int32
+
+const (
+	// Future features, as enabled by "from __future__ import ...". Only
+	// annotations and barry_as_FLUFL change anything in Python 3.
+	FutureDivision        CompilerFlag = 0x20000
+	FutureAbsoluteImport  CompilerFlag = 0x40000
+	FutureWithStatement   CompilerFlag = 0x80000
+	FuturePrintFunction   CompilerFlag = 0x100000
+	FutureUnicodeLiterals CompilerFlag = 0x200000
+	FutureBarryAsBDFL     CompilerFlag = 0x400000
+	FutureGeneratorStop   CompilerFlag = 0x800000
+	FutureAnnotations     CompilerFlag = 0x1000000
+
+	PyCF_SourceIsUTF8         CompilerFlag = 0x0100 // Source is already UTF-8.
+	PyCF_DontImplyDedent      CompilerFlag = 0x0200 // Used by the interactive console.
+	PyCF_OnlyAST              CompilerFlag = 0x0400 // Return an ast.AST instead of code.
+	PyCF_IgnoreCookie         CompilerFlag = 0x0800 // Ignore any coding declaration.
+	PyCF_TypeComments         CompilerFlag = 0x1000 // Include type comments in the AST.
+	PyCF_AllowTopLevelAwait   CompilerFlag = 0x2000 // Allow await, async for and async with at the top level.
+	PyCF_AllowIncompleteInput CompilerFlag = 0x4000 // Used by the interactive console.
+)
+
 type OptimizeLevel = int32
 
 const (
@@ -167,6 +200,9 @@ const (
 	RemoveDebugsAssertsAndDocstrings OptimizeLevel = 2  // __debug__ is False, no asserts, no docstrings.
 )
 
+// PyMarshalVersion is the current marshal format version.
+const PyMarshalVersion int32 = 4
+
 type PyMemAllocator = int32
 
 const (
@@ -179,6 +215,25 @@ const (
 	PyMemAllocator_PyMallocDebug        // Use Python's pymalloc with debug hooks.
 )
 
+// PyMemAllocatorDomain identifies one of Python's memory allocator domains.
+type PyMemAllocatorDomain int32
+
+const (
+	PyMem_DomainRaw PyMemAllocatorDomain = iota // PyMem_RawMalloc() and friends, may be called without the GIL.
+	PyMem_DomainMem                             // PyMem_Malloc() and friends, requires the GIL.
+	PyMem_DomainObj                             // PyObject_Malloc() and friends, requires the GIL.
+)
+
+// PyMemAllocatorEx is a Python memory allocator: C function pointers that
+// are each passed Ctx as their first argument.
+type PyMemAllocatorEx struct {
+	Ctx     uintptr
+	Malloc  uintptr // void *malloc(void *ctx, size_t size)
+	Calloc  uintptr // void *calloc(void *ctx, size_t nelem, size_t elsize)
+	Realloc uintptr // void *realloc(void *ctx, void *ptr, size_t new_size)
+	Free    uintptr // void free(void *ctx, void *ptr)
+}
+
 type PyPreConfig struct {
 	ConfigInit        int32
 	ParseArgv         int32
@@ -350,6 +405,24 @@ type PythonLibraryPtr = uintptr
 // PyCFunction points to a C function implementation.
 type PyCFunction = uintptr
 
+// PyTraceFunc points to a C trace or profile function:
+// int (*)(PyObject *obj, PyFrameObject *frame, int what, PyObject *arg).
+type PyTraceFunc = uintptr
+
+// PyTraceWhat is the kind of event passed to a PyTraceFunc.
+type PyTraceWhat int32
+
+const (
+	PyTrace_Call       PyTraceWhat = iota // A Python function is called.
+	PyTrace_Exception                     // An exception is raised.
+	PyTrace_Line                          // A new line is about to run.
+	PyTrace_Return                        // A Python function is returning.
+	PyTrace_CCall                         // A C function is about to be called.
+	PyTrace_CException                    // A C function raised an exception.
+	PyTrace_CReturn                       // A C function returned.
+	PyTrace_Opcode                        // A new opcode is about to run.
+)
+
 // MethodFlags bits indicate how the method call is constructed.
 type MethodFlags int32
 
@@ -385,6 +458,12 @@ type PyMethodDef struct {
 	Docstring *byte       // Docstring is a C string describing documentation for the method.
 }
 
+// PyCapsuleDestructor points to a C function called when a capsule is
+// destroyed, with the signature:
+//
+//	void destructor(PyObject *capsule)
+type PyCapsuleDestructor = uintptr
+
 type PySendResult int32
 
 const (
@@ -392,3 +471,110 @@ const (
 	PyGen_Error  PySendResult = -1
 	PyGen_Next   PySendResult = 1
 )
+
+// PyModuleDefBase is the PyModuleDef_Base header of a PyModuleDef. It
+// includes the object header as module definitions are Python objects.
+type PyModuleDefBase struct {
+	RefCount int64   // ob_refcnt
+	Type     uintptr // ob_type
+	Init     uintptr // m_init
+	Index    int64   // m_index
+	Copy     PyObjectPtr
+}
+
+// PyModuleDefHeadInit is the equivalent of Python's PyModuleDef_HEAD_INIT
+// macro. Module definitions are immortal objects.
+var PyModuleDefHeadInit = PyModuleDefBase{RefCount: immortalRefCount}
+
+// Reference count used for immortal objects on 64-bit platforms.
+const immortalRefCount = 0xffffffff
+
+// PyModuleDef describes a Python extension module.
+//
+// It must not move or be collected for as long as the Python interpreter
+// may use it, which in practice means for the life of the process.
+type PyModuleDef struct {
+	Base     PyModuleDefBase
+	Name     *byte            // Name is a C string with the module name.
+	Doc      *byte            // Doc is a C string with the module docstring.
+	Size     int64            // Size of the per-module state, or -1.
+	Methods  *PyMethodDef     // Methods is a NULL-terminated array, or nil.
+	Slots    *PyModuleDefSlot // Slots is a zero-terminated array, or nil.
+	Traverse uintptr
+	Clear    uintptr
+	Free     uintptr
+}
+
+// ModuleSlot identifies the kind of a PyModuleDefSlot for multi-phase
+// initialization.
+type ModuleSlot int32
+
+const (
+	ModuleSlotCreate               ModuleSlot = 1 // Py_mod_create
+	ModuleSlotExec                 ModuleSlot = 2 // Py_mod_exec
+	ModuleSlotMultipleInterpreters ModuleSlot = 3 // Py_mod_multiple_interpreters (3.12+)
+)
+
+// Values for the ModuleSlotMultipleInterpreters slot.
+const (
+	ModuleMultipleInterpretersNotSupported uintptr = 0
+	ModuleMultipleInterpretersSupported    uintptr = 1
+	ModulePerInterpreterGilSupported       uintptr = 2
+)
+
+// PyModuleDefSlot is a single slot used for multi-phase module
+// initialization.
+type PyModuleDefSlot struct {
+	Slot  ModuleSlot
+	Value uintptr
+}
+
+// TypeSlot identifies the kind of a PyTypeSlot.
+type TypeSlot int32
+
+const (
+	TypeSlotCall     TypeSlot = 50 // Py_tp_call
+	TypeSlotDealloc  TypeSlot = 52 // Py_tp_dealloc
+	TypeSlotDoc      TypeSlot = 56 // Py_tp_doc
+	TypeSlotIter     TypeSlot = 62 // Py_tp_iter
+	TypeSlotIterNext TypeSlot = 63 // Py_tp_iternext
+	TypeSlotMethods  TypeSlot = 64 // Py_tp_methods
+	TypeSlotNew      TypeSlot = 65 // Py_tp_new
+	TypeSlotRepr     TypeSlot = 66 // Py_tp_repr
+	TypeSlotGetSet   TypeSlot = 73 // Py_tp_getset
+	TypeSlotFree     TypeSlot = 74 // Py_tp_free
+)
+
+// PyTypeSlot is a single slot of a PyTypeSpec.
+type PyTypeSlot struct {
+	Slot  TypeSlot
+	Value uintptr
+}
+
+// TypeFlags are the Py_TPFLAGS_* bits describing a type.
+type TypeFlags uint32
+
+const (
+	TypeFlagsDefault               TypeFlags = 0
+	TypeFlagsDisallowInstantiation TypeFlags = disallowInstantiation
+	TypeFlagsImmutable             TypeFlags = immutableFlag
+	TypeFlagsBaseType              TypeFlags = allowsSubclassingFlag
+)
+
+// PyTypeSpec describes a heap type to create with PyType_FromSpec.
+type PyTypeSpec struct {
+	Name      *byte       // Name is a C string with the (dotted) type name.
+	BasicSize int32       // BasicSize is the size of an instance in bytes.
+	ItemSize  int32       // ItemSize is the size of variable-sized items.
+	Flags     TypeFlags   // Flags are the Py_TPFLAGS_* for the type.
+	Slots     *PyTypeSlot // Slots is a zero-terminated array of slots.
+}
+
+// PyGetSetDef describes a computed attribute (property) of a type.
+type PyGetSetDef struct {
+	Name    *byte   // Name is a C string with the attribute name.
+	Get     uintptr // Get is a C getter function, or 0.
+	Set     uintptr // Set is a C setter function, or 0 for read-only.
+	Doc     *byte   // Doc is a C string describing the attribute.
+	Closure uintptr // Closure is passed as-is to the getter and setter.
+}